@@ -115,6 +115,10 @@ func Forbidden(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusForbidden).JSON(Err("403", fiber.ErrForbidden.Error()))
 }
 
+func Conflict(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusConflict).JSON(Err("409", message))
+}
+
 func InternalError(c *fiber.Ctx, message string) error {
 	return c.Status(fiber.StatusInternalServerError).JSON(Err("500", message))
 }
@@ -21,6 +21,10 @@ type Config struct {
 	RedisConfig       RedisConfig
 	HomeProxyAdapter  AdapterConfig
 	HomeServerAdapter AdapterConfig
+	TrashConfig       TrashConfig
+	BlobStoreConfig   BlobStoreConfig
+	CatalogConfig     CatalogConfig
+	ImportConfig      ImportConfig
 }
 
 type JwtAuthConfig struct {
@@ -72,6 +76,52 @@ type HTTP struct {
 	MaxConnPerHost     int
 }
 
+type TrashConfig struct {
+	RetentionDays int
+}
+
+// BlobStoreConfig selects where uploaded media is kept. Driver is "local"
+// (files under Local.Root, served at Local.ServePath) or "s3" for any
+// S3-compatible object store.
+type BlobStoreConfig struct {
+	Driver        string
+	MaxImageBytes int64
+	MaxAudioBytes int64
+	Local         LocalBlobStoreConfig
+	S3            S3BlobStoreConfig
+}
+
+type LocalBlobStoreConfig struct {
+	Root      string
+	ServePath string
+	BaseURL   string
+}
+
+type S3BlobStoreConfig struct {
+	Endpoint      string
+	Region        string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	UsePathStyle  bool
+	PublicBaseURL string
+	PresignExpiry time.Duration
+}
+
+// CatalogConfig tunes the public set catalog. Rankings are cached in Redis
+// for RankingTTL; trending counts activity of the last TrendingDays days.
+type CatalogConfig struct {
+	RankingTTL   time.Duration
+	TrendingDays int
+}
+
+// ImportConfig bounds the CSV and .apkg files accepted by the import routes.
+// MaxUnpackedBytes caps what is decompressed from one .apkg archive.
+type ImportConfig struct {
+	MaxFileBytes     int64
+	MaxUnpackedBytes int64
+}
+
 type AdapterConfig struct {
 	BaseURL string
 	Timeout time.Duration
@@ -80,6 +130,18 @@ type AdapterConfig struct {
 func InitConfig() (*Config, error) {
 
 	viper.SetDefault("LogConfig.LEVEL", "info")
+	viper.SetDefault("TrashConfig.RetentionDays", 30)
+	viper.SetDefault("BlobStoreConfig.Driver", "local")
+	viper.SetDefault("BlobStoreConfig.MaxImageBytes", 5*1024*1024)
+	viper.SetDefault("BlobStoreConfig.MaxAudioBytes", 10*1024*1024)
+	viper.SetDefault("BlobStoreConfig.Local.Root", "./data/media")
+	viper.SetDefault("BlobStoreConfig.Local.ServePath", "/media")
+	viper.SetDefault("BlobStoreConfig.S3.Region", "us-east-1")
+	viper.SetDefault("BlobStoreConfig.S3.PresignExpiry", "24h")
+	viper.SetDefault("CatalogConfig.RankingTTL", "5m")
+	viper.SetDefault("CatalogConfig.TrendingDays", 7)
+	viper.SetDefault("ImportConfig.MaxFileBytes", 32*1024*1024)
+	viper.SetDefault("ImportConfig.MaxUnpackedBytes", 256*1024*1024)
 
 	configPath, ok := os.LookupEnv("API_CONFIG_PATH")
 	if !ok {
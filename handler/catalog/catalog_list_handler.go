@@ -0,0 +1,37 @@
+package catalog
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewListCatalogHandler(
+	listCatalogFunc ListCatalogFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CatalogListRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+
+		res, err := listCatalogFunc(ctx, logger, req, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error("list catalog failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
@@ -0,0 +1,110 @@
+package catalog
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewRateSetHandler(
+	rateSetFunc RateSetFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CatalogRateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		if err := rateSetFunc(ctx, logger, req); err != nil {
+			logger.Error("rate set failed", zap.String("requestId", requestId), zap.Error(err))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrRateOwnSet:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewDeleteRatingHandler(
+	deleteRatingFunc DeleteRatingFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CatalogRatingDeleteRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		if err := deleteRatingFunc(ctx, logger, req); err != nil {
+			logger.Error("delete rating failed", zap.String("requestId", requestId), zap.Error(err))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewListRatingsHandler(
+	listRatingsFunc ListRatingsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 20)
+		if page < 1 {
+			return api.BadRequest(c, "page must be at least 1")
+		}
+		if size < 1 || size > 100 {
+			return api.BadRequest(c, "size must be between 1 and 100")
+		}
+
+		res, err := listRatingsFunc(ctx, logger, setId, page, size, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error("list ratings failed", zap.String("requestId", requestId), zap.Error(err))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
@@ -0,0 +1,148 @@
+package catalog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+)
+
+const (
+	SortTrending    = "trending"
+	SortMostStudied = "most_studied"
+	SortNewest      = "newest"
+	SortTopRated    = "top_rated"
+
+	ErrRateOwnSet = "cannot rate your own set"
+
+	maxCommentLength = 2000
+)
+
+type CatalogListRequest struct {
+	Sort       string          `json:"sort"`       // trending|most_studied|newest|top_rated
+	Bookmarked string          `json:"bookmarked"` // Y lists only the caller's bookmarks
+	Page       decimal.Decimal `json:"page"`
+	Size       decimal.Decimal `json:"size"`
+}
+
+func (r *CatalogListRequest) Validate() error {
+	switch r.Sort {
+	case "":
+		r.Sort = SortTrending
+	case SortTrending, SortMostStudied, SortNewest, SortTopRated:
+	default:
+		return errors.New("sort must be trending, most_studied, newest or top_rated")
+	}
+	if r.Bookmarked != "" && r.Bookmarked != utils.FlagY && r.Bookmarked != utils.FlagN {
+		return errors.New("bookmarked must be Y or N")
+	}
+	if r.Page.IsZero() || r.Page.IsNegative() {
+		return errors.New("page is required")
+	}
+	if r.Size.IsZero() || r.Size.IsNegative() {
+		return errors.New("size is required")
+	}
+	if r.Size.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("size must be at most 100")
+	}
+	return nil
+}
+
+type CatalogSetDto struct {
+	SetId          decimal.Decimal `json:"setId"`
+	Title          string          `json:"title"`
+	Description    string          `json:"description"`
+	OwnerName      string          `json:"ownerName"`
+	StudyDirection string          `json:"studyDirection"`
+	FrontLang      string          `json:"frontLang"`
+	BackLang       string          `json:"backLang"`
+	Term           int             `json:"term"`
+	Likes          int             `json:"likes"`
+	Bookmarks      int             `json:"bookmarks"`
+	StudyCount     int             `json:"studyCount"` // distinct learners with SRS state on the set
+	AvgRating      float64         `json:"avgRating"`
+	RatingCount    int             `json:"ratingCount"`
+	LikedByMe      bool            `json:"likedByMe"`
+	BookmarkedByMe bool            `json:"bookmarkedByMe"`
+	MyRating       *int            `json:"myRating"`
+	CreateAt       time.Time       `json:"createAt"`
+}
+
+type CatalogListResponse struct {
+	Sort          string          `json:"sort"`
+	Content       []CatalogSetDto `json:"content"`
+	TotalPage     decimal.Decimal `json:"totalPage"`
+	TotalElements decimal.Decimal `json:"totalElements"`
+}
+
+// CatalogToggleRequest turns a like or bookmark on (Y) or off (N).
+type CatalogToggleRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Enabled     string          `json:"enabled"`
+	UserIdToken string          // from middleware
+}
+
+func (r CatalogToggleRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if r.Enabled != utils.FlagY && r.Enabled != utils.FlagN {
+		return errors.New("enabled must be Y or N")
+	}
+	return nil
+}
+
+type CatalogRateRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Stars       decimal.Decimal `json:"stars"`
+	Comment     string          `json:"comment"`
+	UserIdToken string          // from middleware
+	UserId      string          // from middleware
+}
+
+func (r *CatalogRateRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if !r.Stars.IsInteger() || r.Stars.LessThan(decimal.NewFromInt(1)) || r.Stars.GreaterThan(decimal.NewFromInt(5)) {
+		return errors.New("stars must be between 1 and 5")
+	}
+	r.Comment = strings.TrimSpace(r.Comment)
+	if len([]rune(r.Comment)) > maxCommentLength {
+		return errors.New("comment must be at most 2000 characters")
+	}
+	return nil
+}
+
+type CatalogRatingDeleteRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	UserIdToken string          // from middleware
+}
+
+func (r CatalogRatingDeleteRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	return nil
+}
+
+type CatalogRatingDto struct {
+	UserName string     `json:"userName"`
+	Stars    int        `json:"stars"`
+	Comment  string     `json:"comment"`
+	Mine     bool       `json:"mine"`
+	CreateAt time.Time  `json:"createAt"`
+	UpdateAt *time.Time `json:"updateAt"`
+}
+
+type CatalogRatingListResponse struct {
+	SetId         decimal.Decimal    `json:"setId"`
+	AvgRating     float64            `json:"avgRating"`
+	RatingCount   int                `json:"ratingCount"`
+	Histogram     [5]int             `json:"histogram"` // index 0 is one star
+	Content       []CatalogRatingDto `json:"content"`
+	TotalPage     decimal.Decimal    `json:"totalPage"`
+	TotalElements decimal.Decimal    `json:"totalElements"`
+}
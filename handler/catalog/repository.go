@@ -0,0 +1,489 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cache"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+// rankingOrder holds the ORDER BY of each sort. Top rated uses a Bayesian
+// average (five virtual three-star ratings) so a single five-star rating
+// does not outrank a well-reviewed set; trending weighs learners and likes of
+// the last trendingDays days.
+var rankingOrder = map[string]string{
+	SortTrending:    "rst.recent_learners * 2 + lk.recent_likes DESC, st.learners DESC",
+	SortMostStudied: "st.learners DESC, lk.likes DESC",
+	SortNewest:      "s.create_at DESC NULLS LAST",
+	SortTopRated:    "(rt.stars + 15.0) / (rt.ratings + 5) DESC, rt.ratings DESC",
+}
+
+// publicSetSQL checks a set is visible in the catalog and returns its owner.
+const publicSetSQL = `
+    SELECT coalesce(owner_user_token, '')
+      FROM tbl_flashcard_sets
+     WHERE id = $1
+       AND is_public = 'Y'
+       AND is_deleted = 'N'
+`
+
+type RankCatalogFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	sort string,
+) ([]int64, error)
+
+// NewRankCatalog returns the ids of all public sets in sort order. The order
+// is cached in Redis for ttl and only recomputed once it expires, so likes and
+// ratings move a set at most ttl later; Redis errors fall back to the
+// database. Learners are counted from SRS state, and only the trending
+// window of the review log is read.
+func NewRankCatalog(
+	db *pgxpool.Pool,
+	redisCMD redis.UniversalClient,
+	ttl time.Duration,
+	trendingDays int,
+) RankCatalogFunc {
+	const rankSQL = `
+        SELECT s.id
+          FROM tbl_flashcard_sets s
+          LEFT JOIN LATERAL (
+                SELECT count(DISTINCT r.user_id_token) AS learners
+                  FROM tbl_user_flashcard_srs r
+                  JOIN tbl_flashcards f ON f.id = r.card_id
+                 WHERE f.set_id = s.id
+          ) st ON true
+          LEFT JOIN LATERAL (
+                SELECT count(DISTINCT rl.user_id_token) AS recent_learners
+                  FROM tbl_review_log rl
+                  JOIN tbl_flashcards f ON f.id = rl.card_id
+                 WHERE f.set_id = s.id
+                   AND rl.created_at >= now() - make_interval(days => $1)
+          ) rst ON true
+          LEFT JOIN LATERAL (
+                SELECT count(*) AS likes,
+                       count(*) FILTER (WHERE l.create_at >= now() - make_interval(days => $1)) AS recent_likes
+                  FROM tbl_flashcard_set_likes l
+                 WHERE l.set_id = s.id
+          ) lk ON true
+          LEFT JOIN LATERAL (
+                SELECT count(*) AS ratings, coalesce(sum(r.stars), 0) AS stars
+                  FROM tbl_flashcard_set_ratings r
+                 WHERE r.set_id = s.id
+          ) rt ON true
+         WHERE s.is_public = 'Y'
+           AND s.is_deleted = 'N'
+         ORDER BY %s, s.id DESC
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		sort string,
+	) ([]int64, error) {
+		order, ok := rankingOrder[sort]
+		if !ok {
+			return nil, errors.Errorf("unknown sort %q", sort)
+		}
+		key := fmt.Sprintf(cache.KeyCatalogRanking, sort)
+
+		if cached, err := redisCMD.Get(ctx, key).Result(); err == nil {
+			var ids []int64
+			if err := json.Unmarshal([]byte(cached), &ids); err == nil {
+				return ids, nil
+			}
+			logger.Warn("decode cached catalog ranking failed", zap.String("key", key))
+		} else if !errors.Is(err, redis.Nil) {
+			logger.Warn("read catalog ranking cache failed", zap.String("key", key), zap.Error(err))
+		}
+
+		rows, err := db.Query(ctx, fmt.Sprintf(rankSQL, order), trendingDays)
+		if err != nil {
+			logger.Error("rank catalog failed", zap.Error(err), zap.String("sort", sort))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			logger.Error("scan catalog ranking failed", zap.Error(err), zap.String("sort", sort))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		if ids == nil {
+			ids = []int64{}
+		}
+
+		if raw, err := json.Marshal(ids); err == nil {
+			if err := redisCMD.Set(ctx, key, raw, ttl).Err(); err != nil {
+				logger.Warn("write catalog ranking cache failed", zap.String("key", key), zap.Error(err))
+			}
+		}
+		return ids, nil
+	}
+}
+
+type ListCatalogFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req CatalogListRequest,
+	userIdToken string,
+) (CatalogListResponse, error)
+
+// NewListCatalog pages through the cached ranking and loads live counters and
+// the caller's own likes, bookmarks and rating for the sets on the page.
+func NewListCatalog(db *pgxpool.Pool, rankCatalog RankCatalogFunc) ListCatalogFunc {
+	const bookmarksSQL = `
+        SELECT set_id
+          FROM tbl_flashcard_set_bookmarks
+         WHERE user_id_token = $1
+    `
+	const detailSQL = `
+        SELECT s.id,
+               coalesce(s.title, ''),
+               coalesce(s.description, ''),
+               coalesce(s.create_by, ''),
+               s.study_direction,
+               coalesce(s.front_lang, ''),
+               coalesce(s.back_lang, ''),
+               s.create_at,
+               (SELECT count(*) FROM tbl_flashcards f WHERE f.set_id = s.id AND f.is_deleted = 'N'),
+               (SELECT count(*) FROM tbl_flashcard_set_likes l WHERE l.set_id = s.id),
+               (SELECT count(*) FROM tbl_flashcard_set_bookmarks b WHERE b.set_id = s.id),
+               (SELECT count(DISTINCT r.user_id_token)
+                  FROM tbl_user_flashcard_srs r
+                  JOIN tbl_flashcards f ON f.id = r.card_id
+                 WHERE f.set_id = s.id),
+               (SELECT count(*) FROM tbl_flashcard_set_ratings r WHERE r.set_id = s.id),
+               (SELECT coalesce(avg(r.stars), 0)::float8 FROM tbl_flashcard_set_ratings r WHERE r.set_id = s.id),
+               EXISTS (SELECT 1 FROM tbl_flashcard_set_likes l WHERE l.set_id = s.id AND l.user_id_token = $2),
+               EXISTS (SELECT 1 FROM tbl_flashcard_set_bookmarks b WHERE b.set_id = s.id AND b.user_id_token = $2),
+               (SELECT r.stars::int FROM tbl_flashcard_set_ratings r WHERE r.set_id = s.id AND r.user_id_token = $2)
+          FROM tbl_flashcard_sets s
+         WHERE s.id = ANY($1::int[])
+           AND s.is_public = 'Y'
+           AND s.is_deleted = 'N'
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req CatalogListRequest,
+		userIdToken string,
+	) (CatalogListResponse, error) {
+		resp := CatalogListResponse{Sort: req.Sort, Content: []CatalogSetDto{}}
+
+		ids, err := rankCatalog(ctx, logger, req.Sort)
+		if err != nil {
+			return resp, err
+		}
+
+		if req.Bookmarked == utils.FlagY {
+			rows, err := db.Query(ctx, bookmarksSQL, userIdToken)
+			if err != nil {
+				logger.Error("query bookmarks failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			bookmarked, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+			if err != nil {
+				logger.Error("scan bookmarks failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			keep := make(map[int64]bool, len(bookmarked))
+			for _, id := range bookmarked {
+				keep[id] = true
+			}
+			filtered := make([]int64, 0, len(bookmarked))
+			for _, id := range ids {
+				if keep[id] {
+					filtered = append(filtered, id)
+				}
+			}
+			ids = filtered
+		}
+
+		page := int(req.Page.IntPart())
+		size := int(req.Size.IntPart())
+		total := len(ids)
+		resp.TotalElements = decimal.NewFromInt(int64(total))
+		resp.TotalPage = decimal.NewFromInt(int64(math.Ceil(float64(total) / float64(size))))
+
+		start := (page - 1) * size
+		if start >= total {
+			return resp, nil
+		}
+		end := start + size
+		if end > total {
+			end = total
+		}
+		pageIds := ids[start:end]
+
+		rows, err := db.Query(ctx, detailSQL, pageIds, userIdToken)
+		if err != nil {
+			logger.Error("query catalog sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		byId := make(map[int64]CatalogSetDto, len(pageIds))
+		for rows.Next() {
+			var (
+				d  CatalogSetDto
+				id int64
+			)
+			if err := rows.Scan(
+				&id,
+				&d.Title,
+				&d.Description,
+				&d.OwnerName,
+				&d.StudyDirection,
+				&d.FrontLang,
+				&d.BackLang,
+				&d.CreateAt,
+				&d.Term,
+				&d.Likes,
+				&d.Bookmarks,
+				&d.StudyCount,
+				&d.RatingCount,
+				&d.AvgRating,
+				&d.LikedByMe,
+				&d.BookmarkedByMe,
+				&d.MyRating,
+			); err != nil {
+				logger.Error("scan catalog set failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			d.SetId = decimal.NewFromInt(id)
+			d.AvgRating = math.Round(d.AvgRating*100) / 100
+			byId[id] = d
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate catalog sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		// sets made private or deleted after the ranking was cached are skipped
+		for _, id := range pageIds {
+			if d, ok := byId[id]; ok {
+				resp.Content = append(resp.Content, d)
+			}
+		}
+		return resp, nil
+	}
+}
+
+type ToggleFunc func(ctx context.Context, logger *zap.Logger, req CatalogToggleRequest) error
+
+// NewToggleLike likes or unlikes a public set.
+func NewToggleLike(db *pgxpool.Pool) ToggleFunc {
+	const likeSQL = `
+        INSERT INTO tbl_flashcard_set_likes (set_id, user_id_token)
+        VALUES ($1, $2)
+        ON CONFLICT (set_id, user_id_token) DO NOTHING
+    `
+	const unlikeSQL = `
+        DELETE FROM tbl_flashcard_set_likes
+         WHERE set_id = $1
+           AND user_id_token = $2
+    `
+	return newToggle(db, likeSQL, unlikeSQL)
+}
+
+// NewToggleBookmark bookmarks or un-bookmarks a public set.
+func NewToggleBookmark(db *pgxpool.Pool) ToggleFunc {
+	const bookmarkSQL = `
+        INSERT INTO tbl_flashcard_set_bookmarks (set_id, user_id_token)
+        VALUES ($1, $2)
+        ON CONFLICT (set_id, user_id_token) DO NOTHING
+    `
+	const unbookmarkSQL = `
+        DELETE FROM tbl_flashcard_set_bookmarks
+         WHERE set_id = $1
+           AND user_id_token = $2
+    `
+	return newToggle(db, bookmarkSQL, unbookmarkSQL)
+}
+
+func newToggle(db *pgxpool.Pool, onSQL, offSQL string) ToggleFunc {
+	return func(ctx context.Context, logger *zap.Logger, req CatalogToggleRequest) error {
+		sql := offSQL
+		if req.Enabled == utils.FlagY {
+			var owner string
+			if err := db.QueryRow(ctx, publicSetSQL, req.SetId).Scan(&owner); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return errors.New(api.NotFound)
+				}
+				logger.Error("check public set failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			sql = onSQL
+		}
+		if _, err := db.Exec(ctx, sql, req.SetId, req.UserIdToken); err != nil {
+			logger.Error("toggle catalog reaction failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type RateSetFunc func(ctx context.Context, logger *zap.Logger, req CatalogRateRequest) error
+
+// NewRateSet creates or replaces the caller's rating of a public set. Owners
+// cannot rate their own sets.
+func NewRateSet(db *pgxpool.Pool) RateSetFunc {
+	const upsertSQL = `
+        INSERT INTO tbl_flashcard_set_ratings
+            (set_id, user_id_token, stars, comment, create_by)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+        ON CONFLICT (set_id, user_id_token)
+        DO UPDATE
+           SET stars     = EXCLUDED.stars,
+               comment   = EXCLUDED.comment,
+               update_at = now()
+    `
+	return func(ctx context.Context, logger *zap.Logger, req CatalogRateRequest) error {
+		var owner string
+		if err := db.QueryRow(ctx, publicSetSQL, req.SetId).Scan(&owner); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("check public set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if owner == req.UserIdToken {
+			return errors.New(ErrRateOwnSet)
+		}
+		if _, err := db.Exec(ctx, upsertSQL,
+			req.SetId, req.UserIdToken, req.Stars.IntPart(), req.Comment, req.UserId,
+		); err != nil {
+			logger.Error("upsert rating failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type DeleteRatingFunc func(ctx context.Context, logger *zap.Logger, req CatalogRatingDeleteRequest) error
+
+func NewDeleteRating(db *pgxpool.Pool) DeleteRatingFunc {
+	const deleteSQL = `
+        DELETE FROM tbl_flashcard_set_ratings
+         WHERE set_id = $1
+           AND user_id_token = $2
+    `
+	return func(ctx context.Context, logger *zap.Logger, req CatalogRatingDeleteRequest) error {
+		tag, err := db.Exec(ctx, deleteSQL, req.SetId, req.UserIdToken)
+		if err != nil {
+			logger.Error("delete rating failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+type ListRatingsFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	page, size int,
+	userIdToken string,
+) (CatalogRatingListResponse, error)
+
+// NewListRatings returns the rating summary of a public set and its ratings,
+// most recently changed first.
+func NewListRatings(db *pgxpool.Pool) ListRatingsFunc {
+	const summarySQL = `
+        SELECT stars, count(*)
+          FROM tbl_flashcard_set_ratings
+         WHERE set_id = $1
+         GROUP BY stars
+    `
+	const listSQL = `
+        SELECT coalesce(create_by, ''), stars, coalesce(comment, ''),
+               user_id_token = $2, create_at, update_at
+          FROM tbl_flashcard_set_ratings
+         WHERE set_id = $1
+         ORDER BY coalesce(update_at, create_at) DESC, user_id_token
+        OFFSET $3 LIMIT $4
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		page, size int,
+		userIdToken string,
+	) (CatalogRatingListResponse, error) {
+		resp := CatalogRatingListResponse{
+			SetId:   decimal.NewFromInt(setID),
+			Content: []CatalogRatingDto{},
+		}
+
+		var owner string
+		if err := db.QueryRow(ctx, publicSetSQL, setID).Scan(&owner); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return resp, errors.New(api.NotFound)
+			}
+			logger.Error("check public set failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		rows, err := db.Query(ctx, summarySQL, setID)
+		if err != nil {
+			logger.Error("query rating summary failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		sum := 0
+		for rows.Next() {
+			var stars, count int
+			if err := rows.Scan(&stars, &count); err != nil {
+				rows.Close()
+				logger.Error("scan rating summary failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if stars >= 1 && stars <= 5 {
+				resp.Histogram[stars-1] = count
+			}
+			resp.RatingCount += count
+			sum += stars * count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate rating summary failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if resp.RatingCount > 0 {
+			resp.AvgRating = math.Round(float64(sum)/float64(resp.RatingCount)*100) / 100
+		}
+		resp.TotalElements = decimal.NewFromInt(int64(resp.RatingCount))
+		resp.TotalPage = decimal.NewFromInt(int64(math.Ceil(float64(resp.RatingCount) / float64(size))))
+
+		rows, err = db.Query(ctx, listSQL, setID, userIdToken, (page-1)*size, size)
+		if err != nil {
+			logger.Error("query ratings failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var r CatalogRatingDto
+			if err := rows.Scan(&r.UserName, &r.Stars, &r.Comment, &r.Mine, &r.CreateAt, &r.UpdateAt); err != nil {
+				logger.Error("scan rating failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			resp.Content = append(resp.Content, r)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate ratings failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"github.com/go-redis/redis/v9"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+)
+
+func GetRouter(
+	group fiber.Router,
+	config config.Config,
+	redisCMD *redis.UniversalClient,
+	dbPool *pgxpool.Pool,
+) {
+	catalogGroup := group.Group("/catalog")
+	catalogGroup.Post("/list", NewListCatalogHandler(
+		NewListCatalog(dbPool, NewRankCatalog(
+			dbPool,
+			*redisCMD,
+			config.CatalogConfig.RankingTTL,
+			config.CatalogConfig.TrendingDays,
+		)),
+	))
+	catalogGroup.Post("/like", NewToggleHandler(
+		NewToggleLike(dbPool),
+	))
+	catalogGroup.Post("/bookmark", NewToggleHandler(
+		NewToggleBookmark(dbPool),
+	))
+	catalogGroup.Post("/rate", NewRateSetHandler(
+		NewRateSet(dbPool),
+	))
+	catalogGroup.Post("/rate/delete", NewDeleteRatingHandler(
+		NewDeleteRating(dbPool),
+	))
+	catalogGroup.Get("/:setId/ratings", NewListRatingsHandler(
+		NewListRatings(dbPool),
+	))
+}
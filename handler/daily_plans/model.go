@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 )
 
@@ -35,13 +37,20 @@ func (r *DailyPlanSettingRequest) Validate() error {
 }
 
 type DailyFlashCardSetsInquiryResponse struct {
-	DailyPlanId decimal.Decimal `json:"dailyPlanId"`
-	Id          decimal.Decimal `json:"id"`
-	Front       string          `json:"front"`
-	Back        string          `json:"back"`
-	Choices     []string        `json:"choices"`
-	Status      string          `json:"status"`
-	CreateAt    time.Time       `json:"createAt"`
-	OwnerName   string          `json:"ownerName"`
-	Seq         decimal.Decimal `json:"seq"`
+	DailyPlanId decimal.Decimal        `json:"dailyPlanId"`
+	Id          decimal.Decimal        `json:"id"`
+	Front       string                 `json:"front"`
+	Back        string                 `json:"back"`
+	Choices     []string               `json:"choices"`
+	Status      string                 `json:"status"`
+	CardType    string                 `json:"cardType"`
+	ClozeOrd    int                    `json:"clozeOrd,omitempty"`
+	Direction   string                 `json:"direction"`
+	FrontLang   string                 `json:"frontLang"` // language of Front as shown, "" when the set has none
+	BackLang    string                 `json:"backLang"`
+	CreateAt    time.Time              `json:"createAt"`
+	OwnerName   string                 `json:"ownerName"`
+	Seq         decimal.Decimal        `json:"seq"`
+	Media       []cardmedia.Attachment `json:"media"`
+	Audio       tts.CardAudio          `json:"audio"`
 }
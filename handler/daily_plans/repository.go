@@ -8,6 +8,10 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"go.uber.org/zap"
 )
 
@@ -90,7 +94,7 @@ type DailyPlansInquiryFunc func(
 	userId string,
 ) ([]DailyFlashCardSetsInquiryResponse, error)
 
-func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
+func NewDailyPlansInquiry(db *pgxpool.Pool, loadMedia cardmedia.CardMediaLoaderFunc, loadAudio tts.CardAudioLoaderFunc) DailyPlansInquiryFunc {
 	return func(
 		ctx context.Context,
 		logger *zap.Logger,
@@ -110,13 +114,21 @@ func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
                 f.front,
                 f.back,
                 f.choices,
-                f.status,
+                coalesce(us.status, 'studying'),
+                f.card_type,
+                s.study_direction,
+                coalesce(s.front_lang, ''),
+                coalesce(s.back_lang, ''),
                 f.create_at,
                 f.create_by AS owner_name,
                 u.ordinality
             FROM plan p
             CROSS JOIN LATERAL unnest(p.card_ids) WITH ORDINALITY AS u(card_id, ordinality)
             JOIN tbl_flashcards f ON f.id = u.card_id
+            JOIN tbl_flashcard_sets s ON s.id = f.set_id
+            LEFT JOIN tbl_user_flashcard_status us
+                   ON us.card_id = f.id
+                  AND us.user_id_token = $1
             WHERE f.is_deleted = 'N'
             ORDER BY u.ordinality
         `
@@ -128,7 +140,8 @@ func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
 		}
 		defer rows.Close()
 
-		var result []DailyFlashCardSetsInquiryResponse
+		var result, reverse []DailyFlashCardSetsInquiryResponse
+		var fronts []string
 		for rows.Next() {
 			var (
 				idPlan    int64
@@ -137,6 +150,10 @@ func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
 				back      string
 				choices   []string
 				status    string
+				cardType  string
+				direction string
+				frontLang string
+				backLang  string
 				createAt  time.Time
 				ownerName string
 				ord       int64
@@ -148,6 +165,10 @@ func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
 				&back,
 				&choices,
 				&status,
+				&cardType,
+				&direction,
+				&frontLang,
+				&backLang,
 				&createAt,
 				&ownerName,
 				&ord,
@@ -156,22 +177,74 @@ func NewDailyPlansInquiry(db *pgxpool.Pool) DailyPlansInquiryFunc {
 				return nil, errors.New(api.SomeThingWentWrong)
 			}
 
-			result = append(result, DailyFlashCardSetsInquiryResponse{
+			item := DailyFlashCardSetsInquiryResponse{
 				DailyPlanId: decimal.NewFromInt(idPlan),
 				Id:          decimal.NewFromInt(idInt),
 				Front:       front,
 				Back:        back,
 				Choices:     choices,
 				Status:      status,
+				CardType:    cardType,
+				Direction:   studyitem.DirectionForward,
+				FrontLang:   frontLang,
+				BackLang:    backLang,
 				CreateAt:    createAt,
 				OwnerName:   ownerName,
-				Seq:         decimal.NewFromInt(ord),
-			})
+			}
+			if cardType != cloze.CardTypeCloze {
+				result = append(result, item)
+				fronts = append(fronts, front)
+				if direction == studyitem.SetDirectionBoth {
+					back := item
+					back.Front, back.Back = item.Back, item.Front
+					back.FrontLang, back.BackLang = item.BackLang, item.FrontLang
+					back.Direction = studyitem.DirectionReverse
+					reverse = append(reverse, back)
+				}
+				continue
+			}
+			// a cloze note is studied once per blank
+			for _, clozeOrd := range cloze.Ordinals(front) {
+				blank := item
+				blank.Front = cloze.Prompt(front, clozeOrd)
+				blank.Back = cloze.Answer(front, clozeOrd)
+				blank.ClozeOrd = clozeOrd
+				// the hidden text is read from the front, so in its language
+				blank.BackLang = item.FrontLang
+				result = append(result, blank)
+			}
 		}
 		if err := rows.Err(); err != nil {
 			logger.Error("iterating daily plan card rows failed", zap.Error(err), zap.String("user_id_token", userId))
 			return nil, errors.New(api.SomeThingWentWrong)
 		}
+		// reverse items follow the forward pass so a card is not asked both
+		// ways back to back
+		for _, item := range reverse {
+			item.Choices = studyitem.Choices(item.Back, fronts)
+			result = append(result, item)
+		}
+
+		cardIDs := make([]int64, 0, len(result))
+		for i, r := range result {
+			result[i].Seq = decimal.NewFromInt(int64(i + 1))
+			cardIDs = append(cardIDs, r.Id.IntPart())
+		}
+		media, err := loadMedia(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		audio, err := loadAudio(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for i := range result {
+			result[i].Media = media[result[i].Id.IntPart()]
+			if result[i].Media == nil {
+				result[i].Media = []cardmedia.Attachment{}
+			}
+			result[i].Audio = audio[result[i].Id.IntPart()].ForItem(result[i].CardType == cloze.CardTypeCloze, result[i].Direction)
+		}
 
 		return result, nil
 	}
@@ -5,7 +5,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/httputil"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 )
 
 func GetRouter(group fiber.Router,
@@ -13,6 +16,7 @@ func GetRouter(group fiber.Router,
 	redisCMD *redis.UniversalClient,
 	dbPool *pgxpool.Pool,
 	postFunc httputil.HTTPPostRequestFunc,
+	mediaStore blobstore.Store,
 ) {
 	dailyPlanGroup := group.Group("/daily-plans")
 	dailyPlanGroup.Get("", NewDailyConfigHandler(
@@ -23,6 +27,6 @@ func GetRouter(group fiber.Router,
 		NewUpdateDailyPlansFunc(dbPool),
 	))
 	dailyPlanGroup.Get("/inquiry", NewDailyPlansInquiryHandler(
-		NewDailyPlansInquiry(dbPool),
+		NewDailyPlansInquiry(dbPool, cardmedia.NewCardMediaLoader(dbPool, mediaStore), tts.NewCardAudioLoader(dbPool)),
 	))
 }
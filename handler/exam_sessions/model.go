@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 )
 
@@ -21,15 +23,17 @@ type StartExamRequest struct {
 	Mode  string           `json:"mode"`
 	//ClassId           *decimal.Decimal  `json:"classId,omitempty"`
 	DailyPlanId      *decimal.Decimal `json:"planId,omitempty"`
+	Tags             []string         `json:"tags,omitempty"`
 	QuestionCount    decimal.Decimal  `json:"totalQuestions"`
 	TimeLimitSeconds *int64           `json:"timeLimitSec,omitempty"`
 	TimeLimit        *time.Time
 	UserId           string
+	UserIdToken      string
 }
 
 func (r StartExamRequest) Validate() error {
-	if r.SetId == nil && r.DailyPlanId == nil {
-		return errors.New("one of setId, classId or dailyPlanId must be provided")
+	if r.SetId == nil && r.DailyPlanId == nil && len(r.Tags) == 0 {
+		return errors.New("one of setId, classId, dailyPlanId or tags must be provided")
 	}
 	if r.QuestionCount.IsZero() || r.QuestionCount.IsNegative() {
 		return errors.New("examTotalQuestion must be provided")
@@ -38,14 +42,19 @@ func (r StartExamRequest) Validate() error {
 }
 
 type FlashCardDetails struct {
-	Id        decimal.Decimal `json:"id"`
-	Front     string          `json:"front"`
-	Back      string          `json:"back"`
-	Choices   []string        `json:"choices"`
-	Status    string          `json:"status"`
-	CreateAt  time.Time       `json:"createAt"`
-	OwnerName string          `json:"ownerName"`
-	Seq       decimal.Decimal `json:"seq"`
+	Id        decimal.Decimal        `json:"id"`
+	Front     string                 `json:"front"`
+	Back      string                 `json:"back"`
+	Choices   []string               `json:"choices"`
+	Status    string                 `json:"status"`
+	CardType  string                 `json:"cardType"`
+	ClozeOrd  int                    `json:"clozeOrd,omitempty"`
+	Direction string                 `json:"direction"`
+	CreateAt  time.Time              `json:"createAt"`
+	OwnerName string                 `json:"ownerName"`
+	Seq       decimal.Decimal        `json:"seq"`
+	Media     []cardmedia.Attachment `json:"media"`
+	Audio     tts.CardAudio          `json:"audio"`
 }
 
 type StartExamResponse struct {
@@ -69,15 +78,19 @@ type ExamSessionDto struct {
 }
 
 type ExamQuestionDto struct {
-	QuestionID       int64    `json:"questionId"`
-	Seq              int      `json:"seq"`
-	CardID           int64    `json:"cardId"`
-	QuestionType     string   `json:"questionType"`
-	FrontSnapshot    string   `json:"frontSnapshot"`
-	BackSnapshot     string   `json:"backSnapshot"`
-	ChoicesSnapshot  []string `json:"choicesSnapshot,omitempty"`
-	PromptTtsCacheId *int64   `json:"promptTtsCacheId,omitempty"`
-	ScoreMax         int      `json:"scoreMax"`
+	QuestionID       int64                  `json:"questionId"`
+	Seq              int                    `json:"seq"`
+	CardID           int64                  `json:"cardId"`
+	ClozeOrd         int                    `json:"clozeOrd,omitempty"`
+	Direction        string                 `json:"direction"`
+	QuestionType     string                 `json:"questionType"`
+	FrontSnapshot    string                 `json:"frontSnapshot"`
+	BackSnapshot     string                 `json:"backSnapshot"`
+	ChoicesSnapshot  []string               `json:"choicesSnapshot,omitempty"`
+	PromptTtsCacheId *int64                 `json:"promptTtsCacheId,omitempty"`
+	ScoreMax         int                    `json:"scoreMax"`
+	Media            []cardmedia.Attachment `json:"media"`
+	Audio            tts.CardAudio          `json:"audio"`
 
 	Answer *ExamAnswerDto `json:"answer,omitempty"`
 }
@@ -117,16 +130,19 @@ type AnswersDetail struct {
 	} `json:"answerList"`
 }
 
+// ExamSessionUpdateRequest answers one question: MCQ questions send the
+// choice letter, TYPING questions send the typed text.
 type ExamSessionUpdateRequest struct {
 	SessionId   decimal.Decimal `json:"sessionId"`
 	SeqId       decimal.Decimal `json:"seqId"`
 	AnswerType  string          `json:"answerType"`
 	Choice      string          `json:"choice"`
+	TypedText   *string         `json:"typedText,omitempty"`
 	UserIdToken string
 }
 
 func (r ExamSessionUpdateRequest) Validate() error {
-	if utils.GetIndexFromString(r.Choice) == 0 {
+	if r.TypedText == nil && utils.GetIndexFromString(r.Choice) == 0 {
 		return errors.New("choice must start with a letter")
 	}
 	if r.SessionId.IsZero() {
@@ -169,6 +185,8 @@ type ExamSessionListResponseDetails struct {
 
 type ExamSubmitItem struct {
 	CardID       int64
+	ClozeOrd     int
+	Direction    string
 	Source       string
 	IsCorrect    string
 	Grade        int16
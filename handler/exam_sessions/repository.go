@@ -12,6 +12,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
@@ -51,6 +54,44 @@ func NewSelectQuestionIds(db *pgxpool.Pool) SelectQuestionIdsFunc {
 			return ids, nil
 		}
 
+		if len(req.Tags) > 0 {
+			const sql = `
+                SELECT f.id
+                  FROM tbl_flashcards f
+                  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+                 WHERE f.is_deleted = 'N'
+                   AND s.is_deleted = 'N'
+                   AND (s.owner_user_token = $1 OR s.is_public = 'Y')
+                   AND EXISTS (
+                        SELECT 1
+                          FROM tbl_tags t
+                          LEFT JOIN tbl_flashcard_tags ct ON ct.tag_id = t.id AND ct.card_id = f.id
+                          LEFT JOIN tbl_flashcard_set_tags st ON st.tag_id = t.id AND st.set_id = s.id
+                         WHERE t.user_id_token = $1
+                           AND lower(t.name) = ANY (SELECT lower(btrim(unnest($2::text[]))))
+                           AND (ct.card_id IS NOT NULL OR st.set_id IS NOT NULL)
+                   )
+                 ORDER BY random()
+                 LIMIT $3;
+            `
+			rows, err := db.Query(ctx, sql, req.UserIdToken, req.Tags, req.QuestionCount.IntPart())
+			if err != nil {
+				logger.Error("query flashcards by tags failed", zap.Error(err), zap.Strings("tags", req.Tags))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			defer rows.Close()
+
+			var ids []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					return nil, err
+				}
+				ids = append(ids, id)
+			}
+			return ids, nil
+		}
+
 		if req.DailyPlanId != nil {
 			const sql = `
                 SELECT unnest(card_ids)
@@ -111,6 +152,64 @@ func NewInsertStartExamSessions(db *pgxpool.Pool) InsertStartExamSessionsFunc {
 				err = errors.New(api.SomeThingWentWrong)
 			}
 		}()
+		questionType := "MCQ"
+		switch req.Mode {
+		case "TYPING":
+			questionType = "TYPING"
+		case "LISTENING":
+			questionType = "LISTENING"
+		case "SPEAKING":
+			questionType = "SPEAKING"
+		case "MIXED", "MCQ":
+			questionType = "MCQ"
+		}
+
+		const cardsSQL = `
+			SELECT
+			  c.id,
+			  coalesce(c.front, ''),
+			  coalesce(c.back, ''),
+			  c.choices,
+			  c.card_type,
+			  s.study_direction
+			FROM unnest($1::bigint[]) WITH ORDINALITY AS x(card_id, ord)
+			JOIN tbl_flashcards c
+			  ON c.id = x.card_id
+			 AND c.is_deleted = 'N'
+			JOIN tbl_flashcard_sets s
+			  ON s.id = c.set_id
+			ORDER BY x.ord;
+		`
+		rows, err := tx.Query(ctx, cardsSQL, cardIds)
+		if err != nil {
+			logger.Error("load exam cards failed", zap.Error(err), zap.String("userIdToken", userIdToken))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		var cards []examCard
+		for rows.Next() {
+			var card examCard
+			if err = rows.Scan(&card.id, &card.front, &card.back, &card.choices, &card.cardType, &card.setDirection); err != nil {
+				rows.Close()
+				logger.Error("scan exam card failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			cards = append(cards, card)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("iterate exam cards failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if len(cards) != len(cardIds) {
+			logger.Error("exam cards mismatch",
+				zap.Int("found", len(cards)),
+				zap.Int("expected", len(cardIds)),
+			)
+			err = errors.New(fmt.Sprintf("%s: some cards not found", api.InvalidateBody))
+			return 0, err
+		}
+		questions := buildExamQuestions(cards, questionType, int(req.QuestionCount.IntPart()))
+
 		const sql = `
 			insert into tbl_exam_sessions (user_id_token, mode, source_set_id, plan_id, total_questions, time_limit_sec, status,started_at, expires_at,score_max)
 			values ($1,$2,$3,$4,$5,$6,$7,now(),$8,$9)
@@ -122,51 +221,24 @@ func NewInsertStartExamSessions(db *pgxpool.Pool) InsertStartExamSessionsFunc {
 			req.Mode,
 			req.SetId,
 			req.DailyPlanId,
-			req.QuestionCount,
+			len(questions),
 			req.TimeLimitSeconds,
 			"ACTIVE",
 			req.TimeLimit,
-			req.QuestionCount,
+			len(questions),
 		).Scan(&sessionId)
 		if err != nil {
 			logger.Error("insert exam session failed", zap.Error(err), zap.String("userIdToken", userIdToken))
 			return 0, errors.New(api.SomeThingWentWrong)
 		}
-		questionType := "MCQ"
-		switch req.Mode {
-		case "TYPING":
-			questionType = "TYPING"
-		case "LISTENING":
-			questionType = "LISTENING"
-		case "SPEAKING":
-			questionType = "SPEAKING"
-		case "MIXED", "MCQ":
-			questionType = "MCQ"
-		}
 
-		const insertQuestionsSQL = `
-			WITH input AS (
-			  SELECT
-				x.card_id,
-				x.ord::int AS seq
-			  FROM unnest($2::bigint[]) WITH ORDINALITY AS x(card_id, ord)
-			),
-			cards AS (
-			  SELECT
-				i.seq,
-				c.id AS card_id,
-				c.front,
-				c.back,
-				c.choices
-			  FROM input i
-			  JOIN tbl_flashcards c
-				ON c.id = i.card_id
-			   AND c.is_deleted = 'N'
-			)
+		const insertQuestionSQL = `
 			INSERT INTO tbl_exam_questions (
 			  session_id,
 			  seq,
 			  card_id,
+			  cloze_ord,
+			  direction,
 			  question_type,
 			  front_snapshot,
 			  back_snapshot,
@@ -174,37 +246,19 @@ func NewInsertStartExamSessions(db *pgxpool.Pool) InsertStartExamSessionsFunc {
 			  prompt_tts_cache_id,
 			  score_max
 			)
-			SELECT
-			  $1,
-			  cards.seq,
-			  cards.card_id,
-			  $3,
-			  cards.front,
-			  cards.back,
-			  cards.choices,
-			  NULL,
-			  1
-			FROM cards
-			ORDER BY cards.seq;
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULL, 1);
 			`
-
-		cmdTag, err := tx.Exec(ctx, insertQuestionsSQL, sessionId, cardIds, questionType)
-		if err != nil {
-			logger.Error("insert exam questions failed",
-				zap.Error(err),
-				zap.Int64("sessionId", sessionId),
-				zap.String("userIdToken", userIdToken),
-			)
-			return 0, errors.New(api.SomeThingWentWrong)
-		}
-
-		if cmdTag.RowsAffected() != int64(len(cardIds)) {
-			logger.Error("insert exam questions rows mismatch",
-				zap.Int64("sessionId", sessionId),
-				zap.Int64("rowsAffected", cmdTag.RowsAffected()),
-				zap.Int("expected", len(cardIds)),
-			)
-			return 0, errors.New(fmt.Sprintf("%s: some cards not found", api.InvalidateBody))
+		for i, q := range questions {
+			if _, err = tx.Exec(ctx, insertQuestionSQL,
+				sessionId, i+1, q.cardId, q.clozeOrd, q.direction, questionType, q.front, q.back, q.choices,
+			); err != nil {
+				logger.Error("insert exam question failed",
+					zap.Error(err),
+					zap.Int64("sessionId", sessionId),
+					zap.String("userIdToken", userIdToken),
+				)
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
 		}
 
 		return sessionId, nil
@@ -212,45 +266,60 @@ func NewInsertStartExamSessions(db *pgxpool.Pool) InsertStartExamSessionsFunc {
 
 }
 
-type GetFlashCardDetailsFromIdsFunc func(ctx context.Context, logger *zap.Logger, questionIDs []int64) ([]FlashCardDetails, error)
+type GetExamQuestionDetailsFunc func(ctx context.Context, logger *zap.Logger, sessionId int64) ([]FlashCardDetails, error)
 
-func NewGetFlashCardDetailsFromIds(db *pgxpool.Pool) GetFlashCardDetailsFromIdsFunc {
-	return func(ctx context.Context, logger *zap.Logger, questionIDs []int64) ([]FlashCardDetails, error) {
+// NewGetExamQuestionDetails returns the questions of a session as presented
+// to the user, so cloze questions carry the blanked sentence and hidden text.
+func NewGetExamQuestionDetails(db *pgxpool.Pool, loadMedia cardmedia.CardMediaLoaderFunc, loadAudio tts.CardAudioLoaderFunc) GetExamQuestionDetailsFunc {
+	return func(ctx context.Context, logger *zap.Logger, sessionId int64) ([]FlashCardDetails, error) {
 		const sql = `
             SELECT
-                f.id,
-                f.front,
-                f.back,
-                f.choices,
-                f.status,
+                q.card_id,
+                q.cloze_ord,
+                q.direction,
+                coalesce(q.front_snapshot, ''),
+                coalesce(q.back_snapshot, ''),
+                q.choices_snapshot,
+                coalesce(us.status, 'studying'),
+                f.card_type,
                 f.create_at,
                 f.create_by AS owner_name,
-                u.ordinality
-            FROM unnest($1::bigint[]) WITH ORDINALITY AS u(card_id, ordinality)
-            JOIN tbl_flashcards f ON f.id = u.card_id
-            WHERE f.is_deleted = 'N'
-            ORDER BY u.ordinality;
+                q.seq
+            FROM tbl_exam_questions q
+            JOIN tbl_exam_sessions es ON es.id = q.session_id
+            JOIN tbl_flashcards f ON f.id = q.card_id
+            LEFT JOIN tbl_user_flashcard_status us
+                   ON us.card_id = f.id
+                  AND us.user_id_token = es.user_id_token
+            WHERE q.session_id = $1
+            ORDER BY q.seq;
         `
-		rows, err := db.Query(ctx, sql, questionIDs)
+		rows, err := db.Query(ctx, sql, sessionId)
 		if err != nil {
-			logger.Error("fetch question details failed", zap.Error(err), zap.Any("questionIDs", questionIDs))
+			logger.Error("fetch question details failed", zap.Error(err), zap.Int64("sessionId", sessionId))
 			return nil, errors.New(api.SomeThingWentWrong)
 		}
 		defer rows.Close()
 
-		var details []FlashCardDetails
+		var (
+			details []FlashCardDetails
+			cardIDs []int64
+		)
 		for rows.Next() {
 			var (
 				idInt     int64
+				clozeOrd  int
+				direction string
 				front     string
 				back      string
 				choices   []string
 				status    string
+				cardType  string
 				createAt  time.Time
 				ownerName string
-				ord       int64
+				seq       int64
 			)
-			if err := rows.Scan(&idInt, &front, &back, &choices, &status, &createAt, &ownerName, &ord); err != nil {
+			if err := rows.Scan(&idInt, &clozeOrd, &direction, &front, &back, &choices, &status, &cardType, &createAt, &ownerName, &seq); err != nil {
 				logger.Error("scan question detail failed", zap.Error(err))
 				return nil, errors.New(api.SomeThingWentWrong)
 			}
@@ -260,22 +329,39 @@ func NewGetFlashCardDetailsFromIds(db *pgxpool.Pool) GetFlashCardDetailsFromIdsF
 				Back:      back,
 				Choices:   choices,
 				Status:    status,
+				CardType:  cardType,
+				ClozeOrd:  clozeOrd,
+				Direction: direction,
 				CreateAt:  createAt,
 				OwnerName: ownerName,
-				Seq:       decimal.NewFromInt(ord),
+				Seq:       decimal.NewFromInt(seq),
 			})
+			cardIDs = append(cardIDs, idInt)
 		}
 		if err := rows.Err(); err != nil {
 			logger.Error("iterating details failed", zap.Error(err))
 			return nil, errors.New(api.SomeThingWentWrong)
 		}
+
+		media, err := loadMedia(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		audio, err := loadAudio(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for i := range details {
+			details[i].Media = mediaOrEmpty(media[details[i].Id.IntPart()])
+			details[i].Audio = audio[details[i].Id.IntPart()].ForItem(details[i].ClozeOrd > 0, details[i].Direction)
+		}
 		return details, nil
 	}
 }
 
 type GetExamSessionFunc func(ctx context.Context, logger *zap.Logger, examID int64) (*ExamSessionDto, error)
 
-func NewGetExamSession(db *pgxpool.Pool) GetExamSessionFunc {
+func NewGetExamSession(db *pgxpool.Pool, loadMedia cardmedia.CardMediaLoaderFunc, loadAudio tts.CardAudioLoaderFunc) GetExamSessionFunc {
 	return func(ctx context.Context, logger *zap.Logger, examID int64) (*ExamSessionDto, error) {
 		// 1) Load session header
 		const sqlSession = `
@@ -320,7 +406,9 @@ func NewGetExamSession(db *pgxpool.Pool) GetExamSessionFunc {
 			SELECT
 			   txq.id as question_id,
 			   txq.seq,
-			   txq.card_id,
+			   coalesce(txq.card_id, 0),
+			   txq.cloze_ord,
+			   txq.direction,
 			   txq.question_type,
 			   txq.front_snapshot,
 			   txq.back_snapshot,
@@ -375,6 +463,8 @@ func NewGetExamSession(db *pgxpool.Pool) GetExamSessionFunc {
 				&q.QuestionID,
 				&q.Seq,
 				&q.CardID,
+				&q.ClozeOrd,
+				&q.Direction,
 				&q.QuestionType,
 				&q.FrontSnapshot,
 				&q.BackSnapshot,
@@ -426,6 +516,26 @@ func NewGetExamSession(db *pgxpool.Pool) GetExamSessionFunc {
 			return nil, errors.New(api.SomeThingWentWrong)
 		}
 
+		cardIDs := make([]int64, 0, len(dto.Questions))
+		for _, q := range dto.Questions {
+			if q.CardID != 0 {
+				cardIDs = append(cardIDs, q.CardID)
+			}
+		}
+		media, err := loadMedia(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		audio, err := loadAudio(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for i := range dto.Questions {
+			q := &dto.Questions[i]
+			q.Media = mediaOrEmpty(media[q.CardID])
+			q.Audio = audio[q.CardID].ForItem(q.ClozeOrd > 0, q.Direction)
+		}
+
 		return &dto, nil
 	}
 }
@@ -459,6 +569,7 @@ func NewUpdateExamSession(db *pgxpool.Pool) UpdateExamSessionFunc {
 			`
 			SELECT
 				  id,
+				  coalesce(back_snapshot, ''),
 				  (
 					$1 BETWEEN 1 AND array_length(choices_snapshot, 1)
 					AND choices_snapshot[$1] = back_snapshot
@@ -467,12 +578,20 @@ func NewUpdateExamSession(db *pgxpool.Pool) UpdateExamSessionFunc {
 				WHERE session_id = $2
 				  AND seq = $3;
 			`
+		var expected string
 		err = tx.QueryRow(ctx, sqlQuestion, choiceIndex, req.SessionId, req.SeqId).Scan(
-			&questionId, &isCorrect)
+			&questionId, &expected, &isCorrect)
 		if err != nil {
 			logger.Error("failed to update exam question row", zap.Error(err))
 			return correct, errors.New(api.SomeThingWentWrong)
 		}
+		// typed answers are graded against the back, which for a cloze
+		// question is the hidden text
+		typedText := req.AnswerType
+		if req.TypedText != nil {
+			typedText = *req.TypedText
+			isCorrect = cloze.Match(expected, typedText)
+		}
 
 		if isCorrect {
 			score = 1
@@ -503,7 +622,7 @@ func NewUpdateExamSession(db *pgxpool.Pool) UpdateExamSessionFunc {
 				  answered_at     = now();
 
         `
-		_, err = tx.Exec(ctx, sqlInsertAnswer, req.SessionId, questionId, req.UserIdToken, req.Choice, req.AnswerType, correct, score)
+		_, err = tx.Exec(ctx, sqlInsertAnswer, req.SessionId, questionId, req.UserIdToken, req.Choice, typedText, correct, score)
 		if err != nil {
 			logger.Error("failed to update exam answer row", zap.Error(err))
 			return correct, errors.New(api.SomeThingWentWrong)
@@ -596,12 +715,16 @@ func NewInsertReviewLogsFunc() InsertReviewLogsFunc {
 		}
 
 		cardIDs := make([]int64, 0, len(items))
+		clozeOrds := make([]int16, 0, len(items))
+		directions := make([]string, 0, len(items))
 		sources := make([]string, 0, len(items))
 		grades := make([]int16, 0, len(items))
 		isCorrects := make([]string, 0, len(items))
 		answerDetails := make([][]byte, 0, len(items))
 		for _, it := range items {
 			cardIDs = append(cardIDs, it.CardID)
+			clozeOrds = append(clozeOrds, int16(it.ClozeOrd))
+			directions = append(directions, it.Direction)
 			sources = append(sources, it.Source)
 			grades = append(grades, it.Grade)
 			isCorrects = append(isCorrects, it.IsCorrect)
@@ -615,7 +738,9 @@ func NewInsertReviewLogsFunc() InsertReviewLogsFunc {
 				source,
 				grade,
 				is_correct,
-				answer_detail
+				answer_detail,
+				cloze_ord,
+				direction
 			)
 			SELECT
 				$1::varchar(36),
@@ -623,17 +748,21 @@ func NewInsertReviewLogsFunc() InsertReviewLogsFunc {
 				x.source,
 				x.grade,
 				x.is_correct,
-				x.answer_detail::jsonb
+				x.answer_detail::jsonb,
+				x.cloze_ord,
+				x.direction
 			FROM unnest(
 				$2::bigint[],
 				$3::text[],
 				$4::smallint[],
 				$5::text[],
-				$6::jsonb[]
-			) AS x(card_id, source, grade, is_correct, answer_detail);
+				$6::jsonb[],
+				$7::smallint[],
+				$8::text[]
+			) AS x(card_id, source, grade, is_correct, answer_detail, cloze_ord, direction);
 		`
 
-		_, err := tx.Exec(ctx, sql, userIdToken, cardIDs, sources, grades, isCorrects, answerDetails)
+		_, err := tx.Exec(ctx, sql, userIdToken, cardIDs, sources, grades, isCorrects, answerDetails, clozeOrds, directions)
 		return err
 	}
 }
@@ -671,10 +800,14 @@ func NewUpsertUserFlashcardSrsBatchFunc() UpsertUserFlashcardSrsBatchFunc {
 		}
 
 		cardIDs := make([]int64, 0, len(items))
+		clozeOrds := make([]int16, 0, len(items))
+		directions := make([]string, 0, len(items))
 		grades := make([]int16, 0, len(items))
 
 		for _, it := range items {
 			cardIDs = append(cardIDs, it.CardID)
+			clozeOrds = append(clozeOrds, int16(it.ClozeOrd))
+			directions = append(directions, it.Direction)
 			grades = append(grades, it.Grade)
 		}
 
@@ -683,12 +816,14 @@ func NewUpsertUserFlashcardSrsBatchFunc() UpsertUserFlashcardSrsBatchFunc {
 		  SELECT
 			$1::varchar(36) AS user_id_token,
 			x.card_id::bigint AS card_id,
-			x.grade::smallint AS grade
-		  FROM unnest($2::bigint[], $3::smallint[]) AS x(card_id, grade)
+			x.grade::smallint AS grade,
+			x.cloze_ord::smallint AS cloze_ord,
+			x.direction::varchar(7) AS direction
+		  FROM unnest($2::bigint[], $3::smallint[], $4::smallint[], $5::text[]) AS x(card_id, grade, cloze_ord, direction)
 		),
 		upsert AS (
 		  INSERT INTO tbl_user_flashcard_srs(
-			user_id_token, card_id,
+			user_id_token, card_id, cloze_ord, direction,
 			box, next_review_at, last_review_at,
 			streak, total_reviews, last_grade,
 			created_at, updated_at
@@ -696,6 +831,8 @@ func NewUpsertUserFlashcardSrsBatchFunc() UpsertUserFlashcardSrsBatchFunc {
 		  SELECT
 			i.user_id_token,
 			i.card_id,
+			i.cloze_ord,
+			i.direction,
 			CASE
 			  WHEN i.grade <= 2 THEN 1
 			  WHEN i.grade = 3 THEN 1
@@ -716,7 +853,7 @@ func NewUpsertUserFlashcardSrsBatchFunc() UpsertUserFlashcardSrsBatchFunc {
 			now() AS created_at,
 			now() AS updated_at
 		  FROM input i
-		  ON CONFLICT (user_id_token, card_id)
+		  ON CONFLICT (user_id_token, card_id, cloze_ord, direction)
 		  DO UPDATE SET
 			box = (
 			  CASE
@@ -771,7 +908,14 @@ func NewUpsertUserFlashcardSrsBatchFunc() UpsertUserFlashcardSrsBatchFunc {
 		SELECT 1 FROM upsert;
 		`
 
-		_, err := tx.Exec(ctx, sql, userIdToken, cardIDs, grades)
+		_, err := tx.Exec(ctx, sql, userIdToken, cardIDs, grades, clozeOrds, directions)
 		return err
 	}
 }
+
+func mediaOrEmpty(m []cardmedia.Attachment) []cardmedia.Attachment {
+	if m == nil {
+		return []cardmedia.Attachment{}
+	}
+	return m
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/httputil"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 )
 
 func GetRouter(group fiber.Router,
@@ -13,7 +16,10 @@ func GetRouter(group fiber.Router,
 	redisCMD *redis.UniversalClient,
 	dbPool *pgxpool.Pool,
 	postFunc httputil.HTTPPostRequestFunc,
+	mediaStore blobstore.Store,
 ) {
+	loadMedia := cardmedia.NewCardMediaLoader(dbPool, mediaStore)
+	loadAudio := tts.NewCardAudioLoader(dbPool)
 	examGroup := group.Group("/exam-sessions")
 	examGroup.Post("", NewExamSessionsListHandler(
 		NewListExamHistory(dbPool),
@@ -21,18 +27,18 @@ func GetRouter(group fiber.Router,
 	examGroup.Post("/start", NewStartExamHandler(
 		NewSelectQuestionIds(dbPool),
 		NewInsertStartExamSessions(dbPool),
-		NewGetFlashCardDetailsFromIds(dbPool),
+		NewGetExamQuestionDetails(dbPool, loadMedia, loadAudio),
 	))
 
 	examGroup.Get("/:examId", NewInquiryExamHandler(
-		NewGetExamSession(dbPool),
+		NewGetExamSession(dbPool, loadMedia, loadAudio),
 	))
 
 	examGroup.Put("/answer", NewUpdateExamHandler(
 		NewUpdateExamSession(dbPool),
 	))
 	examGroup.Put("/submit/:examId", NewSubmitHandler(
-		NewGetExamSession(dbPool),
+		NewGetExamSession(dbPool, loadMedia, loadAudio),
 		NewSubMitReviewFunc(
 			dbPool,
 			NewInsertReviewLogsFunc(),
@@ -14,7 +14,7 @@ import (
 func NewStartExamHandler(
 	selectQuestionIds SelectQuestionIdsFunc,
 	insertSession InsertStartExamSessionsFunc,
-	getDetails GetFlashCardDetailsFromIdsFunc,
+	getDetails GetExamQuestionDetailsFunc,
 ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req StartExamRequest
@@ -38,7 +38,8 @@ func NewStartExamHandler(
 		}
 		req.UserId = utils.GetUserID(c)
 		userId := utils.GetUserIDToken(c)
-		if req.SetId != nil && !req.SetId.IsZero() {
+		req.UserIdToken = userId
+		if (req.SetId != nil && !req.SetId.IsZero()) || len(req.Tags) > 0 {
 			questionIDs, err = selectQuestionIds(ctx, logger, req)
 			if err != nil || len(questionIDs) == 0 {
 				logger.Error("no questions ", zap.String("requestId", requestId), zap.Error(err))
@@ -49,15 +50,15 @@ func NewStartExamHandler(
 			//TODO
 		}
 
-		req.QuestionCount = decimal.NewFromInt(int64(len(questionIDs)))
-		// insert session
+		// insert session; cloze notes expand to one question per blank, capped
+		// at the requested count
 		sessionId, err := insertSession(ctx, logger, userId, req, questionIDs)
 		if err != nil {
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 		// TODO to remove it
 		// fetch details
-		questions, err := getDetails(ctx, logger, questionIDs)
+		questions, err := getDetails(ctx, logger, sessionId)
 		if err != nil {
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
@@ -57,6 +57,8 @@ func NewSubmitHandler(
 			}
 			examItems = append(examItems, ExamSubmitItem{
 				CardID:       item.CardID,
+				ClozeOrd:     item.ClozeOrd,
+				Direction:    item.Direction,
 				Source:       utils.EXAM,
 				IsCorrect:    isCorrect,
 				Grade:        int16(grade),
@@ -0,0 +1,97 @@
+package exam_sessions
+
+import (
+	"math/rand"
+
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+)
+
+type examCard struct {
+	id           int64
+	front        string
+	back         string
+	choices      []string
+	cardType     string
+	setDirection string
+}
+
+type examQuestion struct {
+	cardId    int64
+	clozeOrd  int
+	direction string
+	front     string
+	back      string
+	choices   []string
+}
+
+// buildExamQuestions turns the selected cards into questions. A cloze note
+// yields one question per blank: the front shows the sentence with that blank
+// and the back holds the hidden text, which is what MCQ and TYPING answers
+// are graded against. Basic cards of sets studied in both directions also
+// yield a reverse question. The result is shuffled, so directions are mixed,
+// and capped at limit.
+func buildExamQuestions(cards []examCard, questionType string, limit int) []examQuestion {
+	var questions []examQuestion
+	for _, card := range cards {
+		if card.cardType == cloze.CardTypeCloze {
+			for _, ord := range cloze.Ordinals(card.front) {
+				questions = append(questions, examQuestion{
+					cardId:    card.id,
+					clozeOrd:  ord,
+					direction: studyitem.DirectionForward,
+					front:     cloze.Prompt(card.front, ord),
+					back:      cloze.Answer(card.front, ord),
+				})
+			}
+			continue
+		}
+		questions = append(questions, examQuestion{
+			cardId:    card.id,
+			direction: studyitem.DirectionForward,
+			front:     card.front,
+			back:      card.back,
+			choices:   card.choices,
+		})
+		if card.setDirection == studyitem.SetDirectionBoth {
+			questions = append(questions, examQuestion{
+				cardId:    card.id,
+				direction: studyitem.DirectionReverse,
+				front:     card.back,
+				back:      card.front,
+			})
+		}
+	}
+
+	rand.Shuffle(len(questions), func(i, j int) {
+		questions[i], questions[j] = questions[j], questions[i]
+	})
+	if limit > 0 && len(questions) > limit {
+		questions = questions[:limit]
+	}
+
+	if questionType == "MCQ" {
+		var clozePool, frontPool []string
+		for _, card := range cards {
+			if card.cardType == cloze.CardTypeCloze {
+				clozePool = append(clozePool, card.choices...)
+			} else {
+				frontPool = append(frontPool, card.front)
+			}
+		}
+		for _, q := range questions {
+			if q.clozeOrd > 0 {
+				clozePool = append(clozePool, q.back)
+			}
+		}
+		for i := range questions {
+			switch {
+			case questions[i].clozeOrd > 0:
+				questions[i].choices = studyitem.Choices(questions[i].back, clozePool)
+			case questions[i].direction == studyitem.DirectionReverse:
+				questions[i].choices = studyitem.Choices(questions[i].back, frontPool)
+			}
+		}
+	}
+	return questions
+}
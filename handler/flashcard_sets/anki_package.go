@@ -0,0 +1,588 @@
+package flashcard_sets
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	ankiFieldSeparator   = "\x1f"
+	ankiCollectionLegacy = "collection.anki2"
+	ankiCollection21     = "collection.anki21"
+	ankiCollection21b    = "collection.anki21b"
+	ankiMediaManifest    = "media"
+	ankiDefaultFactor    = 2500
+	ankiModelCloze       = 1 // model type of cloze note types
+
+	ErrAnkiPackageTooLarge = "Anki package unpacks to more than the allowed size"
+)
+
+var (
+	reAnkiSound = regexp.MustCompile(`\[sound:([^\]]*)\]`)
+	reAnkiBreak = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	reAnkiTag   = regexp.MustCompile(`<[^>]*>`)
+	reAnkiImg   = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc\s*=\s*["']?([^"'\s>]+)`)
+)
+
+const (
+	AnkiIssueMissingFields = "missingFields"
+	AnkiIssueEmptyField    = "emptyField"
+	AnkiIssueMediaDropped  = "mediaDropped"
+)
+
+// AnkiNoteIssue reports a note that was skipped, or imported without the
+// images and sounds it referenced; media is not stored on import.
+type AnkiNoteIssue struct {
+	Note    int      `json:"note"` // 1-based, in note id order
+	Reason  string   `json:"reason"`
+	Front   string   `json:"front,omitempty"`
+	Media   []string `json:"media,omitempty"`
+	Skipped bool     `json:"skipped"`
+}
+
+// AnkiPackage is the result of reading an .apkg file.
+type AnkiPackage struct {
+	DeckName   string
+	Cards      []InsertFlashCards
+	Skipped    int
+	Issues     []AnkiNoteIssue
+	MediaFiles map[string]string // zip entry name -> original file name
+}
+
+// AnkiExportCard is one flashcard written to an .apkg as a note. Each of its
+// study items becomes an Anki card of that note.
+type AnkiExportCard struct {
+	Id       int64
+	Front    string
+	Back     string
+	CardType string
+	Seq      int
+	Items    []AnkiExportItem
+}
+
+// AnkiExportItem is one study item of a card, with optional SRS state. Basic
+// cards map FORWARD to the first template and REVERSE to the second; cloze
+// ordinal n maps to Anki card ord n-1.
+type AnkiExportItem struct {
+	ClozeOrd     int
+	Direction    string
+	Box          *int
+	NextReviewAt *time.Time
+	TotalReviews int
+}
+
+func (it AnkiExportItem) ankiOrd() int {
+	if it.ClozeOrd > 0 {
+		return it.ClozeOrd - 1
+	}
+	if it.Direction == studyitem.DirectionReverse {
+		return 1
+	}
+	return 0
+}
+
+type AnkiExportDeck struct {
+	Title       string
+	Description string
+	Cards       []AnkiExportCard
+}
+
+// ParseAnkiPackage reads an .apkg archive and maps the first two fields of
+// every note to front/back; notes of a cloze note type become cloze cards. HTML is flattened to plain text and media
+// references are dropped from the text; each note that lost media or was
+// skipped is listed in Issues. At most maxUnpacked bytes are decompressed
+// from the archive, so a small zip cannot expand without bound.
+func ParseAnkiPackage(ctx context.Context, r io.ReaderAt, size, maxUnpacked int64) (*AnkiPackage, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "open apkg zip")
+	}
+
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	colFile := entries[ankiCollection21]
+	if colFile == nil {
+		colFile = entries[ankiCollectionLegacy]
+	}
+	if colFile == nil {
+		if entries[ankiCollection21b] != nil {
+			return nil, errors.New("apkg uses the new Anki format, export with \"Support older Anki versions\" enabled")
+		}
+		return nil, errors.New("apkg does not contain a collection")
+	}
+
+	budget := maxUnpacked
+	pkg := &AnkiPackage{MediaFiles: map[string]string{}}
+	if mf := entries[ankiMediaManifest]; mf != nil {
+		raw, err := readZipFile(mf, &budget)
+		if err != nil {
+			return nil, errors.Wrap(err, "read media manifest")
+		}
+		if len(bytes.TrimSpace(raw)) > 0 {
+			if err := json.Unmarshal(raw, &pkg.MediaFiles); err != nil {
+				return nil, errors.Wrap(err, "parse media manifest")
+			}
+		}
+	}
+
+	raw, err := readZipFile(colFile, &budget)
+	if err != nil {
+		return nil, errors.Wrap(err, "read collection")
+	}
+	path, cleanup, err := writeTempFile("apkg-*.sqlite", raw)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, errors.Wrap(err, "open collection")
+	}
+	defer db.Close()
+
+	pkg.DeckName = readAnkiDeckName(ctx, db)
+	clozeModels := readAnkiClozeModels(ctx, db)
+
+	rows, err := db.QueryContext(ctx, `SELECT mid, flds FROM notes ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "query notes")
+	}
+	defer rows.Close()
+	note := 0
+	for rows.Next() {
+		var (
+			mid  int64
+			flds string
+		)
+		if err := rows.Scan(&mid, &flds); err != nil {
+			return nil, errors.Wrap(err, "scan note")
+		}
+		note++
+		fields := strings.Split(flds, ankiFieldSeparator)
+		if len(fields) < 2 {
+			pkg.Skipped++
+			pkg.Issues = append(pkg.Issues, AnkiNoteIssue{Note: note, Reason: AnkiIssueMissingFields, Skipped: true})
+			continue
+		}
+		front := ankiFieldToText(fields[0])
+		back := ankiFieldToText(fields[1])
+		media := ankiMediaRefs(fields[0] + fields[1])
+		cardType := cloze.CardTypeBasic
+		if clozeModels[mid] {
+			cardType = cloze.CardTypeCloze
+		}
+		// the back of a cloze note is only extra text and may be empty
+		if front == "" || (back == "" && cardType == cloze.CardTypeBasic) ||
+			(cardType == cloze.CardTypeCloze && len(cloze.Ordinals(front)) == 0) {
+			pkg.Skipped++
+			pkg.Issues = append(pkg.Issues, AnkiNoteIssue{
+				Note: note, Reason: AnkiIssueEmptyField, Front: front, Media: media, Skipped: true,
+			})
+			continue
+		}
+		if len(media) > 0 {
+			pkg.Issues = append(pkg.Issues, AnkiNoteIssue{
+				Note: note, Reason: AnkiIssueMediaDropped, Front: front, Media: media,
+			})
+		}
+		pkg.Cards = append(pkg.Cards, InsertFlashCards{Front: front, Back: back, CardType: cardType})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate notes")
+	}
+	return pkg, nil
+}
+
+// readAnkiDeckName returns the name of the deck holding most cards, or "" if
+// it cannot be resolved.
+func readAnkiDeckName(ctx context.Context, db *sql.DB) string {
+	var did int64
+	if err := db.QueryRowContext(ctx,
+		`SELECT did FROM cards GROUP BY did ORDER BY count(*) DESC LIMIT 1`,
+	).Scan(&did); err != nil {
+		return ""
+	}
+	var decksJSON string
+	if err := db.QueryRowContext(ctx, `SELECT decks FROM col LIMIT 1`).Scan(&decksJSON); err != nil {
+		return ""
+	}
+	decks := map[string]struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		return ""
+	}
+	return decks[strconv.FormatInt(did, 10)].Name
+}
+
+// readAnkiClozeModels returns the ids of the cloze note types; notes of any
+// other type are read as basic cards.
+func readAnkiClozeModels(ctx context.Context, db *sql.DB) map[int64]bool {
+	var modelsJSON string
+	if err := db.QueryRowContext(ctx, `SELECT models FROM col LIMIT 1`).Scan(&modelsJSON); err != nil {
+		return nil
+	}
+	models := map[string]struct {
+		Type int `json:"type"`
+	}{}
+	if err := json.Unmarshal([]byte(modelsJSON), &models); err != nil {
+		return nil
+	}
+	ids := map[int64]bool{}
+	for id, m := range models {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && m.Type == ankiModelCloze {
+			ids[n] = true
+		}
+	}
+	return ids
+}
+
+func ankiFieldToText(s string) string {
+	s = reAnkiSound.ReplaceAllString(s, "")
+	s = reAnkiBreak.ReplaceAllString(s, "\n")
+	s = reAnkiTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	return strings.TrimSpace(s)
+}
+
+// ankiMediaRefs lists the file names of the images and sounds a field refers
+// to, in order of appearance.
+func ankiMediaRefs(s string) []string {
+	var refs []string
+	for _, m := range reAnkiImg.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, m[1])
+	}
+	for _, m := range reAnkiSound.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+func textToAnkiField(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+// BuildAnkiPackage writes the deck as a legacy (schema 11) .apkg that every
+// Anki version can import. Basic and cloze cards use their own note types,
+// and study items with SRS state are exported as review cards.
+func BuildAnkiPackage(ctx context.Context, deck AnkiExportDeck, now time.Time) ([]byte, error) {
+	path, cleanup, err := writeTempFile("apkg-*.sqlite", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		return nil, errors.Wrap(err, "open collection")
+	}
+	if err := writeAnkiCollection(ctx, db, deck, now); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, errors.Wrap(err, "close collection")
+	}
+
+	colBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read collection")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{ankiCollectionLegacy, colBytes},
+		{ankiMediaManifest, []byte("{}")},
+	} {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			return nil, errors.Wrap(err, "create zip entry")
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			return nil, errors.Wrap(err, "write zip entry")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "close zip")
+	}
+	return buf.Bytes(), nil
+}
+
+const ankiSchemaSQL = `
+CREATE TABLE col (
+    id integer primary key, crt integer not null, mod integer not null,
+    scm integer not null, ver integer not null, dty integer not null,
+    usn integer not null, ls integer not null, conf text not null,
+    models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+    id integer primary key, guid text not null, mid integer not null,
+    mod integer not null, usn integer not null, tags text not null,
+    flds text not null, sfld integer not null, csum integer not null,
+    flags integer not null, data text not null
+);
+CREATE TABLE cards (
+    id integer primary key, nid integer not null, did integer not null,
+    ord integer not null, mod integer not null, usn integer not null,
+    type integer not null, queue integer not null, due integer not null,
+    ivl integer not null, factor integer not null, reps integer not null,
+    lapses integer not null, left integer not null, odue integer not null,
+    odid integer not null, flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+    id integer primary key, cid integer not null, usn integer not null,
+    ease integer not null, ivl integer not null, lastIvl integer not null,
+    factor integer not null, time integer not null, type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+func writeAnkiCollection(ctx context.Context, db *sql.DB, deck AnkiExportDeck, now time.Time) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin collection tx")
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, ankiSchemaSQL); err != nil {
+		return errors.Wrap(err, "create anki schema")
+	}
+
+	nowMs := now.UnixMilli()
+	nowSec := now.Unix()
+	crtDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	basicModelID := nowMs
+	clozeModelID := nowMs + 1
+	deckID := nowMs + 2
+
+	models, decks, dconf, conf := ankiCollectionJSON(basicModelID, clozeModelID, deckID, deck, nowSec)
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO col VALUES (1,?,?,?,11,0,0,0,?,?,?,?,'{}')`,
+		crtDay.Unix(), nowMs, nowMs, conf, models, decks, dconf,
+	); err != nil {
+		return errors.Wrap(err, "insert col")
+	}
+
+	cardID := nowMs
+	for i, card := range deck.Cards {
+		noteID := nowMs + int64(i)
+		mid := basicModelID
+		if card.CardType == cloze.CardTypeCloze {
+			mid = clozeModelID
+		}
+		front := textToAnkiField(card.Front)
+		back := textToAnkiField(card.Back)
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO notes VALUES (?,?,?,?,-1,'',?,?,?,0,'')`,
+			noteID, ankiGuid(card.Id), mid, nowSec,
+			front+ankiFieldSeparator+back, front, ankiChecksum(card.Front),
+		); err != nil {
+			return errors.Wrap(err, "insert note")
+		}
+
+		for _, item := range card.Items {
+			cardType, queue, due, ivl := 0, 0, int64(card.Seq), 0
+			if item.NextReviewAt != nil && item.Box != nil {
+				cardType, queue = 2, 2
+				due = int64(item.NextReviewAt.Sub(crtDay).Hours() / 24)
+				ivl = boxIntervalDays(*item.Box)
+			}
+			if _, err = tx.ExecContext(ctx,
+				`INSERT INTO cards VALUES (?,?,?,?,?,-1,?,?,?,?,?,?,0,0,0,0,0,'')`,
+				cardID, noteID, deckID, item.ankiOrd(), nowSec, cardType, queue, due, ivl,
+				ankiDefaultFactor, item.TotalReviews,
+			); err != nil {
+				return errors.Wrap(err, "insert card")
+			}
+			cardID++
+		}
+	}
+	return nil
+}
+
+func ankiCollectionJSON(basicModelID, clozeModelID, deckID int64, deck AnkiExportDeck, nowSec int64) (models, decks, dconf, conf string) {
+	newModel := func(id int64, name string, modelType int, fields []string, tmpls []map[string]any) map[string]any {
+		flds := make([]map[string]any, len(fields))
+		for i, f := range fields {
+			flds[i] = map[string]any{"name": f, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+		}
+		req := make([]any, len(tmpls))
+		for i, t := range tmpls {
+			t["ord"] = i
+			t["did"] = nil
+			t["bqfmt"] = ""
+			t["bafmt"] = ""
+			req[i] = []any{i, "any", []int{i}}
+		}
+		return map[string]any{
+			"id": id, "name": name, "type": modelType, "mod": nowSec, "usn": -1,
+			"sortf": 0, "did": deckID, "tags": []string{}, "vers": []int{},
+			"css":       ".card { font-family: arial; font-size: 20px; text-align: center; } .cloze { font-weight: bold; color: blue; }",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"req":       req,
+			"flds":      flds,
+			"tmpls":     tmpls,
+		}
+	}
+	// the reverse template only gets a card for notes that are studied
+	// REVERSE; Anki keeps the cards of an imported note as they are
+	basic := newModel(basicModelID, "Basic (flash-card)", 0, []string{"Front", "Back"}, []map[string]any{
+		{"name": "Card 1", "qfmt": "{{Front}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"},
+		{"name": "Card 2", "qfmt": "{{Back}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Front}}"},
+	})
+	clozeModel := newModel(clozeModelID, "Cloze (flash-card)", ankiModelCloze, []string{"Text", "Back Extra"}, []map[string]any{
+		{"name": "Cloze", "qfmt": "{{cloze:Text}}", "afmt": "{{cloze:Text}}<br>\n{{Back Extra}}"},
+	})
+	newDeck := func(id int64, name, desc string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "desc": desc, "mod": nowSec, "usn": -1,
+			"collapsed": false, "dyn": 0, "conf": 1, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0},
+			"lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	deckOptions := map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60,
+		"autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]any{
+			"delays": []float64{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": ankiDefaultFactor,
+			"order": 1, "perDay": 20, "bury": true, "separate": true,
+		},
+		"rev": map[string]any{
+			"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500,
+			"bury": true, "minSpace": 1,
+		},
+		"lapse": map[string]any{
+			"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+		},
+	}
+	collectionConf := map[string]any{
+		"nextPos": len(deck.Cards), "estTimes": true, "activeDecks": []int64{deckID},
+		"sortType": "noteFld", "timeLim": 0, "sortBackwards": false, "addToCur": true,
+		"curDeck": deckID, "newSpread": 0, "dueCounts": true, "curModel": basicModelID,
+		"collapseTime": 1200,
+	}
+
+	m, _ := json.Marshal(map[string]any{
+		strconv.FormatInt(basicModelID, 10): basic,
+		strconv.FormatInt(clozeModelID, 10): clozeModel,
+	})
+	d, _ := json.Marshal(map[string]any{
+		"1":                           newDeck(1, "Default", ""),
+		strconv.FormatInt(deckID, 10): newDeck(deckID, deck.Title, deck.Description),
+	})
+	dc, _ := json.Marshal(map[string]any{"1": deckOptions})
+	c, _ := json.Marshal(collectionConf)
+	return string(m), string(d), string(dc), string(c)
+}
+
+// ankiGuid is stable per flashcard so re-exports update notes in Anki instead
+// of duplicating them.
+func ankiGuid(cardID int64) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("flash-card:%d", cardID)))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+func ankiChecksum(front string) int64 {
+	sum := sha1.Sum([]byte(front))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+// boxIntervalDays mirrors the leitner intervals used by tbl_user_flashcard_srs.
+func boxIntervalDays(box int) int {
+	switch {
+	case box <= 1:
+		return 1
+	case box == 2:
+		return 2
+	case box == 3:
+		return 4
+	case box == 4:
+		return 8
+	default:
+		return 16
+	}
+}
+
+// readZipFile decompresses f and takes its size from budget. The size in the
+// zip header is checked first but can lie, so reading stops one byte past
+// what is left of the budget either way.
+func readZipFile(f *zip.File, budget *int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(*budget) {
+		return nil, errors.New(ErrAnkiPackageTooLarge)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > *budget {
+		return nil, errors.New(ErrAnkiPackageTooLarge)
+	}
+	*budget -= int64(len(data))
+	return data, nil
+}
+
+func writeTempFile(pattern string, data []byte) (string, func(), error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "create temp file")
+	}
+	cleanup := func() { _ = os.Remove(f.Name()) }
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		cleanup()
+		return "", nil, errors.Wrap(err, "write temp file")
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "close temp file")
+	}
+	return f.Name(), cleanup, nil
+}
@@ -0,0 +1,173 @@
+package flashcard_sets
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+)
+
+// testdata/japanese_verbs.apkg is a legacy Anki export of six notes: two
+// basic notes that import, one of them with an image and a sound, three
+// that are skipped, and a cloze note with an empty Back Extra.
+var fixtureCards = []InsertFlashCards{
+	{Front: "taberu", Back: "to eat\n(ichidan)", CardType: cloze.CardTypeBasic},
+	{Front: "nomu", Back: "to drink", CardType: cloze.CardTypeBasic},
+	{Front: "{{c1::Tokyo}} is the capital of {{c2::Japan}}", CardType: cloze.CardTypeCloze},
+}
+
+var fixtureIssues = []AnkiNoteIssue{
+	{Note: 2, Reason: AnkiIssueMediaDropped, Front: "nomu", Media: []string{"drink.png", "nomu.mp3"}},
+	{Note: 3, Reason: AnkiIssueMissingFields, Skipped: true},
+	{Note: 4, Reason: AnkiIssueEmptyField, Front: "miru", Skipped: true},
+	{Note: 5, Reason: AnkiIssueEmptyField, Media: []string{"only.jpg"}, Skipped: true},
+}
+
+const testMaxUnpacked = 64 << 20
+
+func parseAnki(t *testing.T, raw []byte) *AnkiPackage {
+	t.Helper()
+	pkg, err := ParseAnkiPackage(context.Background(), bytes.NewReader(raw), int64(len(raw)), testMaxUnpacked)
+	if err != nil {
+		t.Fatalf("ParseAnkiPackage: %v", err)
+	}
+	return pkg
+}
+
+func TestParseAnkiPackageFixture(t *testing.T) {
+	raw, err := os.ReadFile("testdata/japanese_verbs.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := parseAnki(t, raw)
+
+	if pkg.DeckName != "Japanese::Verbs" {
+		t.Errorf("deck name = %q, want Japanese::Verbs", pkg.DeckName)
+	}
+	if !reflect.DeepEqual(pkg.Cards, fixtureCards) {
+		t.Errorf("cards = %+v, want %+v", pkg.Cards, fixtureCards)
+	}
+	if pkg.Skipped != 3 {
+		t.Errorf("skipped = %d, want 3", pkg.Skipped)
+	}
+	if !reflect.DeepEqual(pkg.Issues, fixtureIssues) {
+		t.Errorf("issues = %+v, want %+v", pkg.Issues, fixtureIssues)
+	}
+	if len(pkg.MediaFiles) != 2 {
+		t.Errorf("media files = %d, want 2", len(pkg.MediaFiles))
+	}
+}
+
+func TestParseAnkiPackageTooLarge(t *testing.T) {
+	raw, err := os.ReadFile("testdata/japanese_verbs.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseAnkiPackage(context.Background(), bytes.NewReader(raw), int64(len(raw)), 1024)
+	if err == nil || errors.Cause(err).Error() != ErrAnkiPackageTooLarge {
+		t.Errorf("err = %v, want %s", err, ErrAnkiPackageTooLarge)
+	}
+}
+
+func TestAnkiPackageRoundTrip(t *testing.T) {
+	raw, err := os.ReadFile("testdata/japanese_verbs.apkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := parseAnki(t, raw)
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	next := now.AddDate(0, 0, 3)
+	box := 3
+	deck := AnkiExportDeck{Title: "Verbs", Description: "round trip"}
+	for i, c := range imported.Cards {
+		card := AnkiExportCard{Id: int64(i + 1), Front: c.Front, Back: c.Back, CardType: c.CardType, Seq: i}
+		if c.CardType == cloze.CardTypeCloze {
+			for _, ord := range cloze.Ordinals(c.Front) {
+				card.Items = append(card.Items, AnkiExportItem{ClozeOrd: ord, Direction: studyitem.DirectionForward})
+			}
+		} else {
+			card.Items = []AnkiExportItem{
+				{Direction: studyitem.DirectionForward},
+				{Direction: studyitem.DirectionReverse, Box: &box, NextReviewAt: &next, TotalReviews: 4},
+			}
+		}
+		deck.Cards = append(deck.Cards, card)
+	}
+
+	exported, err := BuildAnkiPackage(context.Background(), deck, now)
+	if err != nil {
+		t.Fatalf("BuildAnkiPackage: %v", err)
+	}
+	reimported := parseAnki(t, exported)
+
+	if reimported.DeckName != deck.Title {
+		t.Errorf("deck name = %q, want %q", reimported.DeckName, deck.Title)
+	}
+	if !reflect.DeepEqual(reimported.Cards, fixtureCards) {
+		t.Errorf("cards = %+v, want %+v", reimported.Cards, fixtureCards)
+	}
+	if reimported.Skipped != 0 || len(reimported.Issues) != 0 {
+		t.Errorf("skipped = %d, issues = %+v, want none", reimported.Skipped, reimported.Issues)
+	}
+
+	// one Anki card per study item: two per basic note, one per cloze
+	type ankiCard struct{ ord, queue, ivl int }
+	want := []ankiCard{{0, 0, 0}, {1, 2, 4}, {0, 0, 0}, {1, 2, 4}, {0, 0, 0}, {1, 0, 0}}
+	var got []ankiCard
+	db := openExportedCollection(t, exported)
+	rows, err := db.Query(`SELECT ord, queue, ivl FROM cards ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c ankiCard
+		if err := rows.Scan(&c.ord, &c.queue, &c.ivl); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, c)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("anki cards = %+v, want %+v", got, want)
+	}
+}
+
+func openExportedCollection(t *testing.T, apkg []byte) *sql.DB {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(apkg), int64(len(apkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != ankiCollectionLegacy {
+			continue
+		}
+		budget := int64(testMaxUnpacked)
+		raw, err := readZipFile(f, &budget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, cleanup, err := writeTempFile("apkg-test-*.sqlite", raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(cleanup)
+		db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	t.Fatal("exported package has no collection")
+	return nil
+}
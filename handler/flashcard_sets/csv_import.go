@@ -0,0 +1,400 @@
+package flashcard_sets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/textnorm"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const csvSniffSize = 64 * 1024
+
+var (
+	csvDelimiterCandidates = []rune{',', ';', '\t', '|'}
+
+	csvFrontHeaders   = []string{"front", "term", "word", "question"}
+	csvBackHeaders    = []string{"back", "definition", "meaning", "answer"}
+	csvChoicesHeaders = []string{"choices", "options"}
+	csvTypeHeaders    = []string{"type", "cardtype", "card type", "card_type"}
+)
+
+// CsvColumnMapping holds the column index of each card field, -1 when absent.
+// The type column holds BASIC or CLOZE; an empty cell means BASIC.
+type CsvColumnMapping struct {
+	Front    int `json:"front"`
+	Back     int `json:"back"`
+	Choices  int `json:"choices"`
+	CardType int `json:"cardType"`
+}
+
+func defaultCsvColumnMapping() CsvColumnMapping {
+	return CsvColumnMapping{Front: 0, Back: 1, Choices: 2, CardType: 3}
+}
+
+// CsvCardReader streams CSV records from an upload, one row at a time.
+type CsvCardReader struct {
+	reader    *csv.Reader
+	Delimiter rune
+	Mapping   CsvColumnMapping
+	HasHeader bool
+	row       int
+	pending   []string
+}
+
+// NewCsvCardReader decodes src (UTF-8 with or without BOM, UTF-16 LE/BE),
+// detects the delimiter unless one is given, and resolves the column mapping
+// from the header row. hasHeader is Y, N or empty to auto-detect.
+func NewCsvCardReader(src io.Reader, delimiter string, hasHeader string) (*CsvCardReader, error) {
+	br := bufio.NewReaderSize(decodeCsvSource(src), csvSniffSize)
+
+	r := &CsvCardReader{Mapping: defaultCsvColumnMapping()}
+	if delimiter != "" {
+		r.Delimiter = []rune(delimiter)[0]
+	} else {
+		head, _ := br.Peek(csvSniffSize)
+		r.Delimiter = detectCsvDelimiter(head)
+	}
+
+	r.reader = csv.NewReader(br)
+	r.reader.Comma = r.Delimiter
+	r.reader.TrimLeadingSpace = true
+	r.reader.FieldsPerRecord = -1
+	r.reader.LazyQuotes = true
+
+	first, err := r.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("CSV must have at least one row")
+		}
+		return nil, errors.Wrap(err, "read csv")
+	}
+	r.row = 1
+
+	mapping, isHeader := csvHeaderMapping(first)
+	switch hasHeader {
+	case utils.FlagY:
+		if !isHeader {
+			return nil, errors.New("header row must name front and back columns")
+		}
+		r.HasHeader = true
+	case utils.FlagN:
+		r.HasHeader = false
+	default:
+		r.HasHeader = isHeader
+	}
+	if r.HasHeader {
+		r.Mapping = mapping
+	} else {
+		r.pending = first
+	}
+	return r, nil
+}
+
+// Next returns the next data record and its 1-based row number in the file.
+// It returns io.EOF when the file is exhausted.
+func (r *CsvCardReader) Next() ([]string, int, error) {
+	if r.pending != nil {
+		rec := r.pending
+		r.pending = nil
+		return rec, r.row, nil
+	}
+	rec, err := r.reader.Read()
+	if err != nil {
+		return nil, r.row + 1, err
+	}
+	r.row++
+	return rec, r.row, nil
+}
+
+// Card converts a record using the column mapping. ok is false when the row
+// has no front column, or no back column on a basic card.
+func (r *CsvCardReader) Card(rec []string) (card InsertFlashCards, ok bool) {
+	if r.Mapping.CardType >= 0 && r.Mapping.CardType < len(rec) {
+		card.CardType = strings.TrimSpace(rec[r.Mapping.CardType])
+	}
+	if r.Mapping.Front >= len(rec) {
+		return card, false
+	}
+	card.Front = strings.TrimSpace(rec[r.Mapping.Front])
+	if r.Mapping.Back < len(rec) {
+		card.Back = strings.TrimSpace(rec[r.Mapping.Back])
+	} else if !strings.EqualFold(card.CardType, cloze.CardTypeCloze) {
+		return card, false
+	}
+	if r.Mapping.Choices >= 0 && r.Mapping.Choices < len(rec) {
+		card.Choices = parseCsvChoices(rec[r.Mapping.Choices])
+	}
+	return card, true
+}
+
+func parseCsvChoices(raw string) []string {
+	raw = strings.Trim(strings.TrimSpace(raw), "{}")
+	if raw == "" {
+		return nil
+	}
+	var choices []string
+	for _, ch := range strings.Split(raw, ",") {
+		val := strings.TrimSpace(strings.Trim(strings.TrimSpace(ch), `"'`))
+		if val != "" {
+			choices = append(choices, val)
+		}
+	}
+	return choices
+}
+
+// decodeCsvSource transcodes to UTF-8. A BOM wins; without one, a file whose
+// first bytes are mostly NUL at odd/even offsets is treated as UTF-16.
+func decodeCsvSource(src io.Reader) io.Reader {
+	br := bufio.NewReaderSize(src, 512)
+	head, _ := br.Peek(512)
+
+	fallback := unicode.UTF8.NewDecoder()
+	if !hasUnicodeBOM(head) && len(head) >= 4 {
+		var evenZero, oddZero int
+		for i, b := range head {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenZero++
+			} else {
+				oddZero++
+			}
+		}
+		switch {
+		case oddZero*3 >= len(head) && evenZero == 0:
+			fallback = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+		case evenZero*3 >= len(head) && oddZero == 0:
+			fallback = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+		}
+	}
+	return transform.NewReader(br, unicode.BOMOverride(fallback))
+}
+
+func hasUnicodeBOM(b []byte) bool {
+	return bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}) ||
+		bytes.HasPrefix(b, []byte{0xFF, 0xFE}) ||
+		bytes.HasPrefix(b, []byte{0xFE, 0xFF})
+}
+
+// detectCsvDelimiter picks the candidate that splits the first lines into the
+// most consistent, widest rows. Quoted text is ignored.
+func detectCsvDelimiter(head []byte) rune {
+	lines := strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n")
+	if len(lines) > 1 {
+		// the last line may be cut by the sniff window
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+
+	best, bestScore := ',', 0
+	for _, cand := range csvDelimiterCandidates {
+		score, first := 0, -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := countUnquoted(line, cand)
+			if first < 0 {
+				first = n
+			}
+			if n > 0 && n == first {
+				score += n
+			}
+		}
+		if score > bestScore {
+			best, bestScore = cand, score
+		}
+	}
+	return best
+}
+
+func countUnquoted(line string, delim rune) int {
+	n, quoted := 0, false
+	for _, ch := range line {
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case ch == delim && !quoted:
+			n++
+		}
+	}
+	return n
+}
+
+// csvHeaderMapping reports whether rec looks like a header row naming at
+// least front and back, and the mapping it describes.
+func csvHeaderMapping(rec []string) (CsvColumnMapping, bool) {
+	mapping := CsvColumnMapping{Front: -1, Back: -1, Choices: -1, CardType: -1}
+	for i, cell := range rec {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		switch {
+		case mapping.Front < 0 && containsString(csvFrontHeaders, name):
+			mapping.Front = i
+		case mapping.Back < 0 && containsString(csvBackHeaders, name):
+			mapping.Back = i
+		case mapping.Choices < 0 && containsString(csvChoicesHeaders, name):
+			mapping.Choices = i
+		case mapping.CardType < 0 && containsString(csvTypeHeaders, name):
+			mapping.CardType = i
+		}
+	}
+	return mapping, mapping.Front >= 0 && mapping.Back >= 0
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	maxCardChoices        = 4
+	defaultCsvPreviewSize = 10
+	maxCsvPreviewSize     = 100
+
+	CsvIssueMissingColumns  = "missingColumns"
+	CsvIssueEmptyFront      = "emptyFront"
+	CsvIssueEmptyBack       = "emptyBack"
+	CsvIssueDuplicatedFront = "duplicatedFront"
+	CsvIssueSimilarFront    = "similarFront"
+	CsvIssueTooManyChoices  = "tooManyChoices"
+	CsvIssueInvalidCardType = "invalidCardType"
+	CsvIssueInvalidCloze    = "invalidCloze"
+)
+
+// CsvImportRowIssue describes one problem found on a CSV row. Skipped rows
+// are not imported; the rest are imported as-is and reported as warnings.
+type CsvImportRowIssue struct {
+	Row     int    `json:"row"`
+	Reason  string `json:"reason"`
+	Front   string `json:"front,omitempty"`
+	Skipped bool   `json:"skipped"`
+}
+
+type CsvImportReport struct {
+	DryRun          bool                `json:"dryRun"`
+	Delimiter       string              `json:"delimiter"`
+	HasHeader       bool                `json:"hasHeader"`
+	Mapping         CsvColumnMapping    `json:"mapping"`
+	ImportMode      string              `json:"importMode,omitempty"`
+	TotalRows       int                 `json:"totalRows"`
+	Inserted        int                 `json:"inserted"`
+	Updated         int                 `json:"updated"`
+	Unchanged       int                 `json:"unchanged"`
+	Deleted         int                 `json:"deleted"`
+	Skipped         int                 `json:"skipped"`
+	Warnings        int                 `json:"warnings"`
+	SkippedByReason map[string]int      `json:"skippedByReason"`
+	Issues          []CsvImportRowIssue `json:"issues"`
+	Preview         []InsertFlashCards  `json:"preview,omitempty"`
+	// Duplicates lists imported cards that match cards already in the
+	// caller's sets; Index is the position among the imported cards.
+	Duplicates []DuplicateWarning `json:"duplicates,omitempty"`
+}
+
+func (rep *CsvImportReport) addIssue(row int, reason, front string, skipped bool) {
+	rep.Issues = append(rep.Issues, CsvImportRowIssue{Row: row, Reason: reason, Front: front, Skipped: skipped})
+	if skipped {
+		rep.Skipped++
+		rep.SkippedByReason[reason]++
+	} else {
+		rep.Warnings++
+	}
+}
+
+// CollectCsvCards reads every row, validates it and returns the importable
+// cards together with a row-level report. The first previewSize cards are
+// copied into the report preview. Rows repeating an earlier front are kept
+// and reported, or skipped with skipDuplicates.
+func CollectCsvCards(reader *CsvCardReader, previewSize int, skipDuplicates bool) ([]InsertFlashCards, CsvImportReport, error) {
+	rep := CsvImportReport{
+		Delimiter:       string(reader.Delimiter),
+		HasHeader:       reader.HasHeader,
+		Mapping:         reader.Mapping,
+		SkippedByReason: map[string]int{},
+		Issues:          []CsvImportRowIssue{},
+	}
+	seenFront := map[string]int{}
+	seenSimilar := map[string]int{}
+	var cards []InsertFlashCards
+	for {
+		rec, row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, rep, errors.Wrapf(err, "invalid CSV content at row %d", row)
+		}
+		rep.TotalRows++
+
+		card, ok := reader.Card(rec)
+		cardType, validType := cloze.NormalizeCardType(card.CardType)
+		switch {
+		case !ok:
+			rep.addIssue(row, CsvIssueMissingColumns, "", true)
+			continue
+		case card.Front == "":
+			rep.addIssue(row, CsvIssueEmptyFront, "", true)
+			continue
+		case !validType:
+			rep.addIssue(row, CsvIssueInvalidCardType, card.Front, true)
+			continue
+		case cardType == cloze.CardTypeCloze && len(cloze.Ordinals(card.Front)) == 0:
+			rep.addIssue(row, CsvIssueInvalidCloze, card.Front, true)
+			continue
+		case card.Back == "" && cardType == cloze.CardTypeBasic:
+			rep.addIssue(row, CsvIssueEmptyBack, card.Front, true)
+			continue
+		}
+		card.CardType = cardType
+		// only the same front ignoring case is a duplicate; fronts that
+		// differ only in punctuation or spacing, like "C++" and "C#", are
+		// reported as similar
+		key := frontKey(card.Front)
+		if _, dup := seenFront[key]; dup {
+			rep.addIssue(row, CsvIssueDuplicatedFront, card.Front, skipDuplicates)
+			if skipDuplicates {
+				continue
+			}
+		} else {
+			seenFront[key] = row
+		}
+		if similar := textnorm.Normalize(card.Front); similar != "" {
+			if _, dup := seenSimilar[similar]; dup {
+				rep.addIssue(row, CsvIssueSimilarFront, card.Front, false)
+			} else {
+				seenSimilar[similar] = row
+			}
+		}
+		if len(card.Choices) > maxCardChoices {
+			rep.addIssue(row, CsvIssueTooManyChoices, card.Front, false)
+		}
+
+		cards = append(cards, card)
+		if len(rep.Preview) < previewSize {
+			rep.Preview = append(rep.Preview, card)
+		}
+	}
+	return cards, rep, nil
+}
+
+// frontKey is what fronts are matched by on import, in the file and, for an
+// upsert, against the cards of the set: the front ignoring case and
+// surrounding whitespace.
+func frontKey(front string) string {
+	return strings.ToLower(strings.TrimSpace(front))
+}
@@ -4,11 +4,15 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
 func NewFlashCardCreateHandler(
 	insertFlashCardsFunc InsertFlashCardsFunc,
+	checkDuplicateFrontsFunc CheckDuplicateFrontsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
 ) fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
@@ -28,12 +32,37 @@ func NewFlashCardCreateHandler(
 		}
 		userId := c.Locals("userId").(string)
 		req.UserId = userId
-		err := insertFlashCardsFunc(ctx, logger, req)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		// duplicates are only reported, checked before the insert so the
+		// new cards do not match themselves
+		fronts := make([]string, 0, len(req.Cards))
+		for _, card := range req.Cards {
+			fronts = append(fronts, card.Front)
+		}
+		duplicates, err := checkDuplicateFrontsFunc(ctx, logger, DuplicateCheckRequest{
+			SetId:       req.SetId,
+			Fronts:      fronts,
+			UserIdToken: req.UserIdToken,
+		})
+		if err != nil {
+			logger.Warn("duplicate check failed", zap.String("requestId", requestId), zap.Error(err))
+			duplicates = []DuplicateWarning{}
+		}
+
+		err = insertFlashCardsFunc(ctx, logger, req)
 		if err != nil {
 			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrSetAccessDenied:
+				return api.Forbidden(c)
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
-		return api.Ok(c, nil)
+		queueCardAudioFunc()
+		return api.Ok(c, FlashCardsCreateResponse{Duplicates: duplicates})
 	}
 }
@@ -0,0 +1,68 @@
+package flashcard_sets
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+// NewFlashCardDuplicatesHandler lists duplicate groups across the caller's
+// sets, or only the groups touching ?setId= when given.
+func NewFlashCardDuplicatesHandler(
+	listDuplicateGroupsFunc ListDuplicateGroupsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId := c.QueryInt("setId", 0)
+		if setId < 0 {
+			return api.BadRequest(c, "setId is invalid")
+		}
+
+		res, err := listDuplicateGroupsFunc(ctx, logger, int64(setId), utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewFlashCardDuplicatesMergeHandler(
+	mergeDuplicatesFunc MergeDuplicatesFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req DuplicateMergeRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		res, err := mergeDuplicatesFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard not found")
+			case ErrNotDuplicates:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
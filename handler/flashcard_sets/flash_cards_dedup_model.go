@@ -0,0 +1,88 @@
+package flashcard_sets
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// DuplicateCheckRequest looks for existing cards whose normalized front
+// matches one of Fronts, in SetId and in every set the caller owns.
+type DuplicateCheckRequest struct {
+	SetId       decimal.Decimal
+	Fronts      []string
+	UserIdToken string
+	// SkipSameSet ignores matches inside SetId, for imports that upsert or
+	// replace the cards of the set anyway.
+	SkipSameSet bool
+}
+
+type DuplicateMatch struct {
+	CardId   decimal.Decimal `json:"cardId"`
+	SetId    decimal.Decimal `json:"setId"`
+	SetTitle string          `json:"setTitle"`
+	Front    string          `json:"front"`
+}
+
+// DuplicateWarning flags one incoming card. Index is its position in the
+// request; DuplicateOf points at an earlier card of the same request.
+type DuplicateWarning struct {
+	Index       int              `json:"index"`
+	Front       string           `json:"front"`
+	DuplicateOf *int             `json:"duplicateOf,omitempty"`
+	Matches     []DuplicateMatch `json:"matches"`
+}
+
+type FlashCardsCreateResponse struct {
+	Duplicates []DuplicateWarning `json:"duplicates"`
+}
+
+// DuplicateCard is a card of a duplicate group with the caller's SRS
+// progress on it, summed over blanks and directions.
+type DuplicateCard struct {
+	CardId       decimal.Decimal `json:"cardId"`
+	SetId        decimal.Decimal `json:"setId"`
+	SetTitle     string          `json:"setTitle"`
+	Front        string          `json:"front"`
+	Back         string          `json:"back"`
+	Box          int             `json:"box"`
+	TotalReviews int64           `json:"totalReviews"`
+	LastReviewAt *time.Time      `json:"lastReviewAt"`
+}
+
+type DuplicateGroup struct {
+	NormalizedFront string          `json:"normalizedFront"`
+	Cards           []DuplicateCard `json:"cards"`
+}
+
+// DuplicateMergeRequest folds CardIds into one card. KeepCardId picks the
+// surviving card; when empty the card with the best SRS progress is kept.
+type DuplicateMergeRequest struct {
+	CardIds     []decimal.Decimal `json:"cardIds"`
+	KeepCardId  decimal.Decimal   `json:"keepCardId"`
+	UserIdToken string            // from middleware
+	UserId      string            // from middleware
+}
+
+func (r DuplicateMergeRequest) Validate() error {
+	if len(r.CardIds) < 2 {
+		return errors.New("cardIds needs at least two cards")
+	}
+	seen := map[string]bool{}
+	for _, id := range r.CardIds {
+		if seen[id.String()] {
+			return errors.New("cardIds must not contain duplicates")
+		}
+		seen[id.String()] = true
+	}
+	if !r.KeepCardId.IsZero() && !seen[r.KeepCardId.String()] {
+		return errors.New("keepCardId must be one of cardIds")
+	}
+	return nil
+}
+
+type DuplicateMergeResponse struct {
+	KeptCardId     decimal.Decimal   `json:"keptCardId"`
+	RemovedCardIds []decimal.Decimal `json:"removedCardIds"`
+}
@@ -0,0 +1,367 @@
+package flashcard_sets
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/textnorm"
+	"go.uber.org/zap"
+)
+
+const ErrNotDuplicates = "cards do not share the same front text"
+
+type CheckDuplicateFrontsFunc func(ctx context.Context, logger *zap.Logger, req DuplicateCheckRequest) ([]DuplicateWarning, error)
+
+// NewCheckDuplicateFronts compares fronts after textnorm.Normalize, so
+// "Hello, world!" and "hello world" are reported as the same card.
+// Normalization happens in Go, so the caller's cards are scanned in full.
+// The cards of SetId are only looked at when the caller can edit it; a set
+// they cannot edit is reported as api.NotFound.
+func NewCheckDuplicateFronts(db *pgxpool.Pool) CheckDuplicateFrontsFunc {
+	const cardsSQL = `
+        SELECT f.id, f.set_id, coalesce(s.title, ''), f.front
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+         WHERE f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           AND (s.owner_user_token = $1 OR s.id = $2::int)
+         ORDER BY f.id
+    `
+	return func(ctx context.Context, logger *zap.Logger, req DuplicateCheckRequest) ([]DuplicateWarning, error) {
+		warnings := []DuplicateWarning{}
+		if len(req.Fronts) == 0 {
+			return warnings, nil
+		}
+
+		wanted := make(map[string]bool, len(req.Fronts))
+		for _, front := range req.Fronts {
+			if key := textnorm.Normalize(front); key != "" {
+				wanted[key] = true
+			}
+		}
+
+		var setID *int64
+		if !req.SetId.IsZero() {
+			role, err := setRole(ctx, db, req.SetId.IntPart(), req.UserIdToken)
+			if err != nil {
+				logger.Error("select set role failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if !canEditSet(role) {
+				return nil, errors.New(api.NotFound)
+			}
+			v := req.SetId.IntPart()
+			setID = &v
+		}
+
+		rows, err := db.Query(ctx, cardsSQL, req.UserIdToken, setID)
+		if err != nil {
+			logger.Error("query cards for duplicates failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		existing := map[string][]DuplicateMatch{}
+		for rows.Next() {
+			var (
+				cardID, setID int64
+				m             DuplicateMatch
+			)
+			if err := rows.Scan(&cardID, &setID, &m.SetTitle, &m.Front); err != nil {
+				logger.Error("scan card for duplicates failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if req.SkipSameSet && setID == req.SetId.IntPart() {
+				continue
+			}
+			key := textnorm.Normalize(m.Front)
+			if !wanted[key] {
+				continue
+			}
+			m.CardId = decimal.NewFromInt(cardID)
+			m.SetId = decimal.NewFromInt(setID)
+			existing[key] = append(existing[key], m)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate cards for duplicates failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		firstIndex := map[string]int{}
+		for i, front := range req.Fronts {
+			key := textnorm.Normalize(front)
+			if key == "" {
+				continue
+			}
+			w := DuplicateWarning{Index: i, Front: front, Matches: existing[key]}
+			if first, seen := firstIndex[key]; seen {
+				w.DuplicateOf = &first
+			} else {
+				firstIndex[key] = i
+			}
+			if w.DuplicateOf == nil && len(w.Matches) == 0 {
+				continue
+			}
+			if w.Matches == nil {
+				w.Matches = []DuplicateMatch{}
+			}
+			warnings = append(warnings, w)
+		}
+		return warnings, nil
+	}
+}
+
+type ListDuplicateGroupsFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	userIdToken string,
+) ([]DuplicateGroup, error)
+
+// NewListDuplicateGroups groups the caller's cards by normalized front.
+// With a setID only groups touching that set are returned, but each group
+// still lists its cards in the caller's other sets.
+func NewListDuplicateGroups(db *pgxpool.Pool) ListDuplicateGroupsFunc {
+	const cardsSQL = `
+        SELECT f.id, f.set_id, coalesce(s.title, ''), f.front, f.back,
+               coalesce(max(r.box), 0), coalesce(sum(r.total_reviews), 0), max(r.last_review_at)
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+          LEFT JOIN tbl_user_flashcard_srs r
+                 ON r.card_id = f.id
+                AND r.user_id_token = $1
+         WHERE f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           AND s.owner_user_token = $1
+         GROUP BY f.id, s.title
+         ORDER BY f.id
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		userIdToken string,
+	) ([]DuplicateGroup, error) {
+		rows, err := db.Query(ctx, cardsSQL, userIdToken)
+		if err != nil {
+			logger.Error("query cards for duplicates failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		var order []string
+		byKey := map[string][]DuplicateCard{}
+		for rows.Next() {
+			var (
+				cardID, cardSetID int64
+				box               int
+				d                 DuplicateCard
+			)
+			if err := rows.Scan(
+				&cardID, &cardSetID, &d.SetTitle, &d.Front, &d.Back,
+				&box, &d.TotalReviews, &d.LastReviewAt,
+			); err != nil {
+				logger.Error("scan card for duplicates failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			key := textnorm.Normalize(d.Front)
+			if key == "" {
+				continue
+			}
+			d.CardId = decimal.NewFromInt(cardID)
+			d.SetId = decimal.NewFromInt(cardSetID)
+			d.Box = box
+			if _, ok := byKey[key]; !ok {
+				order = append(order, key)
+			}
+			byKey[key] = append(byKey[key], d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate cards for duplicates failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		groups := []DuplicateGroup{}
+		for _, key := range order {
+			cards := byKey[key]
+			if len(cards) < 2 || (setID > 0 && !duplicateGroupTouches(cards, setID)) {
+				continue
+			}
+			groups = append(groups, DuplicateGroup{NormalizedFront: key, Cards: cards})
+		}
+		return groups, nil
+	}
+}
+
+func duplicateGroupTouches(cards []DuplicateCard, setID int64) bool {
+	for _, c := range cards {
+		if c.SetId.IntPart() == setID {
+			return true
+		}
+	}
+	return false
+}
+
+type MergeDuplicatesFunc func(ctx context.Context, logger *zap.Logger, req DuplicateMergeRequest) (DuplicateMergeResponse, error)
+
+// NewMergeDuplicates keeps one card of a duplicate group and moves the rest
+// to the trash bin. For every learner and every blank/direction the surviving
+// card takes the most advanced SRS state found in the group. The removed
+// cards keep their own SRS state and review log, so restoring one from the
+// trash brings its history back with it.
+func NewMergeDuplicates(db *pgxpool.Pool) MergeDuplicatesFunc {
+	const lockSQL = `
+        SELECT f.id, f.set_id, f.front
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+         WHERE f.id = ANY($1::bigint[])
+           AND f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           AND s.owner_user_token = $2
+         ORDER BY f.id
+           FOR UPDATE OF f
+    `
+	const bestCardSQL = `
+        SELECT c.id
+          FROM unnest($1::bigint[]) AS c(id)
+          LEFT JOIN tbl_user_flashcard_srs r
+                 ON r.card_id = c.id
+                AND r.user_id_token = $2
+         GROUP BY c.id
+         ORDER BY coalesce(max(r.box), 0) DESC,
+                  coalesce(sum(r.total_reviews), 0) DESC,
+                  max(r.last_review_at) DESC NULLS LAST,
+                  c.id
+         LIMIT 1
+    `
+	const mergeSrsSQL = `
+        INSERT INTO tbl_user_flashcard_srs
+            (user_id_token, card_id, cloze_ord, direction, box, next_review_at, last_review_at,
+             streak, total_reviews, last_grade, updated_at)
+        SELECT DISTINCT ON (user_id_token, cloze_ord, direction)
+               user_id_token, $1, cloze_ord, direction, box, next_review_at, last_review_at,
+               streak, total_reviews, last_grade, now()
+          FROM tbl_user_flashcard_srs
+         WHERE card_id = ANY($2::bigint[])
+         ORDER BY user_id_token, cloze_ord, direction,
+                  box DESC NULLS LAST,
+                  total_reviews DESC NULLS LAST,
+                  last_review_at DESC NULLS LAST
+        ON CONFLICT (user_id_token, card_id, cloze_ord, direction)
+        DO UPDATE
+           SET box            = EXCLUDED.box,
+               next_review_at = EXCLUDED.next_review_at,
+               last_review_at = EXCLUDED.last_review_at,
+               streak         = EXCLUDED.streak,
+               total_reviews  = EXCLUDED.total_reviews,
+               last_grade     = EXCLUDED.last_grade,
+               updated_at     = now()
+    `
+	const deleteSQL = `
+        UPDATE tbl_flashcards
+           SET is_deleted = 'Y',
+               deleted_at = now(),
+               update_by  = $2,
+               update_at  = now()
+         WHERE id = ANY($1::bigint[])
+    `
+	return func(ctx context.Context, logger *zap.Logger, req DuplicateMergeRequest) (resp DuplicateMergeResponse, err error) {
+		if err := req.Validate(); err != nil {
+			return resp, err
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		ids := decimalsToInt64s(req.CardIds)
+		rows, err := tx.Query(ctx, lockSQL, ids, req.UserIdToken)
+		if err != nil {
+			logger.Error("lock duplicate cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		setOf := map[int64]int64{}
+		key := ""
+		sameFront := true
+		for rows.Next() {
+			var (
+				cardID, setID int64
+				front         string
+			)
+			if err = rows.Scan(&cardID, &setID, &front); err != nil {
+				rows.Close()
+				logger.Error("scan duplicate card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			setOf[cardID] = setID
+			norm := textnorm.Normalize(front)
+			if len(setOf) == 1 {
+				key = norm
+			} else if norm != key {
+				sameFront = false
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("iterate duplicate cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if len(setOf) != len(ids) {
+			return resp, errors.New(api.NotFound)
+		}
+		if !sameFront || key == "" {
+			return resp, errors.New(ErrNotDuplicates)
+		}
+
+		keep := req.KeepCardId.IntPart()
+		if keep == 0 {
+			if err = tx.QueryRow(ctx, bestCardSQL, ids, req.UserIdToken).Scan(&keep); err != nil {
+				logger.Error("pick surviving card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+		}
+		removed := make([]int64, 0, len(ids)-1)
+		for _, id := range ids {
+			if id != keep {
+				removed = append(removed, id)
+			}
+		}
+
+		if _, err = tx.Exec(ctx, mergeSrsSQL, keep, ids); err != nil {
+			logger.Error("merge srs state failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, deleteSQL, removed, req.UserId); err != nil {
+			logger.Error("delete merged cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		resp.KeptCardId = decimal.NewFromInt(keep)
+		resp.RemovedCardIds = make([]decimal.Decimal, 0, len(removed))
+		for _, id := range removed {
+			resp.RemovedCardIds = append(resp.RemovedCardIds, decimal.NewFromInt(id))
+			if err = recordSetActivity(ctx, tx, setOf[id], &id, ActivityCardMerge,
+				map[string]int64{"keptCardId": keep}, req.UserIdToken, req.UserId,
+			); err != nil {
+				logger.Error("record activity failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+		}
+		return resp, nil
+	}
+}
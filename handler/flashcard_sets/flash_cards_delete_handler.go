@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
@@ -28,9 +29,16 @@ func NewFlashCardsDeleteHandler(
 		}
 		userId := c.Locals("userId").(string)
 		req.UserId = userId
+		req.UserIdToken = utils.GetUserIDToken(c)
 		err := deleteFlashCardsFunc(ctx, logger, req)
 		if err != nil {
 			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard not found")
+			case ErrSetAccessDenied:
+				return api.Forbidden(c)
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
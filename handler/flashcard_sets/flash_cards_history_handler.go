@@ -0,0 +1,67 @@
+package flashcard_sets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewFlashCardHistoryHandler(
+	getFlashCardHistoryFunc GetFlashCardHistoryFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return api.BadRequest(c, "id is required")
+		}
+
+		res, err := getFlashCardHistoryFunc(ctx, logger, id, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewFlashCardRevertHandler(
+	revertFlashCardFunc RevertFlashCardFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsRevertRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		if err := revertFlashCardFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
@@ -1,14 +1,21 @@
 package flashcard_sets
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
 )
 
 type FlashCardsCreateRequest struct {
-	SetId  decimal.Decimal `json:"setId"`
-	UserId string
-	Cards  []InsertFlashCards `json:"flashCards"`
+	SetId       decimal.Decimal `json:"setId"`
+	UserId      string
+	UserIdToken string
+	Cards       []InsertFlashCards `json:"flashCards"`
+	// SharedVia is the EDIT link the cards are added through; it replaces
+	// the caller's role check.
+	SharedVia *ShareLink `json:"-"`
 }
 
 func (r FlashCardsCreateRequest) Validate() error {
@@ -18,22 +25,35 @@ func (r FlashCardsCreateRequest) Validate() error {
 	if len(r.Cards) == 0 {
 		return errors.New("cards is required")
 	}
-	return nil
+	return normalizeCardTypes(r.Cards)
 }
 
 type FlashCardsUpdateRequest struct {
-	Id      decimal.Decimal `json:"id"`
-	Front   *string         `json:"front,omitempty"`
-	Back    *string         `json:"back,omitempty"`
-	Choices *[]string       `json:"choices,omitempty"`
-	Status  *string         `json:"status,omitempty"`
-	UserId  string          // from middleware
+	Id       decimal.Decimal `json:"id"`
+	Front    *string         `json:"front,omitempty"`
+	Back     *string         `json:"back,omitempty"`
+	Choices  *[]string       `json:"choices,omitempty"`
+	Status   *string         `json:"status,omitempty"`
+	CardType *string         `json:"cardType,omitempty"`
+	// Version is the card version the edit is based on; a stale version is
+	// rejected. It can also be sent as an If-Match header.
+	Version     *int   `json:"version,omitempty"`
+	UserId      string // from middleware
+	UserIdToken string // from middleware
+	// SharedVia is the EDIT link the card is edited through; only cards of
+	// its set can be edited and it replaces the caller's role check.
+	SharedVia *ShareLink `json:"-"`
 }
 
 func (r FlashCardsUpdateRequest) Validate() error {
 	if r.Id.IsZero() {
 		return errors.New("id is required")
 	}
+	if r.CardType != nil {
+		if _, ok := cloze.NormalizeCardType(*r.CardType); !ok {
+			return errors.New("cardType must be BASIC or CLOZE")
+		}
+	}
 	if r.Status != nil {
 		if *r.Status != CardStatusStudying && *r.Status != CardStatusLearned &&
 			*r.Status != CardStatusWrongAnswerInTest &&
@@ -47,8 +67,9 @@ func (r FlashCardsUpdateRequest) Validate() error {
 }
 
 type FlashCardsDeleteRequest struct {
-	Id     decimal.Decimal `json:"id"`
-	UserId string          // from middleware
+	Id          decimal.Decimal `json:"id"`
+	UserId      string          // from middleware
+	UserIdToken string          // from middleware
 }
 
 func (r FlashCardsDeleteRequest) Validate() error {
@@ -57,3 +78,119 @@ func (r FlashCardsDeleteRequest) Validate() error {
 	}
 	return nil
 }
+
+// FlashCardsReorderRequest sets the full order of a set; cardIds must list
+// every card of the set exactly once.
+type FlashCardsReorderRequest struct {
+	SetId       decimal.Decimal   `json:"setId"`
+	CardIds     []decimal.Decimal `json:"cardIds"`
+	UserIdToken string            // from middleware
+	UserId      string            // from middleware
+}
+
+func (r FlashCardsReorderRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if len(r.CardIds) == 0 {
+		return errors.New("cardIds is required")
+	}
+	return nil
+}
+
+// FlashCardsRepositionRequest moves one card right before or right after
+// another card of the same set.
+type FlashCardsRepositionRequest struct {
+	Id          decimal.Decimal  `json:"id"`
+	BeforeId    *decimal.Decimal `json:"beforeId,omitempty"`
+	AfterId     *decimal.Decimal `json:"afterId,omitempty"`
+	UserIdToken string           // from middleware
+	UserId      string           // from middleware
+}
+
+func (r FlashCardsRepositionRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	if (r.BeforeId == nil) == (r.AfterId == nil) {
+		return errors.New("exactly one of beforeId or afterId is required")
+	}
+	if (r.BeforeId != nil && r.BeforeId.Equal(r.Id)) || (r.AfterId != nil && r.AfterId.Equal(r.Id)) {
+		return errors.New("card cannot be positioned relative to itself")
+	}
+	return nil
+}
+
+// FlashCardsTransferRequest moves or copies cards to the end of another set,
+// in the order given.
+type FlashCardsTransferRequest struct {
+	CardIds     []decimal.Decimal `json:"cardIds"`
+	TargetSetId decimal.Decimal   `json:"targetSetId"`
+	UserIdToken string            // from middleware
+	UserId      string            // from middleware
+}
+
+func (r FlashCardsTransferRequest) Validate() error {
+	if r.TargetSetId.IsZero() {
+		return errors.New("targetSetId is required")
+	}
+	if len(r.CardIds) == 0 {
+		return errors.New("cardIds is required")
+	}
+	seen := map[string]bool{}
+	for _, id := range r.CardIds {
+		if seen[id.String()] {
+			return errors.New("cardIds must not contain duplicates")
+		}
+		seen[id.String()] = true
+	}
+	return nil
+}
+
+type FlashCardsTransferResponse struct {
+	TargetSetId decimal.Decimal   `json:"targetSetId"`
+	CardIds     []decimal.Decimal `json:"cardIds"`
+}
+
+const (
+	RevisionActionCreate = "CREATE"
+	RevisionActionUpdate = "UPDATE"
+	RevisionActionImport = "IMPORT"
+	RevisionActionRevert = "REVERT"
+	RevisionActionSync   = "SYNC"
+)
+
+type FlashCardRevision struct {
+	Revision      int       `json:"revision"`
+	Action        string    `json:"action"`
+	ChangedFields []string  `json:"changedFields"`
+	RevertedFrom  *int      `json:"revertedFrom,omitempty"`
+	Front         string    `json:"front"`
+	Back          string    `json:"back"`
+	Choices       []string  `json:"choices"`
+	ChangedBy     string    `json:"changedBy"`
+	ChangedAt     time.Time `json:"changedAt"`
+}
+
+type FlashCardHistoryResponse struct {
+	CardId    decimal.Decimal     `json:"cardId"`
+	SetId     decimal.Decimal     `json:"setId"`
+	Revisions []FlashCardRevision `json:"revisions"`
+}
+
+type FlashCardsRevertRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	Revision    decimal.Decimal `json:"revision"`
+	UserIdToken string          // from middleware
+	UserId      string          // from middleware
+}
+
+func (r FlashCardsRevertRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	if r.Revision.LessThan(decimal.NewFromInt(1)) {
+		return errors.New("revision is required")
+	}
+	return nil
+}
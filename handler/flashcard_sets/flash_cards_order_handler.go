@@ -0,0 +1,75 @@
+package flashcard_sets
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewFlashCardsReorderHandler(
+	reorderFlashCardsFunc ReorderFlashCardsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsReorderRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		if err := reorderFlashCardsFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return cardOrderError(c, err)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewFlashCardsRepositionHandler(
+	repositionFlashCardFunc RepositionFlashCardFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsRepositionRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		if err := repositionFlashCardFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return cardOrderError(c, err)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func cardOrderError(c *fiber.Ctx, err error) error {
+	switch err.Error() {
+	case api.NotFound:
+		return api.NotFoundError(c, api.NotFound)
+	case ErrInvalidCardOrder:
+		return api.BadRequest(c, err.Error())
+	}
+	return api.InternalError(c, api.SomeThingWentWrong)
+}
@@ -5,12 +5,26 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
 	"go.uber.org/zap"
 )
 
+const (
+	ErrInvalidCardOrder = "card order does not match the cards of the set"
+	ErrInvalidCloze     = "cloze card front must contain at least one {{c1::...}} marker"
+
+	ErrInvalidStudyDirection = "studyDirection must be FORWARD or BOTH"
+	ErrInvalidLanguage       = "frontLang and backLang must be language tags like th-TH or en-US"
+
+	ErrNotAFork            = "set is not a fork"
+	ErrUpstreamUnavailable = "upstream set is no longer available"
+)
+
 type InsertFlashCardsFunc func(ctx context.Context, logger *zap.Logger, flashCards FlashCardsCreateRequest) error
 
 func NewInsertFlashCards(db *pgxpool.Pool) InsertFlashCardsFunc {
@@ -32,48 +46,46 @@ func NewInsertFlashCards(db *pgxpool.Pool) InsertFlashCardsFunc {
 			}
 		}()
 
-		cards := flashCards.Cards
-		n := len(cards)
-		if n == 0 {
+		if len(flashCards.Cards) == 0 {
 			return nil
 		}
-		var (
-			valueStrings []string
-			valueArgs    []interface{}
-		)
-		for i, card := range cards {
-			finalChoices := card.Choices
-			if len(finalChoices) < 4 {
-				finalChoices = GetChoices(toPointerSlice(cards), &card)
-			}
-
-			off := i * 7
-			valueStrings = append(valueStrings, fmt.Sprintf(
-				"($%d,$%d,$%d,$%d,$%d,$%d,$%d)",
-				off+1, off+2, off+3, off+4, off+5, off+6, off+7,
-			))
-			valueArgs = append(valueArgs,
-				flashCards.SetId,
-				card.Front,
-				card.Back,
-				finalChoices,
-				CardStatusStudying,
-				flashCards.UserId,
-				i,
-			)
+		actorToken := flashCards.UserIdToken
+		if flashCards.SharedVia != nil {
+			actorToken = ""
+		} else {
+			role, err := setRole(ctx, tx, flashCards.SetId, flashCards.UserIdToken)
+			if err != nil {
+				logger.Error("resolve set role failed", zap.Error(err), zap.Any("set_id", flashCards.SetId))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if role == "" {
+				return errors.New(api.NotFound)
+			}
+			if !canEditSet(role) {
+				return errors.New(ErrSetAccessDenied)
+			}
 		}
-
-		sql := fmt.Sprintf(`
-            INSERT INTO tbl_flashcards
-                (set_id, front, back, choices, status, create_by, seq)
-            VALUES %s
-        `, strings.Join(valueStrings, ","))
-
-		if _, err = tx.Exec(ctx, sql, valueArgs...); err != nil {
+		if err = lockFlashCardSetTx(ctx, tx, flashCards.SetId); err != nil {
+			logger.Error("lock flashcard set failed", zap.Error(err), zap.Any("set_id", flashCards.SetId))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		startSeq, err := nextFlashCardSeqTx(ctx, tx, flashCards.SetId)
+		if err != nil {
+			logger.Error("resolve next seq failed", zap.Error(err), zap.Any("set_id", flashCards.SetId))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = insertFlashCardsTx(ctx, tx, flashCards.SetId, flashCards.UserId, flashCards.Cards, startSeq); err != nil {
 			logger.Error("batch insert flashcards failed",
 				zap.Error(err), zap.Any("set_id", flashCards.SetId))
 			return errors.New(api.SomeThingWentWrong)
 		}
+		if err = recordSetActivity(ctx, tx, flashCards.SetId, nil, ActivityCardCreate,
+			map[string]int{"count": len(flashCards.Cards)},
+			actorToken, flashCards.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err), zap.Any("set_id", flashCards.SetId))
+			return errors.New(api.SomeThingWentWrong)
+		}
 
 		return nil
 	}
@@ -81,40 +93,129 @@ func NewInsertFlashCards(db *pgxpool.Pool) InsertFlashCardsFunc {
 
 // --- Update func ---
 
-type UpdateFlashCardsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsUpdateRequest) error
+type UpdateFlashCardsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsUpdateRequest) (version int, err error)
 
+// NewUpdateFlashCards edits a card for the set owner, an editor or an EDIT
+// share link. Content changes bump the card version; an edit based on an
+// older version fails with ErrVersionConflict instead of overwriting.
+// Status is the caller's own study state, so anyone who can see the card may
+// set it without touching the shared card.
 func NewUpdateFlashCards(db *pgxpool.Pool) UpdateFlashCardsFunc {
-	return func(ctx context.Context, logger *zap.Logger, req FlashCardsUpdateRequest) error {
+	const cardSQL = `
+        SELECT f.set_id, f.version, coalesce(s.is_public, 'N')
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+         WHERE f.id = $1
+           AND f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           FOR UPDATE OF f
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsUpdateRequest) (version int, err error) {
 		if err := req.Validate(); err != nil {
-			return err
+			return 0, err
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var (
+			setID    int64
+			isPublic string
+		)
+		if err = tx.QueryRow(ctx, cardSQL, req.Id).Scan(&setID, &version, &isPublic); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("lock flashcard failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		editsCard := req.Front != nil || req.Back != nil || req.Choices != nil || req.CardType != nil
+		actorToken := req.UserIdToken
+		if req.SharedVia != nil {
+			if setID != req.SharedVia.SetId {
+				return 0, errors.New(api.NotFound)
+			}
+			actorToken = ""
+		} else {
+			role, err := setRole(ctx, tx, setID, req.UserIdToken)
+			if err != nil {
+				logger.Error("resolve set role failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			if role == "" && !(isPublic == "Y" && !editsCard) {
+				return 0, errors.New(api.NotFound)
+			}
+			if editsCard && !canEditSet(role) {
+				return 0, errors.New(ErrSetAccessDenied)
+			}
+		}
+		if req.Status != nil {
+			if err = upsertCardStatusTx(ctx, tx, req.UserIdToken, req.Id.IntPart(), *req.Status); err != nil {
+				logger.Error("update card status failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+		}
+		if !editsCard {
+			return version, nil
+		}
+		if req.Version != nil && *req.Version != version {
+			return 0, errors.New(ErrVersionConflict)
+		}
+
+		editsContent := req.Front != nil || req.Back != nil || req.Choices != nil
+		if editsContent {
+			if err = seedFlashCardRevisionTx(ctx, tx, req.Id); err != nil {
+				logger.Error("seed flashcard revision failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
 		}
 
 		var (
 			setClauses []string
+			fields     []string
 			args       []interface{}
 			idx        = 1
 		)
 
 		if req.Front != nil {
 			setClauses = append(setClauses, fmt.Sprintf("front     = $%d", idx))
+			fields = append(fields, "front")
 			args = append(args, *req.Front)
 			idx++
 		}
 		if req.Back != nil {
 			setClauses = append(setClauses, fmt.Sprintf("back      = $%d", idx))
+			fields = append(fields, "back")
 			args = append(args, *req.Back)
 			idx++
 		}
 		if req.Choices != nil {
 			setClauses = append(setClauses, fmt.Sprintf("choices   = $%d", idx))
+			fields = append(fields, "choices")
 			args = append(args, *req.Choices)
 			idx++
 		}
-		if req.Status != nil {
-			setClauses = append(setClauses, fmt.Sprintf("status    = $%d", idx))
-			args = append(args, *req.Status)
+		if req.CardType != nil {
+			cardType, _ := cloze.NormalizeCardType(*req.CardType)
+			setClauses = append(setClauses, fmt.Sprintf("card_type = $%d", idx))
+			fields = append(fields, "cardType")
+			args = append(args, cardType)
 			idx++
 		}
+		setClauses = append(setClauses, "version   = version + 1")
 		// audit fields
 		setClauses = append(setClauses, fmt.Sprintf("update_by = $%d", idx))
 		args = append(args, req.UserId)
@@ -125,36 +226,678 @@ func NewUpdateFlashCards(db *pgxpool.Pool) UpdateFlashCardsFunc {
             UPDATE tbl_flashcards
                SET %s
              WHERE id = $%d
+         RETURNING card_type, coalesce(front, ''), version
         `, strings.Join(setClauses, ",\n                 "), idx)
 		args = append(args, req.Id)
 
-		if _, err := db.Exec(ctx, sql, args...); err != nil {
+		var cardType, front string
+		if err = tx.QueryRow(ctx, sql, args...).Scan(&cardType, &front, &version); err != nil {
 			logger.Error("failed to update flashcard", zap.Error(err))
-			return errors.New(api.SomeThingWentWrong)
+			return 0, errors.New(api.SomeThingWentWrong)
 		}
-		return nil
+		if cardType == cloze.CardTypeCloze && len(cloze.Ordinals(front)) == 0 {
+			return 0, errors.New(ErrInvalidCloze)
+		}
+		if editsContent {
+			if err = appendFlashCardRevisionTx(ctx, tx, req.Id, RevisionActionUpdate, nil, req.UserId); err != nil {
+				logger.Error("record flashcard revision failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+		}
+		cardID := req.Id.IntPart()
+		if err = recordSetActivity(ctx, tx, setID, &cardID, ActivityCardUpdate,
+			map[string]interface{}{"fields": fields, "version": version},
+			actorToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return version, nil
 	}
 }
 
 type DeleteFlashCardsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsDeleteRequest) error
 
 func NewDeleteFlashCards(db *pgxpool.Pool) DeleteFlashCardsFunc {
-	return func(ctx context.Context, logger *zap.Logger, req FlashCardsDeleteRequest) error {
+	const cardSQL = `
+        SELECT set_id
+          FROM tbl_flashcards
+         WHERE id = $1
+           AND is_deleted = 'N'
+           FOR UPDATE
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsDeleteRequest) (err error) {
 		if err := req.Validate(); err != nil {
 			return err
 		}
 
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var setID int64
+		if err = tx.QueryRow(ctx, cardSQL, req.Id).Scan(&setID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("lock flashcard failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		role, err := setRole(ctx, tx, setID, req.UserIdToken)
+		if err != nil {
+			logger.Error("resolve set role failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if role == "" {
+			return errors.New(api.NotFound)
+		}
+		if !canEditSet(role) {
+			return errors.New(ErrSetAccessDenied)
+		}
+
 		const sql = `
             UPDATE tbl_flashcards
                SET is_deleted = 'Y',
+                   deleted_at = now(),
                    update_by  = $1,
                    update_at  = now()
              WHERE id = $2
+               AND is_deleted = 'N'
         `
-		if _, err := db.Exec(ctx, sql, req.UserId, req.Id); err != nil {
+		if _, err = tx.Exec(ctx, sql, req.UserId, req.Id); err != nil {
 			logger.Error("failed to delete flashcard", zap.Error(err))
 			return errors.New(api.SomeThingWentWrong)
 		}
+		cardID := req.Id.IntPart()
+		if err = recordSetActivity(ctx, tx, setID, &cardID, ActivityCardDelete, nil, req.UserIdToken, req.UserId); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+// insertFlashCardsTx batch-inserts cards into setID starting at startSeq.
+// Missing choices are generated from the backs of the same batch.
+func insertFlashCardsTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	setID interface{},
+	userId string,
+	cards []InsertFlashCards,
+	startSeq int,
+) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	var (
+		valueStrings []string
+		valueArgs    []interface{}
+	)
+	for i, card := range cards {
+		finalChoices := card.Choices
+		if len(finalChoices) < 4 {
+			finalChoices = GetChoices(toPointerSlice(cards), &card)
+		}
+
+		off := i * 7
+		valueStrings = append(valueStrings, fmt.Sprintf(
+			"($%d,$%d,$%d,$%d,$%d,$%d,$%d)",
+			off+1, off+2, off+3, off+4, off+5, off+6, off+7,
+		))
+		valueArgs = append(valueArgs,
+			setID,
+			card.Front,
+			card.Back,
+			finalChoices,
+			userId,
+			startSeq+i,
+			cardTypeOrBasic(card.CardType),
+		)
+	}
+
+	sql := fmt.Sprintf(`
+        INSERT INTO tbl_flashcards
+            (set_id, front, back, choices, create_by, seq, card_type)
+        VALUES %s
+    `, strings.Join(valueStrings, ","))
+	_, err := tx.Exec(ctx, sql, valueArgs...)
+	return err
+}
+
+// nextFlashCardSeqTx returns the seq after the current max of the set.
+func nextFlashCardSeqTx(ctx context.Context, tx pgx.Tx, setID interface{}) (int, error) {
+	const sql = `
+        SELECT COALESCE(MAX(seq) + 1, 0)
+          FROM tbl_flashcards
+         WHERE set_id = $1
+           AND is_deleted = 'N'
+    `
+	var next int
+	err := tx.QueryRow(ctx, sql, setID).Scan(&next)
+	return next, err
+}
+
+// lockFlashCardSetTx serialises seq allocation for a set until tx ends.
+func lockFlashCardSetTx(ctx context.Context, tx pgx.Tx, setID interface{}) error {
+	const sql = `
+        SELECT id
+          FROM tbl_flashcard_sets
+         WHERE id = $1
+           FOR UPDATE
+    `
+	var id int64
+	return tx.QueryRow(ctx, sql, setID).Scan(&id)
+}
+
+// lockOwnedFlashCardSetTx locks a live set owned by ownerIdToken and reports
+// whether it exists.
+func lockOwnedFlashCardSetTx(ctx context.Context, tx pgx.Tx, setID interface{}, ownerIdToken string) (bool, error) {
+	const sql = `
+        SELECT id
+          FROM tbl_flashcard_sets
+         WHERE id = $1
+           AND owner_user_token = $2
+           AND is_deleted = 'N'
+           FOR UPDATE
+    `
+	var id int64
+	err := tx.QueryRow(ctx, sql, setID, ownerIdToken).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lockFlashCardSetsTx locks the live sets among setIDs in id order and
+// returns their ids.
+func lockFlashCardSetsTx(ctx context.Context, tx pgx.Tx, setIDs []int64) ([]int64, error) {
+	const sql = `
+        SELECT id
+          FROM tbl_flashcard_sets
+         WHERE id = ANY($1::bigint[])
+           AND is_deleted = 'N'
+         ORDER BY id
+           FOR UPDATE
+    `
+	rows, err := tx.Query(ctx, sql, setIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locked []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		locked = append(locked, id)
+	}
+	return locked, rows.Err()
+}
+
+// lockEditableFlashCardSetTx locks a live set the caller owns or is an
+// accepted editor of and reports whether it exists.
+func lockEditableFlashCardSetTx(ctx context.Context, tx pgx.Tx, setID interface{}, userIdToken string) (bool, error) {
+	if err := lockFlashCardSetTx(ctx, tx, setID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	role, err := setRole(ctx, tx, setID, userIdToken)
+	if err != nil {
+		return false, err
+	}
+	return canEditSet(role), nil
+}
+
+// orderedFlashCardIdsTx lists the live cards of a set in display order.
+func orderedFlashCardIdsTx(ctx context.Context, tx pgx.Tx, setID interface{}) ([]int64, error) {
+	const sql = `
+        SELECT id
+          FROM tbl_flashcards
+         WHERE set_id = $1
+           AND is_deleted = 'N'
+         ORDER BY seq, id
+    `
+	rows, err := tx.Query(ctx, sql, setID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// rewriteFlashCardSeqTx renumbers the given cards of a set 0..n-1.
+func rewriteFlashCardSeqTx(ctx context.Context, tx pgx.Tx, setID interface{}, ids []int64, userId string) error {
+	const sql = `
+        UPDATE tbl_flashcards f
+           SET seq       = o.ord - 1,
+               update_by = $3,
+               update_at = now()
+          FROM unnest($2::bigint[]) WITH ORDINALITY AS o(id, ord)
+         WHERE f.id = o.id
+           AND f.set_id = $1
+           AND f.seq IS DISTINCT FROM o.ord - 1
+    `
+	_, err := tx.Exec(ctx, sql, setID, ids, userId)
+	return err
+}
+
+// moveFlashCardsTx re-parents cards onto a set, numbering them from startSeq
+// in the order given.
+func moveFlashCardsTx(ctx context.Context, tx pgx.Tx, setID interface{}, ids []int64, startSeq int, userId string) error {
+	const sql = `
+        UPDATE tbl_flashcards f
+           SET set_id    = $1,
+               seq       = $2 + o.ord - 1,
+               update_by = $4,
+               update_at = now()
+          FROM unnest($3::bigint[]) WITH ORDINALITY AS o(id, ord)
+         WHERE f.id = o.id
+    `
+	_, err := tx.Exec(ctx, sql, setID, startSeq, ids, userId)
+	return err
+}
+
+func decimalsToInt64s(in []decimal.Decimal) []int64 {
+	out := make([]int64, 0, len(in))
+	for _, d := range in {
+		out = append(out, d.IntPart())
+	}
+	return out
+}
+
+type ReorderFlashCardsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsReorderRequest) error
+
+func NewReorderFlashCards(db *pgxpool.Pool) ReorderFlashCardsFunc {
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsReorderRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		editable, err := lockEditableFlashCardSetTx(ctx, tx, req.SetId, req.UserIdToken)
+		if err != nil {
+			logger.Error("lock flashcard set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if !editable {
+			return errors.New(api.NotFound)
+		}
+
+		current, err := orderedFlashCardIdsTx(ctx, tx, req.SetId)
+		if err != nil {
+			logger.Error("load card order failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		ids := decimalsToInt64s(req.CardIds)
+		if !sameInt64Members(current, ids) {
+			return errors.New(ErrInvalidCardOrder)
+		}
+
+		if err = rewriteFlashCardSeqTx(ctx, tx, req.SetId, ids, req.UserId); err != nil {
+			logger.Error("rewrite card seq failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type RepositionFlashCardFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsRepositionRequest) error
+
+func NewRepositionFlashCard(db *pgxpool.Pool) RepositionFlashCardFunc {
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsRepositionRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		const setSQL = `
+            SELECT f.set_id
+              FROM tbl_flashcards f
+             WHERE f.id = $1
+               AND f.is_deleted = 'N'
+        `
+		var setID int64
+		if err = tx.QueryRow(ctx, setSQL, req.Id).Scan(&setID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("load card set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		editable, err := lockEditableFlashCardSetTx(ctx, tx, setID, req.UserIdToken)
+		if err != nil {
+			logger.Error("lock flashcard set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if !editable {
+			return errors.New(api.NotFound)
+		}
+
+		current, err := orderedFlashCardIdsTx(ctx, tx, setID)
+		if err != nil {
+			logger.Error("load card order failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+
+		cardID := req.Id.IntPart()
+		anchor, after := int64(0), false
+		if req.BeforeId != nil {
+			anchor = req.BeforeId.IntPart()
+		} else {
+			anchor, after = req.AfterId.IntPart(), true
+		}
+
+		ids := make([]int64, 0, len(current))
+		placed := false
+		for _, id := range current {
+			if id == cardID {
+				continue
+			}
+			if id == anchor && !after {
+				ids = append(ids, cardID)
+				placed = true
+			}
+			ids = append(ids, id)
+			if id == anchor && after {
+				ids = append(ids, cardID)
+				placed = true
+			}
+		}
+		if !placed {
+			return errors.New(ErrInvalidCardOrder)
+		}
+
+		if err = rewriteFlashCardSeqTx(ctx, tx, setID, ids, req.UserId); err != nil {
+			logger.Error("rewrite card seq failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
 		return nil
 	}
 }
+
+type TransferFlashCardsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsTransferRequest) ([]int64, error)
+
+// NewMoveFlashCards re-parents cards from sets the caller can edit onto the
+// end of another set they can edit. Card ids are kept, so every user's SRS state and review log
+// follow the card; practice sessions of the old set skip it from now on.
+func NewMoveFlashCards(db *pgxpool.Pool) TransferFlashCardsFunc {
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsTransferRequest) (ids []int64, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		ids = decimalsToInt64s(req.CardIds)
+		const sourceSQL = `
+            SELECT DISTINCT set_id
+              FROM tbl_flashcards
+             WHERE id = ANY($1::bigint[])
+               AND is_deleted = 'N'
+        `
+		setIDs := []int64{req.TargetSetId.IntPart()}
+		rows, err := tx.Query(ctx, sourceSQL, ids)
+		if err != nil {
+			logger.Error("load source sets failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				logger.Error("scan source set failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			setIDs = append(setIDs, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("load source sets failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		// the source and target sets are locked in id order, so two moves
+		// between the same sets in opposite directions cannot deadlock
+		locked, err := lockFlashCardSetsTx(ctx, tx, setIDs)
+		if err != nil {
+			logger.Error("lock flashcard sets failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for _, setID := range locked {
+			role, err := setRole(ctx, tx, setID, req.UserIdToken)
+			if err != nil {
+				logger.Error("resolve set role failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if !canEditSet(role) {
+				return nil, errors.New(api.NotFound)
+			}
+		}
+
+		// a card moved elsewhere before the locks were taken is not in any
+		// locked set any more
+		const inLockedSQL = `
+            SELECT count(*)
+              FROM tbl_flashcards
+             WHERE id = ANY($1::bigint[])
+               AND is_deleted = 'N'
+               AND set_id = ANY($2::bigint[])
+        `
+		var n int
+		if err = tx.QueryRow(ctx, inLockedSQL, ids, locked).Scan(&n); err != nil {
+			logger.Error("check moved cards failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		if n != len(ids) || !containsInt64(locked, req.TargetSetId.IntPart()) {
+			return nil, errors.New(api.NotFound)
+		}
+
+		startSeq, err := nextFlashCardSeqTx(ctx, tx, req.TargetSetId)
+		if err != nil {
+			logger.Error("resolve next seq failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		if err = moveFlashCardsTx(ctx, tx, req.TargetSetId, ids, startSeq, req.UserId); err != nil {
+			logger.Error("move flashcards failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return ids, nil
+	}
+}
+
+// copyCardMediaSQL links the media of card $2 to its copy $1. Both rows point
+// at the same blob, which is only removed once no row is left using it.
+const copyCardMediaSQL = `
+    INSERT INTO tbl_flashcard_media
+        (card_id, side, kind, content_type, size_bytes, storage_key, original_name, create_by)
+    SELECT $1, side, kind, content_type, size_bytes, storage_key, original_name, $3
+      FROM tbl_flashcard_media
+     WHERE card_id = $2
+     ORDER BY id
+`
+
+// NewCopyFlashCards appends copies of cards the caller can see (own, shared
+// with them or public sets) to the end of a set they can edit. The copies
+// link the same media blobs. The caller's SRS state is carried over to each
+// copy; other users keep studying the originals.
+func NewCopyFlashCards(db *pgxpool.Pool) TransferFlashCardsFunc {
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsTransferRequest) (ids []int64, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		editable, err := lockEditableFlashCardSetTx(ctx, tx, req.TargetSetId, req.UserIdToken)
+		if err != nil {
+			logger.Error("lock target set failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		if !editable {
+			return nil, errors.New(api.NotFound)
+		}
+
+		startSeq, err := nextFlashCardSeqTx(ctx, tx, req.TargetSetId)
+		if err != nil {
+			logger.Error("resolve next seq failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		const copySQL = `
+            INSERT INTO tbl_flashcards
+                (set_id, front, back, choices, create_by, seq, card_type)
+            SELECT $1, f.front, f.back, f.choices, $2, $3, f.card_type
+              FROM tbl_flashcards f
+              JOIN tbl_flashcard_sets s ON s.id = f.set_id
+             WHERE f.id = $4
+               AND f.is_deleted = 'N'
+               AND s.is_deleted = 'N'
+               AND (s.owner_user_token = $5
+                    OR s.is_public = 'Y'
+                    OR EXISTS (SELECT 1
+                                 FROM tbl_flashcard_set_collaborators c
+                                WHERE c.set_id = s.id
+                                  AND c.user_id_token = $5
+                                  AND c.status = 'ACCEPTED'))
+            RETURNING id
+        `
+		const srsSQL = `
+            INSERT INTO tbl_user_flashcard_srs
+                (user_id_token, card_id, cloze_ord, direction, box, next_review_at, last_review_at,
+                 streak, total_reviews, last_grade, updated_at)
+            SELECT user_id_token, $1, cloze_ord, direction, box, next_review_at, last_review_at,
+                   streak, total_reviews, last_grade, now()
+              FROM tbl_user_flashcard_srs
+             WHERE card_id = $2
+               AND user_id_token = $3
+        `
+		const statusSQL = `
+            INSERT INTO tbl_user_flashcard_status (user_id_token, card_id, status)
+            SELECT user_id_token, $1, status
+              FROM tbl_user_flashcard_status
+             WHERE card_id = $2
+               AND user_id_token = $3
+        `
+		for i, src := range decimalsToInt64s(req.CardIds) {
+			var newID int64
+			if err = tx.QueryRow(ctx, copySQL,
+				req.TargetSetId, req.UserId, startSeq+i, src, req.UserIdToken,
+			).Scan(&newID); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return nil, errors.New(api.NotFound)
+				}
+				logger.Error("copy flashcard failed", zap.Error(err), zap.Int64("card_id", src))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, copyCardMediaSQL, newID, src, req.UserId); err != nil {
+				logger.Error("copy card media failed", zap.Error(err), zap.Int64("card_id", src))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, srsSQL, newID, src, req.UserIdToken); err != nil {
+				logger.Error("copy srs state failed", zap.Error(err), zap.Int64("card_id", src))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, statusSQL, newID, src, req.UserIdToken); err != nil {
+				logger.Error("copy card status failed", zap.Error(err), zap.Int64("card_id", src))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			ids = append(ids, newID)
+		}
+		return ids, nil
+	}
+}
+
+func containsInt64(list []int64, v int64) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sameInt64Members(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[int64]int, len(a))
+	for _, v := range a {
+		seen[v]++
+	}
+	for _, v := range b {
+		if seen[v] == 0 {
+			return false
+		}
+		seen[v]--
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package flashcard_sets
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+// seedFlashCardRevisionTx locks the card and, when it has no history yet,
+// records its current content as revision 1. Call it before changing front,
+// back or choices so the original version is never lost.
+func seedFlashCardRevisionTx(ctx context.Context, tx pgx.Tx, cardID interface{}) error {
+	const lockSQL = `
+        SELECT id
+          FROM tbl_flashcards
+         WHERE id = $1
+           FOR UPDATE
+    `
+	const seedSQL = `
+        INSERT INTO tbl_flashcard_revisions
+            (card_id, revision, front, back, choices, action, create_at, create_by)
+        SELECT f.id, 1, f.front, f.back, f.choices, $2, f.create_at, f.create_by
+          FROM tbl_flashcards f
+         WHERE f.id = $1
+           AND NOT EXISTS (
+                SELECT 1
+                  FROM tbl_flashcard_revisions r
+                 WHERE r.card_id = f.id
+           )
+    `
+	var id int64
+	if err := tx.QueryRow(ctx, lockSQL, cardID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	_, err := tx.Exec(ctx, seedSQL, cardID, RevisionActionCreate)
+	return err
+}
+
+// appendFlashCardRevisionTx snapshots the card's current content as the next
+// revision. Nothing is written when the content equals the latest revision.
+func appendFlashCardRevisionTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	cardID interface{},
+	action string,
+	revertedFrom *int,
+	userId string,
+) error {
+	const sql = `
+        INSERT INTO tbl_flashcard_revisions
+            (card_id, revision, front, back, choices, action, changed_fields, reverted_from, create_by)
+        SELECT f.id, p.revision + 1, f.front, f.back, f.choices, $2,
+               array_remove(ARRAY[
+                   CASE WHEN f.front IS DISTINCT FROM p.front THEN 'front' END,
+                   CASE WHEN f.back IS DISTINCT FROM p.back THEN 'back' END,
+                   CASE WHEN f.choices IS DISTINCT FROM p.choices THEN 'choices' END
+               ], NULL),
+               $3, $4
+          FROM tbl_flashcards f
+          JOIN LATERAL (
+                SELECT r.revision, r.front, r.back, r.choices
+                  FROM tbl_flashcard_revisions r
+                 WHERE r.card_id = f.id
+                 ORDER BY r.revision DESC
+                 LIMIT 1
+          ) p ON true
+         WHERE f.id = $1
+           AND (f.front IS DISTINCT FROM p.front
+                OR f.back IS DISTINCT FROM p.back
+                OR f.choices IS DISTINCT FROM p.choices)
+    `
+	_, err := tx.Exec(ctx, sql, cardID, action, revertedFrom, userId)
+	return err
+}
+
+type GetFlashCardHistoryFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	cardID int64,
+	userIdToken string,
+) (FlashCardHistoryResponse, error)
+
+// NewGetFlashCardHistory lists revisions newest first for cards in sets the
+// caller owns or that are public. A card never edited has a single synthetic
+// revision built from its current content.
+func NewGetFlashCardHistory(db *pgxpool.Pool) GetFlashCardHistoryFunc {
+	const cardSQL = `
+        SELECT f.set_id, f.front, f.back, f.choices, f.create_at, coalesce(f.create_by, '')
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+         WHERE f.id = $1
+           AND f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           AND (s.owner_user_token = $2
+                OR s.is_public = 'Y'
+                OR EXISTS (SELECT 1
+                             FROM tbl_flashcard_set_collaborators c
+                            WHERE c.set_id = s.id
+                              AND c.user_id_token = $2
+                              AND c.status = 'ACCEPTED'))
+    `
+	const historySQL = `
+        SELECT revision, action, changed_fields, reverted_from,
+               coalesce(front, ''), coalesce(back, ''), choices,
+               create_at, coalesce(create_by, '')
+          FROM tbl_flashcard_revisions
+         WHERE card_id = $1
+         ORDER BY revision DESC
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		cardID int64,
+		userIdToken string,
+	) (FlashCardHistoryResponse, error) {
+		resp := FlashCardHistoryResponse{CardId: decimal.NewFromInt(cardID)}
+
+		var (
+			setID   int64
+			current FlashCardRevision
+		)
+		if err := db.QueryRow(ctx, cardSQL, cardID, userIdToken).Scan(
+			&setID, &current.Front, &current.Back, &current.Choices, &current.ChangedAt, &current.ChangedBy,
+		); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return resp, errors.New(api.NotFound)
+			}
+			logger.Error("load flashcard failed", zap.Error(err), zap.Int64("card_id", cardID))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		resp.SetId = decimal.NewFromInt(setID)
+
+		rows, err := db.Query(ctx, historySQL, cardID)
+		if err != nil {
+			logger.Error("load flashcard history failed", zap.Error(err), zap.Int64("card_id", cardID))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		resp.Revisions = []FlashCardRevision{}
+		for rows.Next() {
+			var rev FlashCardRevision
+			if err := rows.Scan(
+				&rev.Revision, &rev.Action, &rev.ChangedFields, &rev.RevertedFrom,
+				&rev.Front, &rev.Back, &rev.Choices, &rev.ChangedAt, &rev.ChangedBy,
+			); err != nil {
+				logger.Error("scan flashcard revision failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			resp.Revisions = append(resp.Revisions, rev)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate flashcard history failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		if len(resp.Revisions) == 0 {
+			current.Revision = 1
+			current.Action = RevisionActionCreate
+			current.ChangedFields = []string{}
+			resp.Revisions = append(resp.Revisions, current)
+		}
+		return resp, nil
+	}
+}
+
+type RevertFlashCardFunc func(ctx context.Context, logger *zap.Logger, req FlashCardsRevertRequest) error
+
+// NewRevertFlashCard restores front, back and choices from a revision of a
+// card in a set the caller owns or edits. The revert is itself recorded as
+// a new revision, so it can be undone the same way.
+func NewRevertFlashCard(db *pgxpool.Pool) RevertFlashCardFunc {
+	const ownerSQL = `
+        SELECT f.set_id
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+         WHERE f.id = $1
+           AND f.is_deleted = 'N'
+           AND s.is_deleted = 'N'
+           AND (s.owner_user_token = $2
+                OR EXISTS (SELECT 1
+                             FROM tbl_flashcard_set_collaborators c
+                            WHERE c.set_id = s.id
+                              AND c.user_id_token = $2
+                              AND c.role = 'EDITOR'
+                              AND c.status = 'ACCEPTED'))
+           FOR UPDATE OF f
+    `
+	const revertSQL = `
+        UPDATE tbl_flashcards f
+           SET front     = r.front,
+               back      = r.back,
+               choices   = r.choices,
+               update_by = $3,
+               update_at = now(),
+               version   = f.version + 1
+          FROM tbl_flashcard_revisions r
+         WHERE f.id = $1
+           AND r.card_id = f.id
+           AND r.revision = $2
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardsRevertRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var setID int64
+		if err = tx.QueryRow(ctx, ownerSQL, req.Id, req.UserIdToken).Scan(&setID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("check card owner failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = seedFlashCardRevisionTx(ctx, tx, req.Id); err != nil {
+			logger.Error("seed flashcard revision failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+
+		revision := int(req.Revision.IntPart())
+		tag, err := tx.Exec(ctx, revertSQL, req.Id, revision, req.UserId)
+		if err != nil {
+			logger.Error("revert flashcard failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		if err = appendFlashCardRevisionTx(ctx, tx, req.Id, RevisionActionRevert, &revision, req.UserId); err != nil {
+			logger.Error("record flashcard revision failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		cardID := req.Id.IntPart()
+		if err = recordSetActivity(ctx, tx, setID, &cardID, ActivityCardRevert,
+			map[string]int{"revision": revision}, req.UserIdToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
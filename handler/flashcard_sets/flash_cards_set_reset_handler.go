@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
@@ -26,7 +27,8 @@ func NewResetStatusHandler(
 		}
 
 		if err := resetFunc(ctx, logger, ResetFlashCardStatusRequest{
-			SetID: body.SetID,
+			SetID:       body.SetID,
+			UserIdToken: utils.GetUserIDToken(c),
 		}); err != nil {
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
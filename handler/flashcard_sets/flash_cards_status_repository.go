@@ -0,0 +1,18 @@
+package flashcard_sets
+
+import (
+	"context"
+)
+
+// upsertCardStatusTx stores the caller's study status of a card.
+func upsertCardStatusTx(ctx context.Context, q querier, userIdToken string, cardID int64, status string) error {
+	const sql = `
+        INSERT INTO tbl_user_flashcard_status (user_id_token, card_id, status)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id_token, card_id)
+        DO UPDATE SET status    = EXCLUDED.status,
+                      update_at = now()
+    `
+	_, err := q.Exec(ctx, sql, userIdToken, cardID, status)
+	return err
+}
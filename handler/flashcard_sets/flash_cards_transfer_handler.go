@@ -2,19 +2,21 @@ package flashcard_sets
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
-func NewDuplicateFlashCardsSetHandler(
-	duplicateFlashCardsSetFunc DuplicateFlashCardsSetFunc,
+// NewFlashCardsTransferHandler serves both move and copy; the response lists
+// the card ids as they now exist in the target set.
+func NewFlashCardsTransferHandler(
+	transferFlashCardsFunc TransferFlashCardsFunc,
 ) fiber.Handler {
-
 	return func(c *fiber.Ctx) error {
-		var (
-			req DuplicateFlashCardsSetRequest
-		)
+		var req FlashCardsTransferRequest
+
 		ctx := c.Context()
 		logger := logz.NewLogger()
 		requestId := c.Get("requestId")
@@ -26,17 +28,25 @@ func NewDuplicateFlashCardsSetHandler(
 			logger.Error(err.Error(), zap.String("requestId", requestId))
 			return api.BadRequest(c, err.Error())
 		}
-		userIdToken := c.Locals("userIdToken").(string)
-		userId := c.Locals("userId").(string)
-		req.UserId = userId
-	
-		req.OwnerIdToken = userIdToken
-		newSetId, err := duplicateFlashCardsSetFunc(ctx, logger, req)
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		ids, err := transferFlashCardsFunc(ctx, logger, req)
 		if err != nil {
 			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
-		return api.Ok(c, fiber.Map{"newSetId": newSetId})
+		resp := FlashCardsTransferResponse{
+			TargetSetId: req.TargetSetId,
+			CardIds:     make([]decimal.Decimal, 0, len(ids)),
+		}
+		for _, id := range ids {
+			resp.CardIds = append(resp.CardIds, decimal.NewFromInt(id))
+		}
+		return api.Ok(c, resp)
 	}
 }
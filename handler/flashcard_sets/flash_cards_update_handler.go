@@ -4,11 +4,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
 func NewFlashCardsUpdateHandler(
 	updateFlashCardsFunc UpdateFlashCardsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
 ) fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
@@ -26,14 +29,36 @@ func NewFlashCardsUpdateHandler(
 			logger.Error(err.Error(), zap.String("requestId", requestId))
 			return api.BadRequest(c, err.Error())
 		}
+		if req.Version == nil {
+			version, ok, err := ifMatchVersion(c)
+			if err != nil {
+				return api.BadRequest(c, err.Error())
+			}
+			if ok {
+				req.Version = &version
+			}
+		}
 		userId := c.Locals("userId").(string)
 		req.UserId = userId
-		err := updateFlashCardsFunc(ctx, logger, req)
+		req.UserIdToken = utils.GetUserIDToken(c)
+		version, err := updateFlashCardsFunc(ctx, logger, req)
 		if err != nil {
 			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard not found")
+			case ErrInvalidCloze:
+				return api.BadRequest(c, err.Error())
+			case ErrSetAccessDenied:
+				return api.Forbidden(c)
+			case ErrVersionConflict:
+				return api.Conflict(c, err.Error())
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
-		return api.Ok(c, nil)
+		queueCardAudioFunc()
+		setETag(c, version)
+		return api.Ok(c, fiber.Map{"version": version})
 	}
 }
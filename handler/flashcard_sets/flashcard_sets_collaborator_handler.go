@@ -0,0 +1,182 @@
+package flashcard_sets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewCollaboratorInviteHandler(
+	inviteCollaboratorFunc InviteCollaboratorFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CollaboratorInviteRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.OwnerToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		if err := inviteCollaboratorFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrInviteOwner:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewCollaboratorRespondHandler(
+	respondCollaboratorFunc RespondCollaboratorFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CollaboratorRespondRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		if err := respondCollaboratorFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, "invitation not found")
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewCollaboratorRemoveHandler(
+	removeCollaboratorFunc RemoveCollaboratorFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CollaboratorRemoveRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.CallerToken = utils.GetUserIDToken(c)
+		req.CallerUserId = utils.GetUserID(c)
+
+		if err := removeCollaboratorFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+func NewCollaboratorListHandler(
+	listCollaboratorsFunc ListCollaboratorsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+
+		res, err := listCollaboratorsFunc(ctx, logger, setId, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewInvitationListHandler(
+	listInvitationsFunc ListInvitationsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		res, err := listInvitationsFunc(ctx, logger, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewSetActivityHandler(
+	listSetActivityFunc ListSetActivityFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+		page := c.QueryInt("page", 1)
+		size := c.QueryInt("size", 20)
+		if page < 1 {
+			return api.BadRequest(c, "page must be at least 1")
+		}
+		if size < 1 || size > 100 {
+			return api.BadRequest(c, "size must be between 1 and 100")
+		}
+
+		res, err := listSetActivityFunc(ctx, logger, setId, page, size, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
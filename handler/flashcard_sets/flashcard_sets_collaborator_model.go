@@ -0,0 +1,129 @@
+package flashcard_sets
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+)
+
+// Roles on a set. OWNER is implied by owner_user_token; the others come from
+// accepted collaborator invitations.
+const (
+	SetRoleOwner  = "OWNER"
+	SetRoleEditor = "EDITOR"
+	SetRoleViewer = "VIEWER"
+
+	CollaboratorStatusPending  = "PENDING"
+	CollaboratorStatusAccepted = "ACCEPTED"
+)
+
+// Activity feed actions.
+const (
+	ActivityCardCreate         = "CARD_CREATE"
+	ActivityCardUpdate         = "CARD_UPDATE"
+	ActivityCardDelete         = "CARD_DELETE"
+	ActivityCardRevert         = "CARD_REVERT"
+	ActivityCardMerge          = "CARD_MERGE"
+	ActivitySetUpdate          = "SET_UPDATE"
+	ActivitySetMerge           = "SET_MERGE"
+	ActivitySetSplit           = "SET_SPLIT"
+	ActivityCollaboratorInvite = "COLLABORATOR_INVITE"
+	ActivityCollaboratorJoin   = "COLLABORATOR_JOIN"
+	ActivityCollaboratorRemove = "COLLABORATOR_REMOVE"
+)
+
+type CollaboratorInviteRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	UserIdToken string          `json:"userIdToken"` // invitee
+	Role        string          `json:"role"`
+	OwnerToken  string          // from middleware
+	UserId      string          // from middleware
+}
+
+func (r *CollaboratorInviteRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	r.UserIdToken = strings.TrimSpace(r.UserIdToken)
+	if r.UserIdToken == "" {
+		return errors.New("userIdToken is required")
+	}
+	r.Role = strings.ToUpper(strings.TrimSpace(r.Role))
+	if r.Role != SetRoleEditor && r.Role != SetRoleViewer {
+		return errors.New("role must be EDITOR or VIEWER")
+	}
+	return nil
+}
+
+type CollaboratorRespondRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Accept      string          `json:"accept"`
+	UserIdToken string          // from middleware
+	UserId      string          // from middleware
+}
+
+func (r CollaboratorRespondRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if r.Accept != utils.FlagY && r.Accept != utils.FlagN {
+		return errors.New("accept must be Y or N")
+	}
+	return nil
+}
+
+// CollaboratorRemoveRequest lets the owner remove anyone and a collaborator
+// remove themselves.
+type CollaboratorRemoveRequest struct {
+	SetId        decimal.Decimal `json:"setId"`
+	UserIdToken  string          `json:"userIdToken"`
+	CallerToken  string          // from middleware
+	CallerUserId string          // from middleware
+}
+
+func (r CollaboratorRemoveRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if strings.TrimSpace(r.UserIdToken) == "" {
+		return errors.New("userIdToken is required")
+	}
+	return nil
+}
+
+type CollaboratorDto struct {
+	UserIdToken string     `json:"userIdToken"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	InvitedBy   string     `json:"invitedBy"`
+	CreateAt    time.Time  `json:"createAt"`
+	AcceptedAt  *time.Time `json:"acceptedAt"`
+}
+
+type CollaboratorInvitationDto struct {
+	SetId     decimal.Decimal `json:"setId"`
+	Title     string          `json:"title"`
+	Role      string          `json:"role"`
+	InvitedBy string          `json:"invitedBy"`
+	CreateAt  time.Time       `json:"createAt"`
+}
+
+type SetActivityDto struct {
+	Id       decimal.Decimal  `json:"id"`
+	CardId   *decimal.Decimal `json:"cardId,omitempty"`
+	Action   string           `json:"action"`
+	Detail   json.RawMessage  `json:"detail,omitempty"`
+	Actor    string           `json:"actor"`
+	CreateAt time.Time        `json:"createAt"`
+}
+
+type SetActivityResponse struct {
+	SetId         decimal.Decimal  `json:"setId"`
+	Content       []SetActivityDto `json:"content"`
+	TotalPage     decimal.Decimal  `json:"totalPage"`
+	TotalElements decimal.Decimal  `json:"totalElements"`
+}
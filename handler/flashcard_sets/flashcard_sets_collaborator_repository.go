@@ -0,0 +1,436 @@
+package flashcard_sets
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrSetAccessDenied = "you do not have access to change this set"
+	ErrVersionConflict = "it was changed by someone else; reload and try again"
+	ErrInviteOwner     = "cannot invite the owner of the set"
+)
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// setRole returns the caller's role on a live set: OWNER, EDITOR, VIEWER, or
+// "" when the caller has none or the set does not exist.
+func setRole(ctx context.Context, q querier, setID interface{}, userIdToken string) (string, error) {
+	const sql = `
+        SELECT CASE WHEN s.owner_user_token = $2 THEN 'OWNER' ELSE coalesce(c.role, '') END
+          FROM tbl_flashcard_sets s
+          LEFT JOIN tbl_flashcard_set_collaborators c
+                 ON c.set_id = s.id
+                AND c.user_id_token = $2
+                AND c.status = 'ACCEPTED'
+         WHERE s.id = $1
+           AND s.is_deleted = 'N'
+    `
+	var role string
+	if err := q.QueryRow(ctx, sql, setID, userIdToken).Scan(&role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func canEditSet(role string) bool {
+	return role == SetRoleOwner || role == SetRoleEditor
+}
+
+// recordSetActivity appends an entry to the set's activity feed. detail is
+// marshalled to JSON and may be nil.
+func recordSetActivity(
+	ctx context.Context,
+	q querier,
+	setID interface{},
+	cardID *int64,
+	action string,
+	detail interface{},
+	actorToken, actor string,
+) error {
+	const sql = `
+        INSERT INTO tbl_flashcard_set_activity
+            (set_id, card_id, action, detail, actor_token, actor)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+    `
+	var raw []byte
+	if detail != nil {
+		var err error
+		if raw, err = json.Marshal(detail); err != nil {
+			return err
+		}
+	}
+	_, err := q.Exec(ctx, sql, setID, cardID, action, raw, actorToken, actor)
+	return err
+}
+
+type InviteCollaboratorFunc func(ctx context.Context, logger *zap.Logger, req CollaboratorInviteRequest) error
+
+// NewInviteCollaborator invites a user to an owned set, or changes the role
+// of an existing invitation or collaborator.
+func NewInviteCollaborator(db *pgxpool.Pool) InviteCollaboratorFunc {
+	const upsertSQL = `
+        INSERT INTO tbl_flashcard_set_collaborators
+            (set_id, user_id_token, role, invited_by)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (set_id, user_id_token)
+        DO UPDATE
+           SET role = EXCLUDED.role
+    `
+	return func(ctx context.Context, logger *zap.Logger, req CollaboratorInviteRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		owned, err := lockOwnedFlashCardSetTx(ctx, tx, req.SetId, req.OwnerToken)
+		if err != nil {
+			logger.Error("lock set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if !owned {
+			return errors.New(api.NotFound)
+		}
+		if req.UserIdToken == req.OwnerToken {
+			return errors.New(ErrInviteOwner)
+		}
+
+		if _, err = tx.Exec(ctx, upsertSQL, req.SetId, req.UserIdToken, req.Role, req.UserId); err != nil {
+			logger.Error("upsert collaborator failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = recordSetActivity(ctx, tx, req.SetId, nil, ActivityCollaboratorInvite,
+			map[string]string{"userIdToken": req.UserIdToken, "role": req.Role},
+			req.OwnerToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type RespondCollaboratorFunc func(ctx context.Context, logger *zap.Logger, req CollaboratorRespondRequest) error
+
+// NewRespondCollaborator accepts or declines the caller's pending invitation.
+func NewRespondCollaborator(db *pgxpool.Pool) RespondCollaboratorFunc {
+	const acceptSQL = `
+        UPDATE tbl_flashcard_set_collaborators
+           SET status      = 'ACCEPTED',
+               accepted_at = now()
+         WHERE set_id = $1
+           AND user_id_token = $2
+           AND status = 'PENDING'
+     RETURNING role
+    `
+	const declineSQL = `
+        DELETE FROM tbl_flashcard_set_collaborators
+         WHERE set_id = $1
+           AND user_id_token = $2
+           AND status = 'PENDING'
+    `
+	return func(ctx context.Context, logger *zap.Logger, req CollaboratorRespondRequest) (err error) {
+		if req.Accept == utils.FlagN {
+			tag, err := db.Exec(ctx, declineSQL, req.SetId, req.UserIdToken)
+			if err != nil {
+				logger.Error("decline invitation failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if tag.RowsAffected() == 0 {
+				return errors.New(api.NotFound)
+			}
+			return nil
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var role string
+		if err = tx.QueryRow(ctx, acceptSQL, req.SetId, req.UserIdToken).Scan(&role); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("accept invitation failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = recordSetActivity(ctx, tx, req.SetId, nil, ActivityCollaboratorJoin,
+			map[string]string{"userIdToken": req.UserIdToken, "role": role},
+			req.UserIdToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type RemoveCollaboratorFunc func(ctx context.Context, logger *zap.Logger, req CollaboratorRemoveRequest) error
+
+func NewRemoveCollaborator(db *pgxpool.Pool) RemoveCollaboratorFunc {
+	const deleteSQL = `
+        DELETE FROM tbl_flashcard_set_collaborators c
+         USING tbl_flashcard_sets s
+         WHERE c.set_id = $1
+           AND c.user_id_token = $2
+           AND s.id = c.set_id
+           AND (s.owner_user_token = $3 OR c.user_id_token = $3)
+    `
+	return func(ctx context.Context, logger *zap.Logger, req CollaboratorRemoveRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		tag, err := tx.Exec(ctx, deleteSQL, req.SetId, req.UserIdToken, req.CallerToken)
+		if err != nil {
+			logger.Error("remove collaborator failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		if err = recordSetActivity(ctx, tx, req.SetId, nil, ActivityCollaboratorRemove,
+			map[string]string{"userIdToken": req.UserIdToken},
+			req.CallerToken, req.CallerUserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type ListCollaboratorsFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	userIdToken string,
+) ([]CollaboratorDto, error)
+
+// NewListCollaborators lists invitations and collaborators of a set to its
+// owner and collaborators.
+func NewListCollaborators(db *pgxpool.Pool) ListCollaboratorsFunc {
+	const listSQL = `
+        SELECT user_id_token, role, status, coalesce(invited_by, ''), create_at, accepted_at
+          FROM tbl_flashcard_set_collaborators
+         WHERE set_id = $1
+         ORDER BY create_at, user_id_token
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		userIdToken string,
+	) ([]CollaboratorDto, error) {
+		role, err := setRole(ctx, db, setID, userIdToken)
+		if err != nil {
+			logger.Error("resolve set role failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		if role == "" {
+			return nil, errors.New(api.NotFound)
+		}
+
+		rows, err := db.Query(ctx, listSQL, setID)
+		if err != nil {
+			logger.Error("query collaborators failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		result := []CollaboratorDto{}
+		for rows.Next() {
+			var d CollaboratorDto
+			if err := rows.Scan(&d.UserIdToken, &d.Role, &d.Status, &d.InvitedBy, &d.CreateAt, &d.AcceptedAt); err != nil {
+				logger.Error("scan collaborator failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			result = append(result, d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate collaborators failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return result, nil
+	}
+}
+
+type ListInvitationsFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	userIdToken string,
+) ([]CollaboratorInvitationDto, error)
+
+// NewListInvitations lists the caller's pending invitations.
+func NewListInvitations(db *pgxpool.Pool) ListInvitationsFunc {
+	const listSQL = `
+        SELECT s.id, coalesce(s.title, ''), c.role, coalesce(c.invited_by, ''), c.create_at
+          FROM tbl_flashcard_set_collaborators c
+          JOIN tbl_flashcard_sets s ON s.id = c.set_id
+         WHERE c.user_id_token = $1
+           AND c.status = 'PENDING'
+           AND s.is_deleted = 'N'
+         ORDER BY c.create_at DESC
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		userIdToken string,
+	) ([]CollaboratorInvitationDto, error) {
+		rows, err := db.Query(ctx, listSQL, userIdToken)
+		if err != nil {
+			logger.Error("query invitations failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		result := []CollaboratorInvitationDto{}
+		for rows.Next() {
+			var (
+				d     CollaboratorInvitationDto
+				setID int64
+			)
+			if err := rows.Scan(&setID, &d.Title, &d.Role, &d.InvitedBy, &d.CreateAt); err != nil {
+				logger.Error("scan invitation failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			d.SetId = decimal.NewFromInt(setID)
+			result = append(result, d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate invitations failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return result, nil
+	}
+}
+
+type ListSetActivityFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	page, size int,
+	userIdToken string,
+) (SetActivityResponse, error)
+
+// NewListSetActivity pages through the activity feed of a set, newest first,
+// for its owner and collaborators.
+func NewListSetActivity(db *pgxpool.Pool) ListSetActivityFunc {
+	const listSQL = `
+        SELECT id, card_id, action, detail, coalesce(actor, ''), create_at, count(*) OVER ()
+          FROM tbl_flashcard_set_activity
+         WHERE set_id = $1
+         ORDER BY create_at DESC, id DESC
+        OFFSET $2 LIMIT $3
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		page, size int,
+		userIdToken string,
+	) (SetActivityResponse, error) {
+		resp := SetActivityResponse{
+			SetId:   decimal.NewFromInt(setID),
+			Content: []SetActivityDto{},
+		}
+
+		role, err := setRole(ctx, db, setID, userIdToken)
+		if err != nil {
+			logger.Error("resolve set role failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if role == "" {
+			return resp, errors.New(api.NotFound)
+		}
+
+		rows, err := db.Query(ctx, listSQL, setID, (page-1)*size, size)
+		if err != nil {
+			logger.Error("query set activity failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		var total int64
+		for rows.Next() {
+			var (
+				d      SetActivityDto
+				id     int64
+				cardID *int64
+				detail []byte
+			)
+			if err := rows.Scan(&id, &cardID, &d.Action, &detail, &d.Actor, &d.CreateAt, &total); err != nil {
+				logger.Error("scan set activity failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			d.Id = decimal.NewFromInt(id)
+			if cardID != nil {
+				c := decimal.NewFromInt(*cardID)
+				d.CardId = &c
+			}
+			if len(detail) > 0 {
+				d.Detail = detail
+			}
+			resp.Content = append(resp.Content, d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate set activity failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		resp.TotalElements = decimal.NewFromInt(total)
+		resp.TotalPage = decimal.NewFromInt(int64(math.Ceil(float64(total) / float64(size))))
+		return resp, nil
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"go.uber.org/zap"
 )
 
 func NewCreateHandler(
 	insertFlashCardsSetFunc InsertFlashCardsSetFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
 ) fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
@@ -36,6 +38,7 @@ func NewCreateHandler(
 		if err != nil {
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
+		queueCardAudioFunc()
 		return api.Ok(c, nil)
 	}
 }
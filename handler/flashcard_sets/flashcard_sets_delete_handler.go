@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
@@ -27,9 +28,16 @@ func NewDeleteHandler(
 		}
 
 		req.UserId = c.Locals("userId").(string)
+		req.UserIdToken = utils.GetUserIDToken(c)
 
 		if err := deleteFlashCardSetsFunc(ctx, logger, req); err != nil {
 			logger.Error("delete failed", zap.String("requestId", requestId), zap.Error(err))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard set not found")
+			case ErrSetAccessDenied:
+				return api.Forbidden(c)
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
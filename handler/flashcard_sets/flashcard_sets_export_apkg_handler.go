@@ -0,0 +1,49 @@
+package flashcard_sets
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewFlashCardSetsExportApkgHandler(
+	getAnkiExportDeckFunc GetAnkiExportDeckFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		setId := c.Params("setId")
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		id, err := strconv.Atoi(setId)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, "setId must be a number")
+		}
+		includeSchedule := c.Query("includeSchedule") == utils.FlagY
+
+		deck, err := getAnkiExportDeckFunc(ctx, logger, id, utils.GetUserIDToken(c), includeSchedule)
+		if err != nil {
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		pkg, err := BuildAnkiPackage(ctx, deck, time.Now())
+		if err != nil {
+			logger.Error("build apkg", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="flashcard-set-%d.apkg"`, id))
+		return c.Status(fiber.StatusOK).Send(pkg)
+	}
+}
@@ -0,0 +1,107 @@
+package flashcard_sets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewForkFlashCardsSetHandler(
+	forkFlashCardsSetFunc ForkFlashCardsSetFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req ForkFlashCardsSetRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.OwnerIdToken = utils.GetUserIDToken(c)
+
+		newSetId, err := forkFlashCardsSetFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		return api.Ok(c, fiber.Map{"newSetId": newSetId})
+	}
+}
+
+func NewUpstreamChangesHandler(
+	getUpstreamChangesFunc GetUpstreamChangesFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+
+		res, err := getUpstreamChangesFunc(ctx, logger, setId, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrNotAFork, ErrUpstreamUnavailable:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewFlashCardSetsSyncHandler(
+	syncFlashCardsSetFunc SyncFlashCardsSetFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardSetsSyncRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		res, err := syncFlashCardsSetFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrNotAFork, ErrUpstreamUnavailable:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
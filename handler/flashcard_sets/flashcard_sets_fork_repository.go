@@ -0,0 +1,473 @@
+package flashcard_sets
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+// A card that was never edited has no rows in tbl_flashcard_revisions; it
+// counts as revision 1, the same way the history endpoint presents it.
+// A fork card tracks two numbers: upstream_revision is the upstream card's
+// revision it was last pulled from, local_revision is its own revision right
+// after that pull. Upstream edited means the upstream card moved past
+// upstream_revision; locally modified means the fork card moved past
+// local_revision.
+
+// forkSourceSQL resolves the upstream of a fork owned by the caller. The
+// upstream must still exist and be visible to the caller.
+const forkSourceSQL = `
+    SELECT s.forked_from_set_id,
+           EXISTS (
+               SELECT 1
+                 FROM tbl_flashcard_sets u
+                WHERE u.id = s.forked_from_set_id
+                  AND u.is_deleted = 'N'
+                  AND (u.owner_user_token = $2 OR u.is_public = 'Y')
+           )
+      FROM tbl_flashcard_sets s
+     WHERE s.id = $1
+       AND s.owner_user_token = $2
+       AND s.is_deleted = 'N'
+`
+
+type ForkFlashCardsSetFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req ForkFlashCardsSetRequest,
+) (newSetID int, err error)
+
+// NewForkFlashCardsSet copies a set the caller owns or that is public into a
+// new private set that remembers where it came from. Every copied card keeps
+// a link to its upstream card so later upstream changes can be pulled.
+func NewForkFlashCardsSet(db *pgxpool.Pool) ForkFlashCardsSetFunc {
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req ForkFlashCardsSetRequest,
+	) (newSetID int, err error) {
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("tx begin failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+		const forkSetSQL = `
+		INSERT INTO tbl_flashcard_sets
+				(owner_user_token, title, description, is_public, create_by, study_direction,
+				 front_lang, back_lang, forked_from_set_id, forked_at)
+		SELECT  $1       , title, description, 'N', $2, study_direction,
+				front_lang, back_lang, id, now()
+		FROM    tbl_flashcard_sets
+		WHERE   id = $3
+		  AND   is_deleted = 'N'
+		  AND   (owner_user_token = $1 OR is_public = 'Y')
+		RETURNING id;
+`
+		if err = tx.QueryRow(
+			ctx, forkSetSQL,
+			req.OwnerIdToken,
+			req.UserId,
+			req.SourceSetID,
+		).Scan(&newSetID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("fork set failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+
+		// the forked cards link the media blobs of their upstream cards
+		const forkCardsSQL = `
+			WITH forked AS (
+				INSERT INTO tbl_flashcards
+						(set_id, front, back, choices, create_by, seq, card_type,
+						 upstream_card_id, upstream_revision, local_revision)
+				SELECT  $1      , f.front, f.back, f.choices, $2, f.seq, f.card_type,
+						f.id,
+						coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = f.id), 1),
+						1
+				FROM    tbl_flashcards f
+				WHERE   f.set_id = $3
+				  AND   f.is_deleted = 'N'
+				RETURNING id, upstream_card_id
+			)
+			INSERT INTO tbl_flashcard_media
+					(card_id, side, kind, content_type, size_bytes, storage_key, original_name, create_by)
+			SELECT  c.id, m.side, m.kind, m.content_type, m.size_bytes, m.storage_key, m.original_name, $2
+			FROM    forked c
+			JOIN    tbl_flashcard_media m ON m.card_id = c.upstream_card_id
+			ORDER BY m.id;
+		`
+		if _, err = tx.Exec(
+			ctx, forkCardsSQL,
+			newSetID,
+			req.UserId,
+			req.SourceSetID,
+		); err != nil {
+			logger.Error("fork cards failed", zap.Error(err), zap.Int("new_set_id", newSetID))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return newSetID, nil
+	}
+}
+
+type GetUpstreamChangesFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	userIdToken string,
+) (UpstreamChangesResponse, error)
+
+// NewGetUpstreamChanges lists what changed upstream since the fork was made
+// or last synced: upstream cards the fork never had, upstream cards edited
+// since they were pulled and upstream cards that were deleted. Cards the fork
+// owner deleted are not offered again.
+func NewGetUpstreamChanges(db *pgxpool.Pool) GetUpstreamChangesFunc {
+	const newSQL = `
+        SELECT u.id, u.front, u.back, u.choices, u.card_type
+          FROM tbl_flashcards u
+         WHERE u.set_id = $1
+           AND u.is_deleted = 'N'
+           AND NOT EXISTS (
+                SELECT 1
+                  FROM tbl_flashcards f
+                 WHERE f.set_id = $2
+                   AND f.upstream_card_id = u.id
+           )
+         ORDER BY u.seq, u.id
+    `
+	const linkedSQL = `
+        SELECT f.id, f.front, f.back, f.choices,
+               coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = f.id), 1)
+                   > coalesce(f.local_revision, 1),
+               u.id IS NULL,
+               coalesce(u.id, 0), coalesce(u.front, ''), coalesce(u.back, ''), u.choices,
+               coalesce(u.card_type, 'BASIC'),
+               coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = u.id), 1)
+                   > coalesce(f.upstream_revision, 1)
+                   OR u.card_type IS DISTINCT FROM f.card_type
+          FROM tbl_flashcards f
+          LEFT JOIN tbl_flashcards u
+                 ON u.id = f.upstream_card_id
+                AND u.set_id = $2
+                AND u.is_deleted = 'N'
+         WHERE f.set_id = $1
+           AND f.is_deleted = 'N'
+           AND f.upstream_card_id IS NOT NULL
+         ORDER BY f.seq, f.id
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		userIdToken string,
+	) (UpstreamChangesResponse, error) {
+		resp := UpstreamChangesResponse{
+			SetId:   decimal.NewFromInt(setID),
+			New:     []UpstreamCard{},
+			Edited:  []UpstreamCardChange{},
+			Deleted: []UpstreamCardChange{},
+		}
+
+		var (
+			upstreamID *int64
+			available  bool
+		)
+		if err := db.QueryRow(ctx, forkSourceSQL, setID, userIdToken).Scan(&upstreamID, &available); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return resp, errors.New(api.NotFound)
+			}
+			logger.Error("resolve fork source failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if upstreamID == nil {
+			return resp, errors.New(ErrNotAFork)
+		}
+		if !available {
+			return resp, errors.New(ErrUpstreamUnavailable)
+		}
+		resp.UpstreamSetId = decimal.NewFromInt(*upstreamID)
+
+		rows, err := db.Query(ctx, newSQL, *upstreamID, setID)
+		if err != nil {
+			logger.Error("query new upstream cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		for rows.Next() {
+			var (
+				card UpstreamCard
+				id   int64
+			)
+			if err = rows.Scan(&id, &card.Front, &card.Back, &card.Choices, &card.CardType); err != nil {
+				rows.Close()
+				logger.Error("scan new upstream card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			card.Id = decimal.NewFromInt(id)
+			resp.New = append(resp.New, card)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("iterate new upstream cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		rows, err = db.Query(ctx, linkedSQL, setID, *upstreamID)
+		if err != nil {
+			logger.Error("query linked cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				change          UpstreamCardChange
+				upstream        UpstreamCard
+				id, upID        int64
+				deleted, edited bool
+			)
+			if err = rows.Scan(
+				&id, &change.Front, &change.Back, &change.Choices, &change.LocallyModified,
+				&deleted,
+				&upID, &upstream.Front, &upstream.Back, &upstream.Choices, &upstream.CardType,
+				&edited,
+			); err != nil {
+				logger.Error("scan linked card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			change.CardId = decimal.NewFromInt(id)
+			switch {
+			case deleted:
+				resp.Deleted = append(resp.Deleted, change)
+			case edited:
+				upstream.Id = decimal.NewFromInt(upID)
+				change.Upstream = &upstream
+				resp.Edited = append(resp.Edited, change)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			logger.Error("iterate linked cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
+
+type SyncFlashCardsSetFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req FlashCardSetsSyncRequest,
+) (FlashCardSetsSyncResponse, error)
+
+// NewSyncFlashCardsSet pulls the selected upstream changes into a fork in a
+// single transaction. New cards are appended after the fork's last card,
+// updates copy the upstream content onto the fork card in place, so its id
+// and SRS progress are kept and the overwritten version stays in its history,
+// and deletes soft-delete the fork card once its upstream card is gone.
+// Added cards link the media of their upstream cards.
+func NewSyncFlashCardsSet(db *pgxpool.Pool) SyncFlashCardsSetFunc {
+	const addSQL = `
+        WITH added AS (
+            INSERT INTO tbl_flashcards
+                (set_id, front, back, choices, create_by, seq, card_type,
+                 upstream_card_id, upstream_revision, local_revision)
+            SELECT $1, u.front, u.back, u.choices, $2,
+                   $3 + row_number() OVER (ORDER BY u.seq, u.id) - 1,
+                   u.card_type, u.id,
+                   coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = u.id), 1),
+                   1
+              FROM tbl_flashcards u
+             WHERE u.id = ANY($4::bigint[])
+               AND u.set_id = $5
+               AND u.is_deleted = 'N'
+               AND NOT EXISTS (
+                    SELECT 1
+                      FROM tbl_flashcards f
+                     WHERE f.set_id = $1
+                       AND f.upstream_card_id = u.id
+               )
+            RETURNING id, upstream_card_id
+        ), media AS (
+            INSERT INTO tbl_flashcard_media
+                (card_id, side, kind, content_type, size_bytes, storage_key, original_name, create_by)
+            SELECT a.id, m.side, m.kind, m.content_type, m.size_bytes, m.storage_key, m.original_name, $2
+              FROM added a
+              JOIN tbl_flashcard_media m ON m.card_id = a.upstream_card_id
+             ORDER BY m.id
+        )
+        SELECT count(*) FROM added
+    `
+	const localSQL = `
+        SELECT coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = f.id), 1)
+                   > coalesce(f.local_revision, 1)
+          FROM tbl_flashcards f
+          JOIN tbl_flashcards u ON u.id = f.upstream_card_id
+         WHERE f.id = $1
+           AND f.set_id = $2
+           AND f.is_deleted = 'N'
+           AND u.set_id = $3
+           AND u.is_deleted = 'N'
+    `
+	const pullSQL = `
+        UPDATE tbl_flashcards f
+           SET front             = u.front,
+               back              = u.back,
+               choices           = u.choices,
+               card_type         = u.card_type,
+               upstream_revision = coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = u.id), 1),
+               update_by         = $2,
+               update_at         = now(),
+               version           = f.version + 1
+          FROM tbl_flashcards u
+         WHERE f.id = $1
+           AND u.id = f.upstream_card_id
+    `
+	const markSyncedSQL = `
+        UPDATE tbl_flashcards f
+           SET local_revision = coalesce((SELECT max(r.revision) FROM tbl_flashcard_revisions r WHERE r.card_id = f.id), 1)
+         WHERE f.id = $1
+    `
+	const deleteSQL = `
+        UPDATE tbl_flashcards f
+           SET is_deleted = 'Y',
+               deleted_at = now(),
+               update_by  = $1,
+               update_at  = now()
+         WHERE f.id = ANY($2::bigint[])
+           AND f.set_id = $3
+           AND f.is_deleted = 'N'
+           AND f.upstream_card_id IS NOT NULL
+           AND NOT EXISTS (
+                SELECT 1
+                  FROM tbl_flashcards u
+                 WHERE u.id = f.upstream_card_id
+                   AND u.set_id = $4
+                   AND u.is_deleted = 'N'
+           )
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req FlashCardSetsSyncRequest,
+	) (resp FlashCardSetsSyncResponse, err error) {
+		resp.Updated = []decimal.Decimal{}
+		resp.Skipped = []decimal.Decimal{}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		owned, err := lockOwnedFlashCardSetTx(ctx, tx, req.SetId, req.UserIdToken)
+		if err != nil {
+			logger.Error("lock fork set failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if !owned {
+			return resp, errors.New(api.NotFound)
+		}
+
+		var (
+			upstreamID *int64
+			available  bool
+		)
+		if err = tx.QueryRow(ctx, forkSourceSQL, req.SetId, req.UserIdToken).Scan(&upstreamID, &available); err != nil {
+			logger.Error("resolve fork source failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if upstreamID == nil {
+			return resp, errors.New(ErrNotAFork)
+		}
+		if !available {
+			return resp, errors.New(ErrUpstreamUnavailable)
+		}
+
+		if len(req.AddCardIds) > 0 {
+			var startSeq int
+			if startSeq, err = nextFlashCardSeqTx(ctx, tx, req.SetId); err != nil {
+				logger.Error("resolve next seq failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if err = tx.QueryRow(ctx, addSQL,
+				req.SetId, req.UserId, startSeq,
+				decimalsToInt64s(req.AddCardIds), *upstreamID,
+			).Scan(&resp.Added); err != nil {
+				logger.Error("add upstream cards failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+		}
+
+		for _, id := range decimalsToInt64s(req.UpdateCardIds) {
+			var modified bool
+			if err = tx.QueryRow(ctx, localSQL, id, req.SetId, *upstreamID).Scan(&modified); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					err = nil
+					resp.Skipped = append(resp.Skipped, decimal.NewFromInt(id))
+					continue
+				}
+				logger.Error("check local revision failed", zap.Error(err), zap.Int64("card_id", id))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if modified && req.OverwriteLocal != "Y" {
+				resp.Skipped = append(resp.Skipped, decimal.NewFromInt(id))
+				continue
+			}
+			if err = seedFlashCardRevisionTx(ctx, tx, id); err != nil {
+				logger.Error("seed revision failed", zap.Error(err), zap.Int64("card_id", id))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, pullSQL, id, req.UserId); err != nil {
+				logger.Error("pull upstream card failed", zap.Error(err), zap.Int64("card_id", id))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if err = appendFlashCardRevisionTx(ctx, tx, id, RevisionActionSync, nil, req.UserId); err != nil {
+				logger.Error("append revision failed", zap.Error(err), zap.Int64("card_id", id))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, markSyncedSQL, id); err != nil {
+				logger.Error("mark card synced failed", zap.Error(err), zap.Int64("card_id", id))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			resp.Updated = append(resp.Updated, decimal.NewFromInt(id))
+		}
+
+		if len(req.DeleteCardIds) > 0 {
+			var tag pgconn.CommandTag
+			if tag, err = tx.Exec(ctx, deleteSQL,
+				req.UserId, decimalsToInt64s(req.DeleteCardIds), req.SetId, *upstreamID,
+			); err != nil {
+				logger.Error("delete fork cards failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			resp.Deleted = int(tag.RowsAffected())
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,118 @@
+package flashcard_sets
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewFlashCardSetsImportApkgHandler(
+	maxUnpackedBytes int64,
+	insertFlashCardsSetFunc InsertFlashCardsSetFunc,
+	checkDuplicateFrontsFunc CheckDuplicateFrontsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
+) fiber.Handler {
+
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsSetsApkgImportRequest
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		// 1) parse + validate multipart form
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		fh, err := c.FormFile("file")
+		if err != nil || fh == nil {
+			return api.BadRequest(c, "missing file")
+		}
+		if !strings.EqualFold(filepath.Ext(fh.Filename), ".apkg") {
+			return api.BadRequest(c, "file must be an .apkg package")
+		}
+
+		// 2) identity from middleware
+		req.UserId = utils.GetUserID(c)
+		req.OwnerIdToken = utils.GetUserIDToken(c)
+
+		// 3) read package
+		src, err := fh.Open()
+		if err != nil {
+			logger.Error("open apkg", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		defer src.Close()
+		// the zip directory is read in place from the spooled upload
+		pkg, err := ParseAnkiPackage(ctx, src, fh.Size, maxUnpackedBytes)
+		if err != nil {
+			logger.Error("parse apkg", zap.String("requestId", requestId), zap.Error(err))
+			if errors.Cause(err).Error() == ErrAnkiPackageTooLarge {
+				return api.BadRequest(c, ErrAnkiPackageTooLarge)
+			}
+			return api.BadRequest(c, "invalid Anki package")
+		}
+		if len(pkg.Cards) == 0 {
+			return api.BadRequest(c, "Anki package contained no notes with front and back")
+		}
+
+		// 4) fill defaults from the deck
+		if req.Title == "" {
+			req.Title = pkg.DeckName
+		}
+		if req.Title == "" {
+			req.Title = strings.TrimSuffix(fh.Filename, filepath.Ext(fh.Filename))
+		}
+		if req.Description == "" {
+			req.Description = "Imported from Anki"
+		}
+		if req.IsPublic == "" {
+			req.IsPublic = utils.FlagN
+		}
+
+		fronts := make([]string, 0, len(pkg.Cards))
+		for _, card := range pkg.Cards {
+			fronts = append(fronts, card.Front)
+		}
+		duplicates, err := checkDuplicateFrontsFunc(ctx, logger, DuplicateCheckRequest{
+			Fronts:      fronts,
+			UserIdToken: req.OwnerIdToken,
+		})
+		if err != nil {
+			logger.Warn("duplicate check failed", zap.String("requestId", requestId), zap.Error(err))
+		}
+
+		// 5) reuse existing InsertFlashCardsSetFunc
+		createReq := FlashCardSetsCreateRequest{
+			Title:        req.Title,
+			Description:  req.Description,
+			IsPublic:     req.IsPublic,
+			FlashCards:   &pkg.Cards,
+			OwnerIdToken: req.OwnerIdToken,
+			UserId:       req.UserId,
+		}
+		if err := insertFlashCardsSetFunc(ctx, logger, createReq); err != nil {
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		queueCardAudioFunc()
+		return api.Ok(c, FlashCardsSetsApkgImportResponse{
+			Title:      req.Title,
+			Imported:   len(pkg.Cards),
+			Skipped:    pkg.Skipped,
+			Media:      len(pkg.MediaFiles),
+			Issues:     pkg.Issues,
+			Duplicates: duplicates,
+		})
+	}
+}
@@ -3,26 +3,32 @@ package flashcard_sets
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/csv"
+	"io"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
 func NewFlashCardSetsImportCsvHandler(
 	insertFlashCardsSetFunc InsertFlashCardsSetFunc,
+	importIntoFlashCardsSetFunc ImportIntoFlashCardsSetFunc,
+	checkDuplicateFrontsFunc CheckDuplicateFrontsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
 ) fiber.Handler {
 
 	return func(c *fiber.Ctx) error {
 		var req FlashCardsSetsCsvImportRequest
+		var src io.Reader
 		ctx := c.Context()
 		logger := logz.NewLogger()
 		requestId := c.Get("requestId")
 
-		// 1) parse + validate JSON
+		// 1) parse + validate JSON or multipart form
 		if err := c.BodyParser(&req); err != nil {
 			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
 			return api.BadRequest(c, api.InvalidateBody)
@@ -37,61 +43,110 @@ func NewFlashCardSetsImportCsvHandler(
 		userIdToken := c.Locals("userIdToken").(string)
 		req.OwnerIdToken = userIdToken
 
-		// 3) decode CSV
-		csvBytes, decodeErr := base64.StdEncoding.DecodeString(req.File)
-		if decodeErr != nil {
-			logger.Error("decode base64", zap.String("requestId", requestId), zap.Error(decodeErr))
-			return api.BadRequest(c, "file must be a valid base64‑encoded CSV")
+		// 3) open CSV: multipart file part, or legacy base64 field
+		if strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEMultipartForm) {
+			fh, err := c.FormFile("file")
+			if err != nil || fh == nil {
+				return api.BadRequest(c, "missing file")
+			}
+			f, err := fh.Open()
+			if err != nil {
+				logger.Error("open csv", zap.String("requestId", requestId), zap.Error(err))
+				return api.InternalError(c, api.SomeThingWentWrong)
+			}
+			defer f.Close()
+			src = f
+		} else {
+			if req.File == "" {
+				return api.BadRequest(c, "file (base64 CSV) is required")
+			}
+			csvBytes, decodeErr := base64.StdEncoding.DecodeString(req.File)
+			if decodeErr != nil {
+				logger.Error("decode base64", zap.String("requestId", requestId), zap.Error(decodeErr))
+				return api.BadRequest(c, "file must be a valid base64‑encoded CSV")
+			}
+			src = bytes.NewReader(csvBytes)
 		}
-		reader := csv.NewReader(bytes.NewReader(csvBytes))
-		reader.TrimLeadingSpace = true
-		if req.CommandRec != "" {
-			reader.Comma = []rune(req.CommandRec)[0] // custom delimiter
+
+		reader, err := NewCsvCardReader(src, req.CommandRec, req.HasHeader)
+		if err != nil {
+			logger.Error("read csv", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
 		}
 
-		records, csvErr := reader.ReadAll()
-		if csvErr != nil {
-			logger.Error("read csv", zap.String("requestId", requestId), zap.Error(csvErr))
-			return api.BadRequest(c, "invalid CSV content")
+		// 4) convert rows -> cards, collecting a row-level report
+		previewSize := req.PreviewSize
+		if previewSize == 0 {
+			previewSize = defaultCsvPreviewSize
 		}
-		if len(records) == 0 {
-			return api.BadRequest(c, "CSV must have at least one row")
+		previewSize = min(previewSize, maxCsvPreviewSize)
+		// upsert matches cards on their front, so a repeated front can only
+		// update the card once and is always skipped
+		skipDuplicates := req.SkipDuplicates == utils.FlagY || req.ImportMode == ImportModeUpsert
+		cards, report, err := CollectCsvCards(reader, previewSize, skipDuplicates)
+		if err != nil {
+			logger.Error("read csv", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
 		}
-
-		// 4) convert rows -> cards
-		var cards []InsertFlashCards
-		for idx, rec := range records {
-			if len(rec) < 2 { // need at least front & back
-				logger.Warn("csv row skipped (need front & back)",
-					zap.String("requestId", requestId), zap.Int("row", idx+1))
-				continue
+		if len(cards) != 0 {
+			fronts := make([]string, 0, len(cards))
+			for _, card := range cards {
+				fronts = append(fronts, card.Front)
 			}
-
-			front := strings.TrimSpace(rec[0])
-			back := strings.TrimSpace(rec[1])
-
-			var choices []string
-			if len(rec) >= 3 && strings.TrimSpace(rec[2]) != "" {
-				raw := strings.Trim(strings.TrimSpace(rec[2]), "{}")
-				for _, ch := range strings.Split(raw, ",") {
-					val := strings.TrimSpace(strings.Trim(ch, `"'`))
-					if val != "" {
-						choices = append(choices, val)
-					}
+			// upsert and replace rewrite the set's own cards, so only
+			// matches in other sets are worth a warning
+			duplicates, err := checkDuplicateFrontsFunc(ctx, logger, DuplicateCheckRequest{
+				SetId:       req.SetId,
+				Fronts:      fronts,
+				UserIdToken: req.OwnerIdToken,
+				SkipSameSet: req.ImportMode == ImportModeUpsert || req.ImportMode == ImportModeReplace,
+			})
+			if err != nil {
+				if err.Error() == api.NotFound {
+					return api.NotFoundError(c, "flashcard set not found")
 				}
+				logger.Warn("duplicate check failed", zap.String("requestId", requestId), zap.Error(err))
 			}
-
-			cards = append(cards, InsertFlashCards{
-				Front:   front,
-				Back:    back,
-				Choices: choices,
-			})
+			report.Duplicates = duplicates
+		}
+		if req.DryRun == utils.FlagY {
+			report.DryRun = true
+			return api.Ok(c, report)
 		}
 		if len(cards) == 0 {
-			return api.BadRequest(c, "CSV contained no valid rows")
+			return api.ValidateError(c, report)
+		}
+
+		// 5a) import into an existing owned set
+		if !req.SetId.IsZero() {
+			if req.ImportMode == "" {
+				req.ImportMode = ImportModeAppend
+			}
+			result, err := importIntoFlashCardsSetFunc(ctx, logger, ImportIntoFlashCardsSetRequest{
+				SetId:         req.SetId,
+				Mode:          req.ImportMode,
+				DeleteMissing: req.DeleteMissing == utils.FlagY,
+				Cards:         cards,
+				OwnerIdToken:  req.OwnerIdToken,
+				UserId:        req.UserId,
+			})
+			if err != nil {
+				if err.Error() == api.NotFound {
+					return api.NotFoundError(c, "flashcard set not found")
+				}
+				return api.InternalError(c, api.SomeThingWentWrong)
+			}
+			report.ImportMode = req.ImportMode
+			report.Inserted = result.Inserted
+			report.Updated = result.Updated
+			report.Unchanged = result.Unchanged
+			report.Deleted = result.Deleted
+			report.Preview = nil
+			queueCardAudioFunc()
+			return api.Ok(c, report)
 		}
 
-		// 5) reuse existing InsertFlashCardsSetFunc
+		// 5b) reuse existing InsertFlashCardsSetFunc
 		createReq := FlashCardSetsCreateRequest{
 			Title:        req.Title,
 			Description:  req.Description,
@@ -103,6 +158,9 @@ func NewFlashCardSetsImportCsvHandler(
 		if err := insertFlashCardsSetFunc(ctx, logger, createReq); err != nil {
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
-		return api.Ok(c, nil)
+		report.Inserted = len(cards)
+		report.Preview = nil
+		queueCardAudioFunc()
+		return api.Ok(c, report)
 	}
 }
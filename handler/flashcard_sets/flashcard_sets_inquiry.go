@@ -30,6 +30,9 @@ func NewInquiryFlashCardSetsHandler(
 		userIdStr := c.Locals("userIdToken").(string)
 		res, err := inquiryFlashCardSetsFunc(ctx, logger, id, userIdStr)
 		if err != nil {
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
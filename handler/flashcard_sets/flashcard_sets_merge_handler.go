@@ -0,0 +1,76 @@
+package flashcard_sets
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewMergeFlashCardSetsHandler(
+	mergeFlashCardSetsFunc MergeFlashCardSetsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardSetsMergeRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.OwnerIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		res, err := mergeFlashCardSetsFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, "flashcard set not found")
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewSplitFlashCardSetsHandler(
+	splitFlashCardSetsFunc SplitFlashCardSetsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardSetsSplitRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.OwnerIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		res, err := splitFlashCardSetsFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard set not found")
+			case ErrNothingToSplit:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
@@ -0,0 +1,398 @@
+package flashcard_sets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const ErrNothingToSplit = "no card matches the split"
+
+type MergeFlashCardSetsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardSetsMergeRequest) (FlashCardSetsMergeResponse, error)
+
+// NewMergeFlashCardSets merges owned sets into the target in one tx. Card ids
+// are kept, so SRS state and review logs follow the cards. Practice sessions
+// and the daily/default set of tbl_user_config are pointed at the target.
+func NewMergeFlashCardSets(db *pgxpool.Pool) MergeFlashCardSetsFunc {
+	const lockSetsSQL = `
+        SELECT id
+          FROM tbl_flashcard_sets
+         WHERE id = ANY($1::int[])
+           AND owner_user_token = $2
+           AND is_deleted = 'N'
+         ORDER BY id
+           FOR UPDATE
+    `
+	const setTagsSQL = `
+        INSERT INTO tbl_flashcard_set_tags (set_id, tag_id)
+        SELECT DISTINCT $1::int, tag_id
+          FROM tbl_flashcard_set_tags
+         WHERE set_id = ANY($2::int[])
+        ON CONFLICT DO NOTHING
+    `
+	const moveSessionsSQL = `
+        UPDATE tbl_practice_sessions
+           SET set_id = $1
+         WHERE set_id = ANY($2::int[])
+    `
+	const userConfigSQL = `
+        UPDATE tbl_user_config
+           SET daily_flash_card_set_id   = CASE WHEN daily_flash_card_set_id = ANY($2::int[]) THEN $1 ELSE daily_flash_card_set_id END,
+               default_flash_card_set_id = CASE WHEN default_flash_card_set_id = ANY($2::int[]) THEN $1 ELSE default_flash_card_set_id END,
+               update_at                 = now()
+         WHERE daily_flash_card_set_id = ANY($2::int[])
+            OR default_flash_card_set_id = ANY($2::int[])
+    `
+	const deleteSetsSQL = `
+        UPDATE tbl_flashcard_sets
+           SET is_deleted = 'Y',
+               deleted_at = now(),
+               update_by  = $2,
+               update_at  = now()
+         WHERE id = ANY($1::int[])
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsMergeRequest) (resp FlashCardSetsMergeResponse, err error) {
+		if err := req.Validate(); err != nil {
+			return resp, err
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		target := req.TargetSetId.IntPart()
+		sources := decimalsToInt64s(req.SourceSetIds)
+
+		// lock every set in id order so concurrent merges cannot deadlock
+		rows, err := tx.Query(ctx, lockSetsSQL, append([]int64{target}, sources...), req.OwnerIdToken)
+		if err != nil {
+			logger.Error("lock sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("lock sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if locked != len(sources)+1 {
+			return resp, errors.New(api.NotFound)
+		}
+
+		var ids []int64
+		for _, source := range sources {
+			sourceIds, err := orderedFlashCardIdsTx(ctx, tx, source)
+			if err != nil {
+				logger.Error("list source cards failed", zap.Error(err), zap.Int64("set_id", source))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			ids = append(ids, sourceIds...)
+		}
+		startSeq, err := nextFlashCardSeqTx(ctx, tx, target)
+		if err != nil {
+			logger.Error("resolve next seq failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if err = moveFlashCardsTx(ctx, tx, target, ids, startSeq, req.UserId); err != nil {
+			logger.Error("move flashcards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		if _, err = tx.Exec(ctx, setTagsSQL, target, sources); err != nil {
+			logger.Error("merge set tags failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, moveSessionsSQL, target, sources); err != nil {
+			logger.Error("remap practice sessions failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, userConfigSQL, target, sources); err != nil {
+			logger.Error("remap user config failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, deleteSetsSQL, sources, req.UserId); err != nil {
+			logger.Error("delete merged sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if err = recordSetActivity(ctx, tx, target, nil, ActivitySetMerge,
+			map[string]interface{}{"sourceSetIds": sources, "count": len(ids)},
+			req.OwnerIdToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		resp.TargetSetId = decimal.NewFromInt(target)
+		resp.MovedCards = len(ids)
+		return resp, nil
+	}
+}
+
+type SplitFlashCardSetsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardSetsSplitRequest) (FlashCardSetsSplitResponse, error)
+
+type splitGroup struct {
+	label string
+	ids   []int64
+}
+
+// NewSplitFlashCardSets moves the selected cards of an owned set into new
+// sets in one tx. The new sets copy the settings, folder and tags of the
+// original and are titled "<title> (<range|tag|status>)". Card ids are kept,
+// so SRS state follows the cards; practice sessions of the original skip the
+// moved cards from now on.
+func NewSplitFlashCardSets(db *pgxpool.Pool) SplitFlashCardSetsFunc {
+	const cardTagsSQL = `
+        SELECT ct.card_id, lower(t.name)
+          FROM tbl_flashcard_tags ct
+          JOIN tbl_tags t ON t.id = ct.tag_id
+         WHERE ct.card_id = ANY($1::bigint[])
+           AND t.user_id_token = $2
+    `
+	const cardStatusSQL = `
+        SELECT f.id, coalesce(us.status, 'studying')
+          FROM tbl_flashcards f
+          LEFT JOIN tbl_user_flashcard_status us
+                 ON us.card_id = f.id
+                AND us.user_id_token = $1
+         WHERE f.id = ANY($2::bigint[])
+    `
+	const newSetSQL = `
+        INSERT INTO tbl_flashcard_sets
+            (owner_user_token, title, description, is_public, create_by, study_direction, folder_id,
+             front_lang, back_lang)
+        SELECT owner_user_token, left(coalesce(title, '') || ' (' || $2 || ')', 255), description,
+               is_public, $3, study_direction, folder_id, front_lang, back_lang
+          FROM tbl_flashcard_sets
+         WHERE id = $1
+        RETURNING id, title
+    `
+	const setTagsSQL = `
+        INSERT INTO tbl_flashcard_set_tags (set_id, tag_id)
+        SELECT $2, tag_id
+          FROM tbl_flashcard_set_tags
+         WHERE set_id = $1
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsSplitRequest) (resp FlashCardSetsSplitResponse, err error) {
+		if err := req.Validate(); err != nil {
+			return resp, err
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		owned, err := lockOwnedFlashCardSetTx(ctx, tx, req.SetId, req.OwnerIdToken)
+		if err != nil {
+			logger.Error("lock set failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if !owned {
+			return resp, errors.New(api.NotFound)
+		}
+		ordered, err := orderedFlashCardIdsTx(ctx, tx, req.SetId)
+		if err != nil {
+			logger.Error("list set cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		var groups []splitGroup
+		switch req.By {
+		case SplitByRange:
+			for _, rg := range req.Ranges {
+				g := splitGroup{label: fmt.Sprintf("%d-%d", rg.From, rg.To)}
+				if rg.From <= len(ordered) {
+					g.ids = ordered[rg.From-1 : min(rg.To, len(ordered))]
+				}
+				groups = append(groups, g)
+			}
+
+		case SplitByTag:
+			tagsOf := map[int64]map[string]bool{}
+			rows, err := tx.Query(ctx, cardTagsSQL, ordered, req.OwnerIdToken)
+			if err != nil {
+				logger.Error("query card tags failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			for rows.Next() {
+				var (
+					cardID int64
+					name   string
+				)
+				if err = rows.Scan(&cardID, &name); err != nil {
+					rows.Close()
+					logger.Error("scan card tag failed", zap.Error(err))
+					return resp, errors.New(api.SomeThingWentWrong)
+				}
+				if tagsOf[cardID] == nil {
+					tagsOf[cardID] = map[string]bool{}
+				}
+				tagsOf[cardID][name] = true
+			}
+			rows.Close()
+			if err = rows.Err(); err != nil {
+				logger.Error("iterate card tags failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+
+			assigned := map[int64]bool{}
+			seenTag := map[string]bool{}
+			for _, tag := range req.Tags {
+				tag = strings.TrimSpace(tag)
+				key := strings.ToLower(tag)
+				if key == "" || seenTag[key] {
+					continue
+				}
+				seenTag[key] = true
+				g := splitGroup{label: tag}
+				for _, id := range ordered {
+					if !assigned[id] && tagsOf[id][key] {
+						assigned[id] = true
+						g.ids = append(g.ids, id)
+					}
+				}
+				groups = append(groups, g)
+			}
+
+		case SplitByStatus:
+			statusOf := map[int64]string{}
+			rows, err := tx.Query(ctx, cardStatusSQL, req.OwnerIdToken, ordered)
+			if err != nil {
+				logger.Error("query card status failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			for rows.Next() {
+				var (
+					cardID int64
+					status string
+				)
+				if err = rows.Scan(&cardID, &status); err != nil {
+					rows.Close()
+					logger.Error("scan card status failed", zap.Error(err))
+					return resp, errors.New(api.SomeThingWentWrong)
+				}
+				statusOf[cardID] = status
+			}
+			rows.Close()
+			if err = rows.Err(); err != nil {
+				logger.Error("iterate card status failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+
+			statuses := req.Statuses
+			if len(statuses) == 0 {
+				seen := map[string]bool{}
+				for _, id := range ordered {
+					if s := statusOf[id]; s != "" && !seen[s] {
+						seen[s] = true
+						statuses = append(statuses, s)
+					}
+				}
+			}
+			for _, status := range statuses {
+				g := splitGroup{label: status}
+				for _, id := range ordered {
+					if statusOf[id] == status {
+						g.ids = append(g.ids, id)
+					}
+				}
+				groups = append(groups, g)
+			}
+		}
+
+		resp.SetId = req.SetId
+		resp.NewSets = []FlashCardSetsSplitPart{}
+		moved := map[int64]bool{}
+		var movedIds []int64
+		for _, g := range groups {
+			if len(g.ids) == 0 {
+				continue
+			}
+			var (
+				newSetID int64
+				title    string
+			)
+			if err = tx.QueryRow(ctx, newSetSQL, req.SetId, g.label, req.UserId).Scan(&newSetID, &title); err != nil {
+				logger.Error("create split set failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, setTagsSQL, req.SetId, newSetID); err != nil {
+				logger.Error("copy set tags failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			if err = moveFlashCardsTx(ctx, tx, newSetID, g.ids, 0, req.UserId); err != nil {
+				logger.Error("move flashcards failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			for _, id := range g.ids {
+				moved[id] = true
+			}
+			movedIds = append(movedIds, g.ids...)
+			resp.NewSets = append(resp.NewSets, FlashCardSetsSplitPart{
+				SetId:     decimal.NewFromInt(newSetID),
+				Title:     title,
+				CardCount: len(g.ids),
+			})
+		}
+		if len(movedIds) == 0 {
+			return resp, errors.New(ErrNothingToSplit)
+		}
+
+		remaining := make([]int64, 0, len(ordered)-len(movedIds))
+		for _, id := range ordered {
+			if !moved[id] {
+				remaining = append(remaining, id)
+			}
+		}
+		if err = rewriteFlashCardSeqTx(ctx, tx, req.SetId, remaining, req.UserId); err != nil {
+			logger.Error("rewrite card seq failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		resp.Remaining = len(remaining)
+
+		newSetIds := make([]decimal.Decimal, 0, len(resp.NewSets))
+		for _, part := range resp.NewSets {
+			newSetIds = append(newSetIds, part.SetId)
+		}
+		if err = recordSetActivity(ctx, tx, req.SetId, nil, ActivitySetSplit,
+			map[string]interface{}{"by": req.By, "newSetIds": newSetIds, "count": len(movedIds)},
+			req.OwnerIdToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
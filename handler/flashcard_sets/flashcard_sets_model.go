@@ -1,26 +1,59 @@
 package flashcard_sets
 
 import (
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 )
 
 type InsertFlashCards struct {
-	Front   string   `json:"front"`
-	Back    string   `json:"back"`
-	Choices []string `json:"choices"`
+	Front    string   `json:"front"`
+	Back     string   `json:"back"`
+	Choices  []string `json:"choices"`
+	CardType string   `json:"cardType,omitempty"`
+}
+
+// normalizeCardType defaults the card type to BASIC and checks that a cloze
+// card has at least one {{cN::...}} marker on its front.
+func (c *InsertFlashCards) normalizeCardType() error {
+	cardType, ok := cloze.NormalizeCardType(c.CardType)
+	if !ok {
+		return errors.New("cardType must be BASIC or CLOZE")
+	}
+	c.CardType = cardType
+	if cardType == cloze.CardTypeCloze && len(cloze.Ordinals(c.Front)) == 0 {
+		return errors.New(ErrInvalidCloze)
+	}
+	return nil
+}
+
+func normalizeCardTypes(cards []InsertFlashCards) error {
+	for i := range cards {
+		if err := cards[i].normalizeCardType(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type FlashCardSetsCreateRequest struct {
-	Title        string              `json:"title"`
-	Description  string              `json:"description"`
-	IsPublic     string              `json:"isPublic"`
-	FlashCards   *[]InsertFlashCards `json:"flashCards,omitempty"`
-	OwnerIdToken string
-	UserId       string
+	Title          string              `json:"title"`
+	Description    string              `json:"description"`
+	IsPublic       string              `json:"isPublic"`
+	StudyDirection string              `json:"studyDirection"`
+	FrontLang      string              `json:"frontLang"`
+	BackLang       string              `json:"backLang"`
+	FlashCards     *[]InsertFlashCards `json:"flashCards,omitempty"`
+	OwnerIdToken   string
+	UserId         string
 }
 
 func (r *FlashCardSetsCreateRequest) Validate() error {
@@ -36,12 +69,22 @@ func (r *FlashCardSetsCreateRequest) Validate() error {
 	if r.IsPublic != utils.FlagY && r.IsPublic != utils.FlagN {
 		return errors.New("isPublic is only Y or N")
 	}
+	if _, ok := studyitem.NormalizeSetDirection(r.StudyDirection); !ok {
+		return errors.New(ErrInvalidStudyDirection)
+	}
+	if !validLanguages(&r.FrontLang, &r.BackLang) {
+		return errors.New(ErrInvalidLanguage)
+	}
+	if r.FlashCards != nil {
+		return normalizeCardTypes(*r.FlashCards)
+	}
 	return nil
 }
 
 type ResetFlashCardStatusRequest struct {
 	SetID decimal.Decimal `json:"setId"`
 	//Status string          `json:"status"`
+	UserIdToken string // from middleware
 }
 
 func (b *ResetFlashCardStatusRequest) Validate() error {
@@ -56,42 +99,199 @@ func (b *ResetFlashCardStatusRequest) Validate() error {
 	return nil
 }
 
-type DuplicateFlashCardsSetRequest struct {
-	OldSetID     decimal.Decimal `json:"setId"`
+// ForkFlashCardsSetRequest forks a set the caller owns or that is public.
+type ForkFlashCardsSetRequest struct {
+	SourceSetID  decimal.Decimal `json:"setId"`
+	OwnerIdToken string
+	UserId       string
+}
+
+func (r ForkFlashCardsSetRequest) Validate() error {
+	if r.SourceSetID.IsZero() {
+		return errors.New("setId is required")
+	}
+	return nil
+}
+
+// UpstreamCard is the current upstream content of a card.
+type UpstreamCard struct {
+	Id       decimal.Decimal `json:"id"`
+	Front    string          `json:"front"`
+	Back     string          `json:"back"`
+	Choices  []string        `json:"choices"`
+	CardType string          `json:"cardType"`
+}
+
+// UpstreamCardChange pairs a fork card with its upstream card. Upstream is
+// nil when the upstream card was deleted.
+type UpstreamCardChange struct {
+	CardId          decimal.Decimal `json:"cardId"`
+	Front           string          `json:"front"`
+	Back            string          `json:"back"`
+	Choices         []string        `json:"choices"`
+	LocallyModified bool            `json:"locallyModified"`
+	Upstream        *UpstreamCard   `json:"upstream,omitempty"`
+}
+
+type UpstreamChangesResponse struct {
+	SetId         decimal.Decimal      `json:"setId"`
+	UpstreamSetId decimal.Decimal      `json:"upstreamSetId"`
+	New           []UpstreamCard       `json:"new"`
+	Edited        []UpstreamCardChange `json:"edited"`
+	Deleted       []UpstreamCardChange `json:"deleted"`
+}
+
+// FlashCardSetsSyncRequest pulls selected upstream changes into a fork.
+// AddCardIds are upstream card ids; UpdateCardIds and DeleteCardIds are ids
+// of the fork's own cards. Cards edited in the fork are skipped on update
+// unless OverwriteLocal is "Y".
+type FlashCardSetsSyncRequest struct {
+	SetId          decimal.Decimal   `json:"setId"`
+	AddCardIds     []decimal.Decimal `json:"addCardIds"`
+	UpdateCardIds  []decimal.Decimal `json:"updateCardIds"`
+	DeleteCardIds  []decimal.Decimal `json:"deleteCardIds"`
+	OverwriteLocal string            `json:"overwriteLocal"`
+	UserIdToken    string            // from middleware
+	UserId         string            // from middleware
+}
+
+func (r FlashCardSetsSyncRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	if len(r.AddCardIds)+len(r.UpdateCardIds)+len(r.DeleteCardIds) == 0 {
+		return errors.New("at least one of addCardIds, updateCardIds or deleteCardIds is required")
+	}
+	if r.OverwriteLocal != "" && r.OverwriteLocal != "Y" && r.OverwriteLocal != "N" {
+		return errors.New("overwriteLocal must be Y or N")
+	}
+	return nil
+}
+
+type FlashCardSetsSyncResponse struct {
+	Added   int               `json:"added"`
+	Updated []decimal.Decimal `json:"updated"`
+	Skipped []decimal.Decimal `json:"skipped"`
+	Deleted int               `json:"deleted"`
+}
+
+// FlashCardSetsMergeRequest moves every card of the source sets to the end
+// of the target set, in the order the sources are given, and then moves the
+// emptied sources to the trash bin.
+type FlashCardSetsMergeRequest struct {
+	TargetSetId  decimal.Decimal   `json:"targetSetId"`
+	SourceSetIds []decimal.Decimal `json:"sourceSetIds"`
 	OwnerIdToken string
 	UserId       string
 }
 
-func (r DuplicateFlashCardsSetRequest) Validate() error {
-	if r.OldSetID.IsZero() {
-		return errors.New("oldSetId is required")
+func (r FlashCardSetsMergeRequest) Validate() error {
+	if r.TargetSetId.IsZero() {
+		return errors.New("targetSetId is required")
+	}
+	if len(r.SourceSetIds) == 0 {
+		return errors.New("sourceSetIds is required")
+	}
+	seen := map[string]bool{r.TargetSetId.String(): true}
+	for _, id := range r.SourceSetIds {
+		if seen[id.String()] {
+			return errors.New("sourceSetIds must be distinct and must not contain targetSetId")
+		}
+		seen[id.String()] = true
 	}
 	return nil
 }
 
-type FlashCardSetsTrackerUpsert struct {
-	SetID        decimal.Decimal `json:"setId"`
-	CardID       decimal.Decimal `json:"cardId"`
-	TrackerType  string          `json:"trackerType"`
-	OwnerIDToken string
+type FlashCardSetsMergeResponse struct {
+	TargetSetId decimal.Decimal `json:"targetSetId"`
+	MovedCards  int             `json:"movedCards"`
+}
+
+const (
+	SplitByRange  = "RANGE"
+	SplitByTag    = "TAG"
+	SplitByStatus = "STATUS"
+)
+
+// SplitRange selects cards by 1-based position in display order, both ends
+// included.
+type SplitRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
 }
 
-func (r FlashCardSetsTrackerUpsert) Validate() error {
-	if r.SetID.IsZero() {
+// FlashCardSetsSplitRequest moves cards out of a set into new sets: one per
+// range, one per tag (a card with several of the tags goes to the first one
+// listed) or one per status (every status found when Statuses is empty).
+// Cards that match nothing stay in the set.
+type FlashCardSetsSplitRequest struct {
+	SetId        decimal.Decimal `json:"setId"`
+	By           string          `json:"by"`
+	Ranges       []SplitRange    `json:"ranges"`
+	Tags         []string        `json:"tags"`
+	Statuses     []string        `json:"statuses"`
+	OwnerIdToken string
+	UserId       string
+}
+
+func (r *FlashCardSetsSplitRequest) Validate() error {
+	if r.SetId.IsZero() {
 		return errors.New("setId is required")
 	}
-	if r.CardID.IsZero() {
-		return errors.New("cardId is required")
+	r.By = strings.ToUpper(strings.TrimSpace(r.By))
+	switch r.By {
+	case SplitByRange:
+		if len(r.Ranges) == 0 {
+			return errors.New("ranges is required")
+		}
+		sorted := append([]SplitRange(nil), r.Ranges...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].From < sorted[j].From })
+		for i, rg := range sorted {
+			if rg.From < 1 || rg.To < rg.From {
+				return errors.New("each range needs 1 <= from <= to")
+			}
+			if i > 0 && rg.From <= sorted[i-1].To {
+				return errors.New("ranges must not overlap")
+			}
+		}
+	case SplitByTag:
+		if len(r.Tags) == 0 {
+			return errors.New("tags is required")
+		}
+	case SplitByStatus:
+	default:
+		return errors.New("by must be RANGE, TAG or STATUS")
 	}
 	return nil
 }
 
+type FlashCardSetsSplitPart struct {
+	SetId     decimal.Decimal `json:"setId"`
+	Title     string          `json:"title"`
+	CardCount int             `json:"cardCount"`
+}
+
+type FlashCardSetsSplitResponse struct {
+	SetId     decimal.Decimal          `json:"setId"`
+	Remaining int                      `json:"remaining"`
+	NewSets   []FlashCardSetsSplitPart `json:"newSets"`
+}
+
 type FlashCardSetsUpdateRequest struct {
-	Id          decimal.Decimal `json:"id"`
-	Title       string          `json:"title"`
-	Description string          `json:"description"`
-	IsPublic    string          `json:"isPublic"`
+	Id             decimal.Decimal `json:"id"`
+	Title          string          `json:"title"`
+	Description    string          `json:"description"`
+	IsPublic       string          `json:"isPublic"`
+	StudyDirection string          `json:"studyDirection"`
+	// FrontLang and BackLang are left unchanged when nil; an empty string
+	// clears them.
+	FrontLang *string `json:"frontLang,omitempty"`
+	BackLang  *string `json:"backLang,omitempty"`
+	// Version is the set version the edit is based on; a stale version is
+	// rejected. It can also be sent as an If-Match header.
+	Version     *int `json:"version,omitempty"`
 	UserId      string
+	UserIdToken string
 }
 
 // Validate ensures we have the minimum required data to run an UPDATE.
@@ -99,12 +299,19 @@ func (r FlashCardSetsUpdateRequest) Validate() error {
 	if r.Id.IsZero() {
 		return errors.New("id is required")
 	}
+	if _, ok := studyitem.NormalizeSetDirection(r.StudyDirection); !ok {
+		return errors.New(ErrInvalidStudyDirection)
+	}
+	if !validLanguages(r.FrontLang, r.BackLang) {
+		return errors.New(ErrInvalidLanguage)
+	}
 	return nil
 }
 
 type FlashCardSetsDeleteRequest struct {
-	Id     decimal.Decimal `json:"id"`
-	UserId string
+	Id          decimal.Decimal `json:"id"`
+	UserId      string
+	UserIdToken string `json:"-"`
 }
 
 func (r FlashCardSetsDeleteRequest) Validate() error {
@@ -119,6 +326,10 @@ type FlashCardSetsListRequest struct {
 	Size     decimal.Decimal `json:"size"`
 	IsPublic string          `json:"isPublic"`
 	SearchBy string          `json:"searchBy"`
+	// Tags keeps sets carrying any of the caller's tags with these names.
+	Tags []string `json:"tags"`
+	// FolderId keeps sets filed in the caller's folder or any of its subfolders.
+	FolderId *decimal.Decimal `json:"folderId"`
 }
 
 func (r FlashCardSetsListRequest) Validate() error {
@@ -135,13 +346,22 @@ func (r FlashCardSetsListRequest) Validate() error {
 }
 
 type FlashCardSetsListResponseDetails struct {
-	SetId        decimal.Decimal `json:"setId"`
-	Title        string          `json:"title"`
-	Description  string          `json:"description"`
-	IsPublic     string          `json:"isPublic"`
-	OwnerTokenId string          `json:"ownerTokenId"`
-	OwnerName    string          `json:"ownerName"`
-	Term         decimal.Decimal `json:"term"`
+	SetId           decimal.Decimal `json:"setId"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	IsPublic        string          `json:"isPublic"`
+	StudyDirection  string          `json:"studyDirection"`
+	FrontLang       string          `json:"frontLang"`
+	BackLang        string          `json:"backLang"`
+	ForkedFromSetId *int64          `json:"forkedFromSetId"`
+	OwnerTokenId    string          `json:"ownerTokenId"`
+	OwnerName       string          `json:"ownerName"`
+	Version         int             `json:"version"`
+	MyRole          string          `json:"myRole"` // OWNER, EDITOR, VIEWER or "" for public sets
+	Term            decimal.Decimal `json:"term"`   // live cards only
+	FolderId        *int64          `json:"folderId"`
+	Tags            []string        `json:"tags"`
+	Progress        SetProgress     `json:"progress"`
 }
 type FlashCardSetsListResponse struct {
 	Content       []FlashCardSetsListResponseDetails `json:"content"`
@@ -150,44 +370,133 @@ type FlashCardSetsListResponse struct {
 }
 
 type FlashCardSetsInquiryResponse struct {
-	Id        decimal.Decimal `json:"id"`
-	Front     string          `json:"front"`
-	Back      string          `json:"back"`
-	Choices   []string        `json:"choices"`
-	Status    string          `json:"status"`
-	CreateAt  time.Time       `json:"createAt"`
-	OwnerName string          `json:"ownerName"`
-	IsCurrent bool            `json:"isCurrent"`
-	Seq       decimal.Decimal `json:"seq"`
-}
-
-// TODO can import with update setId a
+	Id        decimal.Decimal        `json:"id"`
+	Front     string                 `json:"front"`
+	Back      string                 `json:"back"`
+	Choices   []string               `json:"choices"`
+	Status    string                 `json:"status"`
+	CardType  string                 `json:"cardType"`
+	ClozeOrds []int                  `json:"clozeOrds,omitempty"`
+	CreateAt  time.Time              `json:"createAt"`
+	OwnerName string                 `json:"ownerName"`
+	IsCurrent bool                   `json:"isCurrent"`
+	Seq       decimal.Decimal        `json:"seq"`
+	Version   int                    `json:"version"`
+	Progress  CardProgress           `json:"progress"` // of the caller
+	Media     []cardmedia.Attachment `json:"media"`
+	Audio     tts.CardAudio          `json:"audio"`
+}
+
+const (
+	ImportModeAppend  = "append"
+	ImportModeReplace = "replace"
+	ImportModeUpsert  = "upsert"
+)
+
+// FlashCardsSetsCsvImportRequest creates a new set, or imports into the
+// caller's existing set when SetId is given.
 type FlashCardsSetsCsvImportRequest struct {
-	SetId        decimal.Decimal `json:"setId"`
-	CommandRec   string          `json:"commandRec"`
-	Title        string          `json:"title"`
-	Description  string          `json:"description"`
-	IsPublic     string          `json:"isPublic"`
-	File         string          `json:"file"` // base64‑encoded CSV
-	OwnerIdToken string          `json:"-"`
-	UserId       string          `json:"-"`
+	SetId          decimal.Decimal `json:"setId" form:"setId"`
+	ImportMode     string          `json:"importMode" form:"importMode"`       // append|replace|upsert, only with setId
+	DeleteMissing  string          `json:"deleteMissing" form:"deleteMissing"` // Y = upsert soft-deletes cards not in the file
+	CommandRec     string          `json:"commandRec" form:"commandRec"`
+	HasHeader      string          `json:"hasHeader" form:"hasHeader"` // Y|N, empty = auto-detect
+	DryRun         string          `json:"dryRun" form:"dryRun"`       // Y = validate and preview only
+	PreviewSize    int             `json:"previewSize" form:"previewSize"`
+	SkipDuplicates string          `json:"skipDuplicates" form:"skipDuplicates"` // Y = drop repeated fronts instead of importing them with a warning
+	Title          string          `json:"title" form:"title"`
+	Description    string          `json:"description" form:"description"`
+	IsPublic       string          `json:"isPublic" form:"isPublic"`
+	File           string          `json:"file" form:"-"` // base64‑encoded CSV, JSON body only
+	OwnerIdToken   string          `json:"-" form:"-"`
+	UserId         string          `json:"-" form:"-"`
 }
 
 func (r FlashCardsSetsCsvImportRequest) Validate() error {
-	if r.Title == "" {
-		return errors.New("title is required")
+	if !r.SetId.IsZero() {
+		switch r.ImportMode {
+		case "", ImportModeAppend, ImportModeReplace, ImportModeUpsert:
+		default:
+			return errors.New("importMode must be append, replace or upsert")
+		}
+	} else if r.ImportMode != "" {
+		return errors.New("importMode requires setId")
 	}
-	if r.Description == "" {
-		return errors.New("description is required")
+	if r.DeleteMissing != "" && r.DeleteMissing != utils.FlagY && r.DeleteMissing != utils.FlagN {
+		return errors.New("deleteMissing must be Y or N")
 	}
-	if r.IsPublic != "Y" && r.IsPublic != "N" {
-		return errors.New("isPublic must be Y or N")
+	if r.DeleteMissing == utils.FlagY && r.ImportMode != ImportModeUpsert {
+		return errors.New("deleteMissing is only allowed with upsert")
 	}
-	if r.File == "" {
-		return errors.New("file (base64 CSV) is required")
+	if r.DryRun != utils.FlagY && r.SetId.IsZero() {
+		if r.Title == "" {
+			return errors.New("title is required")
+		}
+		if r.Description == "" {
+			return errors.New("description is required")
+		}
+		if r.IsPublic != "Y" && r.IsPublic != "N" {
+			return errors.New("isPublic must be Y or N")
+		}
 	}
 	if r.CommandRec != "" && len([]rune(r.CommandRec)) != 1 {
 		return errors.New("commandRec must be exactly one character")
 	}
+	if r.HasHeader != "" && r.HasHeader != utils.FlagY && r.HasHeader != utils.FlagN {
+		return errors.New("hasHeader must be Y or N")
+	}
+	if r.DryRun != "" && r.DryRun != utils.FlagY && r.DryRun != utils.FlagN {
+		return errors.New("dryRun must be Y or N")
+	}
+	if r.PreviewSize < 0 {
+		return errors.New("previewSize must not be negative")
+	}
+	if r.SkipDuplicates != "" && r.SkipDuplicates != utils.FlagY && r.SkipDuplicates != utils.FlagN {
+		return errors.New("skipDuplicates must be Y or N")
+	}
+	return nil
+}
+
+type ImportIntoFlashCardsSetRequest struct {
+	SetId         decimal.Decimal
+	Mode          string
+	DeleteMissing bool
+	Cards         []InsertFlashCards
+	OwnerIdToken  string
+	UserId        string
+}
+
+type ImportIntoFlashCardsSetResult struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+}
+
+type FlashCardsSetsApkgImportRequest struct {
+	Title        string `form:"title"`
+	Description  string `form:"description"`
+	IsPublic     string `form:"isPublic"`
+	OwnerIdToken string `form:"-"`
+	UserId       string `form:"-"`
+}
+
+func (r FlashCardsSetsApkgImportRequest) Validate() error {
+	if r.IsPublic != "" && r.IsPublic != utils.FlagY && r.IsPublic != utils.FlagN {
+		return errors.New("isPublic must be Y or N")
+	}
 	return nil
 }
+
+type FlashCardsSetsApkgImportResponse struct {
+	Title    string `json:"title"`
+	Imported int    `json:"imported"`
+	Skipped  int    `json:"skipped"`
+	Media    int    `json:"media"`
+	// Issues lists the skipped notes and those imported without their
+	// images or sounds.
+	Issues []AnkiNoteIssue `json:"issues,omitempty"`
+	// Duplicates lists imported notes whose front is already in one of
+	// the caller's sets.
+	Duplicates []DuplicateWarning `json:"duplicates,omitempty"`
+}
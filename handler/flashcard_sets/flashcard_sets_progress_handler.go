@@ -0,0 +1,36 @@
+package flashcard_sets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewSetProgressHandler(
+	getSetProgressFunc GetSetProgressFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+
+		res, err := getSetProgressFunc(ctx, logger, setId, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
@@ -0,0 +1,65 @@
+package flashcard_sets
+
+import (
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Card states derived from the caller's SRS boxes. A card with several SRS
+// rows (cloze blanks, reverse direction) is as far as its weakest row.
+const (
+	CardStateNew      = "NEW"
+	CardStateLearning = "LEARNING"
+	CardStateMature   = "MATURE"
+
+	// matureBox is the first box reviewed at intervals of a week or more.
+	matureBox = 4
+)
+
+// SetProgress summarises the caller's study of a set.
+type SetProgress struct {
+	NewCards      int64      `json:"newCards"`
+	LearningCards int64      `json:"learningCards"`
+	MatureCards   int64      `json:"matureCards"`
+	DueToday      int64      `json:"dueToday"`
+	LastStudiedAt *time.Time `json:"lastStudiedAt"`
+	// MasteryPercent is the share of live cards that are mature.
+	MasteryPercent int `json:"masteryPercent"`
+}
+
+func (p *SetProgress) fillMastery(term int64) {
+	if term > 0 {
+		p.MasteryPercent = int(math.Round(float64(p.MatureCards) * 100 / float64(term)))
+	}
+}
+
+// CardProgress is the caller's SRS state on one card.
+type CardProgress struct {
+	State        string     `json:"state"`
+	Box          *int       `json:"box"`
+	Due          bool       `json:"due"`
+	LastReviewAt *time.Time `json:"lastReviewAt"`
+}
+
+func newCardProgress(box *int16, due *bool, lastReviewAt *time.Time) CardProgress {
+	p := CardProgress{State: CardStateNew, LastReviewAt: lastReviewAt}
+	if box == nil {
+		return p
+	}
+	b := int(*box)
+	p.Box = &b
+	p.State = CardStateLearning
+	if b >= matureBox {
+		p.State = CardStateMature
+	}
+	p.Due = due != nil && *due
+	return p
+}
+
+type SetProgressResponse struct {
+	SetId decimal.Decimal `json:"setId"`
+	Term  int64           `json:"term"`
+	SetProgress
+}
@@ -0,0 +1,110 @@
+package flashcard_sets
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+// dueBeforeSQL is the end of today; reviews scheduled before it are due.
+const dueBeforeSQL = `date_trunc('day', now()) + interval '1 day'`
+
+// cardStudyItemsSQL lists the study items of card f of set s: one per cloze
+// number, or FORWARD, plus REVERSE in a BOTH set, for a basic card. It is
+// meant to be joined LATERAL.
+const cardStudyItemsSQL = `
+                    SELECT 0 AS cloze_ord, 'FORWARD' AS direction
+                     WHERE f.card_type <> 'CLOZE'
+                    UNION ALL
+                    SELECT 0, 'REVERSE'
+                     WHERE f.card_type <> 'CLOZE'
+                       AND s.study_direction = 'BOTH'
+                    UNION ALL
+                    SELECT DISTINCT m[1]::int, 'FORWARD'
+                      FROM regexp_matches(coalesce(f.front, ''), '\{\{c(\d+?)::.*?\}\}', 'g') AS m
+                     WHERE f.card_type = 'CLOZE'
+                       AND m[1] ~ '^0*[1-9]\d{0,3}$'
+`
+
+// cardItemProgressSQL sums up one card over its study items i, left joined
+// to the SRS rows r of the user. The card is new until one item has been
+// reviewed; after that an item never reviewed counts as box 0 and as due.
+const cardItemProgressSQL = `
+                    CASE WHEN bool_or(r.card_id IS NOT NULL) THEN min(coalesce(r.box, 0)) END AS box,
+                    coalesce(bool_or(r.card_id IS NOT NULL)
+                             AND bool_or(r.card_id IS NULL
+                                         OR r.next_review_at IS NULL
+                                         OR r.next_review_at < ` + dueBeforeSQL + `), false) AS due,
+                    max(r.last_review_at) AS last_review_at
+`
+
+// setProgressSQL aggregates the SRS state of user $1 over the live cards of
+// the set aliased s, per study item as above. It is meant to be joined
+// LATERAL. Box 4 is matureBox.
+const setProgressSQL = `
+            SELECT count(*)                                AS term,
+                   count(*) FILTER (WHERE c.box IS NULL)   AS new_cards,
+                   count(*) FILTER (WHERE c.box < 4)       AS learning_cards,
+                   count(*) FILTER (WHERE c.box >= 4)      AS mature_cards,
+                   count(*) FILTER (WHERE c.due)           AS due_today,
+                   max(c.last_review_at)                   AS last_studied_at
+              FROM (
+                    SELECT ` + cardItemProgressSQL + `
+                      FROM tbl_flashcards f
+                      LEFT JOIN LATERAL (` + cardStudyItemsSQL + `) i ON true
+                      LEFT JOIN tbl_user_flashcard_srs r
+                             ON r.card_id = f.id
+                            AND r.user_id_token = $1
+                            AND r.cloze_ord = i.cloze_ord
+                            AND r.direction = i.direction
+                     WHERE f.set_id = s.id
+                       AND f.is_deleted = 'N'
+                     GROUP BY f.id
+                   ) c
+`
+
+type GetSetProgressFunc func(ctx context.Context, logger *zap.Logger, setID int64, userIdToken string) (SetProgressResponse, error)
+
+// NewGetSetProgress returns the caller's progress on one live set, the same
+// summary the list reports per set. A set the caller cannot see, as in the
+// list, is not found.
+func NewGetSetProgress(db *pgxpool.Pool) GetSetProgressFunc {
+	const progressSQL = `
+        SELECT p.*
+          FROM tbl_flashcard_sets s
+         CROSS JOIN LATERAL (` + setProgressSQL + `) p
+         WHERE s.id = $2
+           AND s.is_deleted = 'N'
+           AND (s.owner_user_token = $1
+                OR s.is_public = 'Y'
+                OR EXISTS (SELECT 1
+                             FROM tbl_flashcard_set_collaborators c
+                            WHERE c.set_id = s.id
+                              AND c.user_id_token = $1
+                              AND c.status = 'ACCEPTED'))
+    `
+	return func(ctx context.Context, logger *zap.Logger, setID int64, userIdToken string) (SetProgressResponse, error) {
+		resp := SetProgressResponse{SetId: decimal.NewFromInt(setID)}
+		if err := db.QueryRow(ctx, progressSQL, userIdToken, setID).Scan(
+			&resp.Term,
+			&resp.NewCards,
+			&resp.LearningCards,
+			&resp.MatureCards,
+			&resp.DueToday,
+			&resp.LastStudiedAt,
+		); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return resp, errors.New(api.NotFound)
+			}
+			logger.Error("load set progress failed", zap.Error(err), zap.Int64("set_id", setID))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		resp.fillMastery(resp.Term)
+		return resp, nil
+	}
+}
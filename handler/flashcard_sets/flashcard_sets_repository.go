@@ -12,14 +12,14 @@ import (
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
-const (
-	FlashCardPageType     = "flashCardPageType"
-	FlashCardPracticeType = "flashCardPracticeType"
-)
-
 type InsertFlashCardsSetFunc func(ctx context.Context, logger *zap.Logger, sets FlashCardSetsCreateRequest) error
 
 func NewInsertFlashCardsSet(db *pgxpool.Pool) InsertFlashCardsSetFunc {
@@ -42,13 +42,16 @@ func NewInsertFlashCardsSet(db *pgxpool.Pool) InsertFlashCardsSetFunc {
 
 		const insertSetSQL = `
             INSERT INTO tbl_flashcard_sets
-                (owner_user_token, title, description, is_public, create_by)
-            VALUES ($1,$2,$3,$4,$5)
+                (owner_user_token, title, description, is_public, create_by, study_direction,
+                 front_lang, back_lang)
+            VALUES ($1,$2,$3,$4,$5,$6,NULLIF($7, ''),NULLIF($8, ''))
             RETURNING id
         `
+		studyDirection, _ := studyitem.NormalizeSetDirection(sets.StudyDirection)
 		var setID int
 		if err = tx.QueryRow(ctx, insertSetSQL,
-			sets.OwnerIdToken, sets.Title, sets.Description, sets.IsPublic, sets.UserId,
+			sets.OwnerIdToken, sets.Title, sets.Description, sets.IsPublic, sets.UserId, studyDirection,
+			sets.FrontLang, sets.BackLang,
 		).Scan(&setID); err != nil {
 			logger.Error("failed to insert flashcard_sets", zap.Error(err))
 			return errors.New(api.SomeThingWentWrong)
@@ -63,7 +66,7 @@ func NewInsertFlashCardsSet(db *pgxpool.Pool) InsertFlashCardsSetFunc {
 				valueStrings []string
 				valueArgs    []interface{}
 			)
-			// each card has 7 columns: set_id, front, back, choices, status, create_by, seq
+			// each card has 7 columns: set_id, front, back, choices, create_by, seq, card_type
 			for i, card := range cards {
 				finalChoices := card.Choices
 				if len(finalChoices) < 4 {
@@ -83,16 +86,16 @@ func NewInsertFlashCardsSet(db *pgxpool.Pool) InsertFlashCardsSetFunc {
 					card.Front,
 					card.Back,
 					finalChoices,
-					CardStatusStudying,
 					sets.UserId,
 					i, // seq
+					cardTypeOrBasic(card.CardType),
 				)
 			}
 
 			// assemble and execute single INSERT
 			insertCardsSQL := fmt.Sprintf(`
                 INSERT INTO tbl_flashcards
-                    (set_id, front, back, choices, status, create_by, seq)
+                    (set_id, front, back, choices, create_by, seq, card_type)
                 VALUES %s
             `, strings.Join(valueStrings, ","))
 			if _, err = tx.Exec(ctx, insertCardsSQL, valueArgs...); err != nil {
@@ -106,148 +109,31 @@ func NewInsertFlashCardsSet(db *pgxpool.Pool) InsertFlashCardsSetFunc {
 	}
 }
 
-type DuplicateFlashCardsSetFunc func(
-	ctx context.Context,
-	logger *zap.Logger,
-	req DuplicateFlashCardsSetRequest,
-) (newSetID int, err error)
-
-func NewDuplicateFlashCardsSet(db *pgxpool.Pool) DuplicateFlashCardsSetFunc {
-	return func(
-		ctx context.Context,
-		logger *zap.Logger,
-		req DuplicateFlashCardsSetRequest,
-	) (newSetID int, err error) {
-
-		tx, err := db.Begin(ctx)
-		if err != nil {
-			logger.Error("tx begin failed", zap.Error(err))
-			return 0, errors.New(api.SomeThingWentWrong)
-		}
-		defer func() {
-			if err != nil {
-				if rbErr := tx.Rollback(ctx); rbErr != nil {
-					logger.Error("tx rollback failed", zap.Error(rbErr))
-				}
-			} else if cmErr := tx.Commit(ctx); cmErr != nil {
-				logger.Error("tx commit failed", zap.Error(cmErr))
-				err = errors.New(api.SomeThingWentWrong)
-			}
-		}()
-		const dupSetSQL = `
-		INSERT INTO tbl_flashcard_sets
-				(owner_user_token, title, description, is_public, create_by)
-		SELECT  $1       , title, description, is_public, $2
-		FROM    tbl_flashcard_sets
-		WHERE   id = $3
-		RETURNING id;
-`
-		if err = tx.QueryRow(
-			ctx, dupSetSQL,
-			req.OwnerIdToken,
-			req.UserId,
-			req.OldSetID,
-		).Scan(&newSetID); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return 0, errors.New(api.NotFound)
-			}
-			logger.Error("duplicate set failed", zap.Error(err))
-			return 0, errors.New(api.SomeThingWentWrong)
-		}
-
-		const dupCardsSQL = `
-			INSERT INTO tbl_flashcards
-					(set_id, front, back, choices, status, create_by, seq)
-			SELECT  $1      , front, back, choices, status, $2       , seq
-			FROM    tbl_flashcards
-			WHERE   set_id = $3;
-		`
-		if _, err = tx.Exec(
-			ctx, dupCardsSQL,
-			newSetID,
-			req.UserId,
-			req.OldSetID,
-		); err != nil {
-			logger.Error("duplicate cards failed", zap.Error(err), zap.Int("new_set_id", newSetID))
-			return 0, errors.New(api.SomeThingWentWrong)
-		}
-		return newSetID, nil
-	}
-}
-
-type InsertAndMergeFlashCardSetsTrackerFunc func(
-	ctx context.Context,
-	logger *zap.Logger,
-	row FlashCardSetsTrackerUpsert,
-) error
-
-func NewInsertAndMergeFlashCardSetsTracker(
-	db *pgxpool.Pool,
-) InsertAndMergeFlashCardSetsTrackerFunc {
-	const upsertSQL = `
-		INSERT INTO public.tbl_flashcard_sets_tracker
-				(set_id, user_id_token,tracker_type, card_id)
-		VALUES ($1, $2, $3,$4)
-		ON CONFLICT (user_id_token, tracker_type, set_id)
-		DO UPDATE
-		   SET card_id = EXCLUDED.card_id;    
-		`
-
-	return func(
-		ctx context.Context,
-		logger *zap.Logger,
-		row FlashCardSetsTrackerUpsert,
-	) (err error) {
-
-		tx, err := db.Begin(ctx)
-		if err != nil {
-			logger.Error("tx begin failed", zap.Error(err))
-			return errors.New(api.SomeThingWentWrong)
-		}
-		defer func() {
-			if err != nil {
-				_ = tx.Rollback(ctx)
-			} else if cmErr := tx.Commit(ctx); cmErr != nil {
-				logger.Error("tx commit failed", zap.Error(cmErr))
-				err = errors.New(api.SomeThingWentWrong)
-			}
-		}()
-		if row.TrackerType == "" {
-			row.TrackerType = FlashCardPageType
-		}
-
-		if _, err = tx.Exec(
-			ctx, upsertSQL,
-			row.SetID,
-			row.OwnerIDToken,
-			row.TrackerType,
-			row.CardID,
-		); err != nil {
-			logger.Error("upsert tracker failed", zap.Error(err))
-			return errors.New(api.SomeThingWentWrong)
-		}
-
-		return nil
-	}
-}
-
 type ResetStatusFlashCardsFunc func(
 	ctx context.Context,
 	logger *zap.Logger,
 	req ResetFlashCardStatusRequest,
 ) error
 
+// NewResetStatusFlashCards resets the caller's study status of a set and
+// abandons their active practice sessions on it; other users studying the
+// same set are not affected.
 func NewResetStatusFlashCards(db *pgxpool.Pool) ResetStatusFlashCardsFunc {
-	const updateSQL = `
-		UPDATE tbl_flashcards
-		SET    status = $1
-		WHERE  set_id = $2;
+	const deleteStatusSQL = `
+		DELETE FROM tbl_user_flashcard_status us
+		USING  tbl_flashcards f
+		WHERE  f.id = us.card_id
+		  AND  f.set_id = $1
+		  AND  us.user_id_token = $2;
+	`
+	const abandonSessionsSQL = `
+		UPDATE tbl_practice_sessions
+		SET    status = 'ABANDONED',
+		       last_activity_at = now()
+		WHERE  set_id = $1
+		  AND  user_id_token = $2
+		  AND  status = 'ACTIVE';
 	`
-	const deleteTracker = `
-		delete
-		from tbl_flashcard_sets_tracker
-		where set_id=$1;    
-		`
 	return func(
 		ctx context.Context,
 		logger *zap.Logger,
@@ -272,13 +158,12 @@ func NewResetStatusFlashCards(db *pgxpool.Pool) ResetStatusFlashCardsFunc {
 		//if  req.Status == ""{
 		//	req.Status = CardStatusStudying
 		//}
-		if _, err = tx.Exec(ctx, updateSQL, CardStatusStudying, req.SetID); err != nil {
+		if _, err = tx.Exec(ctx, deleteStatusSQL, req.SetID, req.UserIdToken); err != nil {
 			logger.Error("reset status failed",
 				zap.Error(err), zap.Any("set_id", req.SetID))
 			return errors.New(api.SomeThingWentWrong)
 		}
-		// TODO change to soft deleted or something
-		if _, err = tx.Exec(ctx, deleteTracker, req.SetID); err != nil {
+		if _, err = tx.Exec(ctx, abandonSessionsSQL, req.SetID, req.UserIdToken); err != nil {
 			logger.Error("reset status failed",
 				zap.Error(err), zap.Any("set_id", req.SetID))
 			return errors.New(api.SomeThingWentWrong)
@@ -296,70 +181,188 @@ func toPointerSlice(in []InsertFlashCards) []*InsertFlashCards {
 	return out
 }
 
-type UpdateFlashCardSetsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardSetsUpdateRequest) error
+type UpdateFlashCardSetsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardSetsUpdateRequest) (int, error)
 
+// NewUpdateFlashCardSets lets the owner and editors update a set; only the
+// owner may change isPublic. It returns the new version of the set.
 func NewUpdateFlashCardSets(db *pgxpool.Pool) UpdateFlashCardSetsFunc {
-	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsUpdateRequest) error {
+	const lockSQL = `
+        SELECT version
+          FROM tbl_flashcard_sets
+         WHERE id = $1
+           AND is_deleted = 'N'
+           FOR UPDATE
+    `
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsUpdateRequest) (version int, err error) {
 		if err := req.Validate(); err != nil {
-			return err
+			return 0, err
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if err = tx.QueryRow(ctx, lockSQL, req.Id).Scan(&version); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("lock flashcard_sets failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		role, err := setRole(ctx, tx, req.Id, req.UserIdToken)
+		if err != nil {
+			logger.Error("resolve set role failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if role == "" {
+			return 0, errors.New(api.NotFound)
+		}
+		if !canEditSet(role) || (req.IsPublic != "" && role != SetRoleOwner) {
+			return 0, errors.New(ErrSetAccessDenied)
+		}
+		if req.Version != nil && *req.Version != version {
+			return 0, errors.New(ErrVersionConflict)
 		}
 
 		var (
 			setClauses []string
+			fields     []string
 			args       []interface{}
 			idx        = 1
 		)
 		if req.Title != "" {
 			setClauses = append(setClauses, fmt.Sprintf("title        = $%d", idx))
+			fields = append(fields, "title")
 			args = append(args, req.Title)
 			idx++
 		}
 		if req.Description != "" {
 			setClauses = append(setClauses, fmt.Sprintf("description  = $%d", idx))
+			fields = append(fields, "description")
 			args = append(args, req.Description)
 			idx++
 		}
 		if req.IsPublic != "" {
 			setClauses = append(setClauses, fmt.Sprintf("is_public    = $%d", idx))
+			fields = append(fields, "isPublic")
 			args = append(args, req.IsPublic)
 			idx++
 		}
+		if req.StudyDirection != "" {
+			studyDirection, _ := studyitem.NormalizeSetDirection(req.StudyDirection)
+			setClauses = append(setClauses, fmt.Sprintf("study_direction = $%d", idx))
+			fields = append(fields, "studyDirection")
+			args = append(args, studyDirection)
+			idx++
+		}
+		if req.FrontLang != nil {
+			setClauses = append(setClauses, fmt.Sprintf("front_lang   = NULLIF($%d, '')", idx))
+			fields = append(fields, "frontLang")
+			args = append(args, *req.FrontLang)
+			idx++
+		}
+		if req.BackLang != nil {
+			setClauses = append(setClauses, fmt.Sprintf("back_lang    = NULLIF($%d, '')", idx))
+			fields = append(fields, "backLang")
+			args = append(args, *req.BackLang)
+			idx++
+		}
 		setClauses = append(setClauses, fmt.Sprintf("update_by    = $%d", idx))
 		args = append(args, req.UserId)
 		idx++
 		setClauses = append(setClauses, "update_at    = now()")
+		setClauses = append(setClauses, "version      = version + 1")
 
 		sql := fmt.Sprintf(`
             UPDATE tbl_flashcard_sets
                SET %s
              WHERE id = $%d
+         RETURNING version
         `, strings.Join(setClauses, ",\n                 "), idx)
 		args = append(args, req.Id)
 
-		if _, err := db.Exec(ctx, sql, args...); err != nil {
+		if err = tx.QueryRow(ctx, sql, args...).Scan(&version); err != nil {
 			logger.Error("failed to update flashcard_sets", zap.Error(err))
-			return errors.New(api.SomeThingWentWrong)
+			return 0, errors.New(api.SomeThingWentWrong)
 		}
-		return nil
+		if err = recordSetActivity(ctx, tx, req.Id, nil, ActivitySetUpdate,
+			map[string]interface{}{"fields": fields, "version": version},
+			req.UserIdToken, req.UserId,
+		); err != nil {
+			logger.Error("record activity failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return version, nil
 	}
 }
 
 type DeleteFlashCardSetsFunc func(ctx context.Context, logger *zap.Logger, req FlashCardSetsDeleteRequest) error
 
+// NewDeleteFlashCardSets moves a set to the trash. Only the owner may, as
+// only the owner's trash lists the set to restore it.
 func NewDeleteFlashCardSets(db *pgxpool.Pool) DeleteFlashCardSetsFunc {
-	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsDeleteRequest) error {
+	return func(ctx context.Context, logger *zap.Logger, req FlashCardSetsDeleteRequest) (err error) {
 		if err := req.Validate(); err != nil {
 			return err
 		}
 
-		sql := `
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if err = lockFlashCardSetTx(ctx, tx, req.Id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("lock flashcard set failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		role, err := setRole(ctx, tx, req.Id, req.UserIdToken)
+		if err != nil {
+			logger.Error("resolve set role failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if role == "" {
+			return errors.New(api.NotFound)
+		}
+		if role != SetRoleOwner {
+			return errors.New(ErrSetAccessDenied)
+		}
+
+		const sql = `
             UPDATE tbl_flashcard_sets
                SET is_deleted = 'Y',
+                   deleted_at = now(),
                    update_by  = $1,
                    update_at  = now()
              WHERE id = $2
+               AND is_deleted = 'N'
         `
-		if _, err := db.Exec(ctx, sql, req.UserId, req.Id); err != nil {
+		if _, err = tx.Exec(ctx, sql, req.UserId, req.Id); err != nil {
 			logger.Error("failed to delete flashcard_sets", zap.Error(err))
 			return errors.New(api.SomeThingWentWrong)
 		}
@@ -386,22 +389,61 @@ func NewListFlashCardSets(db *pgxpool.Pool) ListFlashCardSetsFunc {
 		size := int(req.Size.IntPart())
 		offset := (page - 1) * size
 
-		pattern := "%" + req.SearchBy + "%"
+		pattern := "%" + utils.EscapeLike(strings.ToLower(strings.TrimSpace(req.SearchBy))) + "%"
 
 		const countSQL = `
 			SELECT count(*) 
-			  FROM tbl_flashcard_sets
+			  FROM tbl_flashcard_sets s
 			 	 WHERE
 				(
 					owner_user_token = $1
 					OR ($2 = 'Y' AND is_public = 'Y')
+					OR EXISTS (
+						SELECT 1
+						  FROM tbl_flashcard_set_collaborators c
+						 WHERE c.set_id = s.id
+						   AND c.user_id_token = $1
+						   AND c.status = 'ACCEPTED'
+					)
+				)
+			   AND (
+					search_vector @@ websearch_to_tsquery('simple', $4)
+					OR lower(coalesce(title, '') || ' ' || coalesce(description, '')) LIKE $3
+				)
+			   AND (
+					coalesce(cardinality($5::text[]), 0) = 0
+					OR EXISTS (
+						SELECT 1
+						  FROM tbl_flashcard_set_tags st
+						  JOIN tbl_tags t ON t.id = st.tag_id
+						 WHERE st.set_id = s.id
+						   AND t.user_id_token = $1
+						   AND lower(t.name) = ANY (SELECT lower(btrim(unnest($5::text[]))))
+					)
+				)
+			   AND (
+					$6::int IS NULL
+					OR folder_id IN (
+						WITH RECURSIVE subtree AS (
+							SELECT id
+							  FROM tbl_folders
+							 WHERE id = $6::int
+							   AND user_id_token = $1
+							   AND is_deleted = 'N'
+							UNION
+							SELECT f.id
+							  FROM tbl_folders f
+							  JOIN subtree sub ON f.parent_id = sub.id
+							 WHERE f.is_deleted = 'N'
+						)
+						SELECT id FROM subtree
+					)
 				)
-			   AND (title || ' ' || description) LIKE $3
 				and is_deleted = 'N'
 		`
 		var totalElements int64
 		if err := db.QueryRow(ctx, countSQL,
-			ownerId, req.IsPublic, pattern,
+			ownerId, req.IsPublic, pattern, req.SearchBy, req.Tags, req.FolderId,
 		).Scan(&totalElements); err != nil {
 			logger.Error("failed to count flashcard_sets", zap.Error(err))
 			return resp, errors.New(api.SomeThingWentWrong)
@@ -409,26 +451,87 @@ func NewListFlashCardSets(db *pgxpool.Pool) ListFlashCardSetsFunc {
 
 		totalPages := int64(math.Ceil(float64(totalElements) / float64(size)))
 
+		// the page is cut first so progress is only aggregated for its sets
 		const listSQL = `
-			SELECT id , title, description, is_public, owner_user_token, create_by,
-			     (
-					SELECT COUNT(*) 
-					FROM tbl_flashcards f 
-					WHERE f.set_id = s.id
-				) AS term    
+			WITH page AS (
+			SELECT id , title, description, is_public, study_direction,
+				 coalesce(front_lang, '') AS front_lang, coalesce(back_lang, '') AS back_lang,
+				 forked_from_set_id, owner_user_token, create_by, version,
+				 CASE WHEN owner_user_token = $1 THEN 'OWNER'
+				      ELSE coalesce((
+						SELECT c.role
+						  FROM tbl_flashcard_set_collaborators c
+						 WHERE c.set_id = s.id
+						   AND c.user_id_token = $1
+						   AND c.status = 'ACCEPTED'
+				      ), '')
+				 END AS my_role,
+				 CASE WHEN owner_user_token = $1 THEN folder_id END AS folder_id,
+				 ARRAY(
+					SELECT t.name
+					  FROM tbl_flashcard_set_tags st
+					  JOIN tbl_tags t ON t.id = st.tag_id
+					 WHERE st.set_id = s.id
+					   AND t.user_id_token = $1
+					 ORDER BY lower(t.name)
+				 ) AS tags
 			  FROM tbl_flashcard_sets s
 			 WHERE
 				(
 					owner_user_token = $1
 					OR ($2 = 'Y' AND is_public = 'Y')
+					OR EXISTS (
+						SELECT 1
+						  FROM tbl_flashcard_set_collaborators c
+						 WHERE c.set_id = s.id
+						   AND c.user_id_token = $1
+						   AND c.status = 'ACCEPTED'
+					)
+				)
+			   AND (
+					search_vector @@ websearch_to_tsquery('simple', $6)
+					OR lower(coalesce(title, '') || ' ' || coalesce(description, '')) LIKE $3
+				)
+			   AND (
+					coalesce(cardinality($7::text[]), 0) = 0
+					OR EXISTS (
+						SELECT 1
+						  FROM tbl_flashcard_set_tags st
+						  JOIN tbl_tags t ON t.id = st.tag_id
+						 WHERE st.set_id = s.id
+						   AND t.user_id_token = $1
+						   AND lower(t.name) = ANY (SELECT lower(btrim(unnest($7::text[]))))
+					)
+				)
+			   AND (
+					$8::int IS NULL
+					OR folder_id IN (
+						WITH RECURSIVE subtree AS (
+							SELECT id
+							  FROM tbl_folders
+							 WHERE id = $8::int
+							   AND user_id_token = $1
+							   AND is_deleted = 'N'
+							UNION
+							SELECT f.id
+							  FROM tbl_folders f
+							  JOIN subtree sub ON f.parent_id = sub.id
+							 WHERE f.is_deleted = 'N'
+						)
+						SELECT id FROM subtree
+					)
 				)
-			   AND (title || ' ' || description) LIKE $3
 			 	and is_deleted = 'N'
 			 ORDER BY id
 			 OFFSET $4 LIMIT $5
+			)
+			SELECT s.*, p.*
+			  FROM page s
+			 CROSS JOIN LATERAL (` + setProgressSQL + `) p
+			 ORDER BY s.id
 		`
 		rows, err := db.Query(ctx, listSQL,
-			ownerId, req.IsPublic, pattern, offset, size,
+			ownerId, req.IsPublic, pattern, offset, size, req.SearchBy, req.Tags, req.FolderId,
 		)
 		if err != nil {
 			logger.Error("failed to list flashcard_sets", zap.Error(err))
@@ -444,13 +547,27 @@ func NewListFlashCardSets(db *pgxpool.Pool) ListFlashCardSetsFunc {
 				&d.Title,
 				&d.Description,
 				&d.IsPublic,
+				&d.StudyDirection,
+				&d.FrontLang,
+				&d.BackLang,
+				&d.ForkedFromSetId,
 				&d.OwnerTokenId,
 				&d.OwnerName,
+				&d.Version,
+				&d.MyRole,
+				&d.FolderId,
+				&d.Tags,
 				&d.Term,
+				&d.Progress.NewCards,
+				&d.Progress.LearningCards,
+				&d.Progress.MatureCards,
+				&d.Progress.DueToday,
+				&d.Progress.LastStudiedAt,
 			); err != nil {
 				logger.Error("scan flashcard_sets row failed", zap.Error(err))
 				return resp, errors.New(api.SomeThingWentWrong)
 			}
+			d.Progress.fillMastery(d.Term.IntPart())
 			items = append(items, d)
 		}
 		if err := rows.Err(); err != nil {
@@ -474,21 +591,63 @@ type FlashCardSetsInquiryFunc func(
 	userID string,
 ) ([]FlashCardSetsInquiryResponse, error)
 
-func NewFlashCardSetsInquiry(db *pgxpool.Pool) FlashCardSetsInquiryFunc {
+// NewFlashCardSetsInquiry lists the cards of a set the caller owns,
+// collaborates on or that is public; other sets are not found. An empty
+// userID skips the check, for share links whose token was already resolved.
+func NewFlashCardSetsInquiry(db *pgxpool.Pool, loadMedia cardmedia.CardMediaLoaderFunc, loadAudio tts.CardAudioLoaderFunc) FlashCardSetsInquiryFunc {
+	const visibleSQL = `
+            SELECT EXISTS (
+                SELECT 1
+                  FROM tbl_flashcard_sets s
+                 WHERE s.id = $1
+                   AND s.is_deleted = 'N'
+                   AND (s.owner_user_token = $2
+                        OR s.is_public = 'Y'
+                        OR EXISTS (SELECT 1
+                                     FROM tbl_flashcard_set_collaborators c
+                                    WHERE c.set_id = s.id
+                                      AND c.user_id_token = $2
+                                      AND c.status = 'ACCEPTED'))
+            )
+        `
 	return func(
 		ctx context.Context,
 		logger *zap.Logger,
 		setID int,
 		userID string,
 	) ([]FlashCardSetsInquiryResponse, error) {
+		if userID != "" {
+			var visible bool
+			if err := db.QueryRow(ctx, visibleSQL, setID, userID).Scan(&visible); err != nil {
+				logger.Error("check set visibility failed", zap.Error(err), zap.Int("set_id", setID))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			if !visible {
+				return nil, errors.New(api.NotFound)
+			}
+		}
 		const inquirySQL = `
-            SELECT id, front, back, choices, status, create_at, create_by,seq
-              FROM tbl_flashcards
-             WHERE is_deleted = 'N'
-               AND set_id = $1
-            ORDER BY seq,id
+            SELECT f.id, f.front, f.back, f.choices, coalesce(us.status, 'studying'), f.card_type, f.create_at, f.create_by, f.seq, f.version,
+                   p.box, p.due, p.last_review_at
+              FROM tbl_flashcards f
+              JOIN tbl_flashcard_sets s ON s.id = f.set_id
+              LEFT JOIN tbl_user_flashcard_status us
+                     ON us.card_id = f.id
+                    AND us.user_id_token = $2
+              LEFT JOIN LATERAL (
+                    SELECT ` + cardItemProgressSQL + `
+                      FROM (` + cardStudyItemsSQL + `) i
+                      LEFT JOIN tbl_user_flashcard_srs r
+                             ON r.card_id = f.id
+                            AND r.user_id_token = $2
+                            AND r.cloze_ord = i.cloze_ord
+                            AND r.direction = i.direction
+                   ) p ON true
+             WHERE f.is_deleted = 'N'
+               AND f.set_id = $1
+            ORDER BY f.seq, f.id
         `
-		rows, err := db.Query(ctx, inquirySQL, setID)
+		rows, err := db.Query(ctx, inquirySQL, setID, userID)
 		if err != nil {
 			logger.Error("failed to query flashcards", zap.Error(err), zap.Int("set_id", setID))
 			return nil, errors.New(api.SomeThingWentWrong)
@@ -503,9 +662,14 @@ func NewFlashCardSetsInquiry(db *pgxpool.Pool) FlashCardSetsInquiryFunc {
 				back      string
 				choices   []string
 				status    string
+				cardType  string
 				createAt  time.Time
 				ownerName string
 				seq       decimal.Decimal
+				version   int
+				box       *int16
+				due       *bool
+				reviewAt  *time.Time
 			)
 			if err := rows.Scan(
 				&idInt,
@@ -513,24 +677,36 @@ func NewFlashCardSetsInquiry(db *pgxpool.Pool) FlashCardSetsInquiryFunc {
 				&back,
 				&choices,
 				&status,
+				&cardType,
 				&createAt,
 				&ownerName,
 				&seq,
+				&version,
+				&box,
+				&due,
+				&reviewAt,
 			); err != nil {
 				logger.Error("scan flashcard row failed", zap.Error(err), zap.Int("set_id", setID))
 				return nil, errors.New(api.SomeThingWentWrong)
 			}
 
-			result = append(result, FlashCardSetsInquiryResponse{
+			item := FlashCardSetsInquiryResponse{
 				Id:        decimal.NewFromInt(idInt),
 				Front:     front,
 				Back:      back,
 				Choices:   choices,
 				Status:    status,
+				CardType:  cardType,
 				CreateAt:  createAt,
 				OwnerName: ownerName,
 				Seq:       seq,
-			})
+				Version:   version,
+				Progress:  newCardProgress(box, due, reviewAt),
+			}
+			if cardType == cloze.CardTypeCloze {
+				item.ClozeOrds = cloze.Ordinals(front)
+			}
+			result = append(result, item)
 		}
 
 		if err := rows.Err(); err != nil {
@@ -538,10 +714,39 @@ func NewFlashCardSetsInquiry(db *pgxpool.Pool) FlashCardSetsInquiryFunc {
 			return nil, errors.New(api.SomeThingWentWrong)
 		}
 
+		cardIDs := make([]int64, 0, len(result))
+		for _, r := range result {
+			cardIDs = append(cardIDs, r.Id.IntPart())
+		}
+		media, err := loadMedia(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		audio, err := loadAudio(ctx, logger, cardIDs)
+		if err != nil {
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		for i := range result {
+			result[i].Media = media[result[i].Id.IntPart()]
+			if result[i].Media == nil {
+				result[i].Media = []cardmedia.Attachment{}
+			}
+			result[i].Audio = audio[result[i].Id.IntPart()]
+		}
+
 		if len(result) != 0 {
+			// the current card is where the caller's latest practice session stands
 			sqlTrack := `
-				select card_id from tbl_flashcard_sets_tracker
-				where user_id_token=$1 and set_id = $2
+				select c.card_id
+				  from tbl_practice_sessions ps
+				  join tbl_practice_session_cards c
+				    on c.session_id = ps.id
+				   and c.seq = ps.position
+				 where ps.user_id_token = $1
+				   and ps.set_id = $2
+				   and ps.status = 'ACTIVE'
+				 order by ps.last_activity_at desc, ps.id desc
+				 limit 1
 				`
 			var cardId decimal.Decimal
 			err := db.QueryRow(ctx, sqlTrack, userID, setID).Scan(&cardId)
@@ -560,3 +765,348 @@ func NewFlashCardSetsInquiry(db *pgxpool.Pool) FlashCardSetsInquiryFunc {
 		return result, nil
 	}
 }
+
+type GetAnkiExportDeckFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int,
+	userIdToken string,
+	includeSchedule bool,
+) (AnkiExportDeck, error)
+
+func NewGetAnkiExportDeck(db *pgxpool.Pool) GetAnkiExportDeckFunc {
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int,
+		userIdToken string,
+		includeSchedule bool,
+	) (AnkiExportDeck, error) {
+		var (
+			deck      AnkiExportDeck
+			direction string
+		)
+		const setSQL = `
+			SELECT s.title, COALESCE(s.description, ''), s.study_direction
+			  FROM tbl_flashcard_sets s
+			 WHERE s.id = $1
+			   AND s.is_deleted = 'N'
+			   AND (s.owner_user_token = $2
+			        OR s.is_public = 'Y'
+			        OR EXISTS (SELECT 1
+			                     FROM tbl_flashcard_set_collaborators c
+			                    WHERE c.set_id = s.id
+			                      AND c.user_id_token = $2
+			                      AND c.status = 'ACCEPTED'))
+		`
+		if err := db.QueryRow(ctx, setSQL, setID, userIdToken).Scan(&deck.Title, &deck.Description, &direction); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return deck, errors.New(api.NotFound)
+			}
+			logger.Error("failed to load set for export", zap.Error(err), zap.Int("set_id", setID))
+			return deck, errors.New(api.SomeThingWentWrong)
+		}
+
+		const cardsSQL = `
+			SELECT f.id, f.front, f.back, f.card_type, COALESCE(f.seq, 0)
+			  FROM tbl_flashcards f
+			 WHERE f.set_id = $1
+			   AND f.is_deleted = 'N'
+			 ORDER BY f.seq, f.id
+		`
+		rows, err := db.Query(ctx, cardsSQL, setID)
+		if err != nil {
+			logger.Error("failed to query cards for export", zap.Error(err), zap.Int("set_id", setID))
+			return deck, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		index := map[int64]int{}
+		for rows.Next() {
+			var card AnkiExportCard
+			if err := rows.Scan(&card.Id, &card.Front, &card.Back, &card.CardType, &card.Seq); err != nil {
+				logger.Error("scan export card failed", zap.Error(err), zap.Int("set_id", setID))
+				return deck, errors.New(api.SomeThingWentWrong)
+			}
+			// the study items the card has today: every cloze ordinal, or
+			// FORWARD plus REVERSE when the set is studied both ways
+			if card.CardType == cloze.CardTypeCloze {
+				for _, ord := range cloze.Ordinals(card.Front) {
+					card.Items = append(card.Items, AnkiExportItem{ClozeOrd: ord, Direction: studyitem.DirectionForward})
+				}
+			} else {
+				card.Items = append(card.Items, AnkiExportItem{Direction: studyitem.DirectionForward})
+				if direction == studyitem.SetDirectionBoth {
+					card.Items = append(card.Items, AnkiExportItem{Direction: studyitem.DirectionReverse})
+				}
+			}
+			index[card.Id] = len(deck.Cards)
+			deck.Cards = append(deck.Cards, card)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterating export cards failed", zap.Error(err), zap.Int("set_id", setID))
+			return deck, errors.New(api.SomeThingWentWrong)
+		}
+		rows.Close()
+		if !includeSchedule {
+			return deck, nil
+		}
+
+		// progress on an item the card no longer has (a removed cloze, or
+		// REVERSE after the set went back to FORWARD) has no Anki card to
+		// go on and is left out
+		const srsSQL = `
+			SELECT s.card_id, s.cloze_ord, s.direction, s.box, s.next_review_at, COALESCE(s.total_reviews, 0)
+			  FROM tbl_user_flashcard_srs s
+			  JOIN tbl_flashcards f ON f.id = s.card_id
+			 WHERE f.set_id = $1
+			   AND f.is_deleted = 'N'
+			   AND s.user_id_token = $2
+		`
+		srsRows, err := db.Query(ctx, srsSQL, setID, userIdToken)
+		if err != nil {
+			logger.Error("failed to query srs for export", zap.Error(err), zap.Int("set_id", setID))
+			return deck, errors.New(api.SomeThingWentWrong)
+		}
+		defer srsRows.Close()
+		for srsRows.Next() {
+			var (
+				cardID    int64
+				clozeOrd  int
+				itemDir   string
+				box       *int16
+				nextAt    *time.Time
+				totalRevs int
+			)
+			if err := srsRows.Scan(&cardID, &clozeOrd, &itemDir, &box, &nextAt, &totalRevs); err != nil {
+				logger.Error("scan export srs failed", zap.Error(err), zap.Int("set_id", setID))
+				return deck, errors.New(api.SomeThingWentWrong)
+			}
+			i, ok := index[cardID]
+			if !ok {
+				continue
+			}
+			for j := range deck.Cards[i].Items {
+				item := &deck.Cards[i].Items[j]
+				if item.ClozeOrd != clozeOrd || item.Direction != itemDir {
+					continue
+				}
+				if box != nil {
+					b := int(*box)
+					item.Box = &b
+				}
+				item.NextReviewAt = nextAt
+				item.TotalReviews = totalRevs
+			}
+		}
+		if err := srsRows.Err(); err != nil {
+			logger.Error("iterating export srs failed", zap.Error(err), zap.Int("set_id", setID))
+			return deck, errors.New(api.SomeThingWentWrong)
+		}
+		return deck, nil
+	}
+}
+
+type ImportIntoFlashCardsSetFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req ImportIntoFlashCardsSetRequest,
+) (ImportIntoFlashCardsSetResult, error)
+
+// NewImportIntoFlashCardsSet imports cards into an existing owned set.
+// append adds every card, replace soft-deletes the current cards first, and
+// upsert matches on case-insensitive front so existing card ids (and every
+// user's SRS progress on them) are kept.
+func NewImportIntoFlashCardsSet(db *pgxpool.Pool) ImportIntoFlashCardsSetFunc {
+	const lockSetSQL = `
+		SELECT id
+		  FROM tbl_flashcard_sets
+		 WHERE id = $1
+		   AND owner_user_token = $2
+		   AND is_deleted = 'N'
+		   FOR UPDATE
+	`
+	const deleteAllSQL = `
+		UPDATE tbl_flashcards
+		   SET is_deleted = 'Y',
+		       deleted_at = now(),
+		       update_by  = $2,
+		       update_at  = now()
+		 WHERE set_id = $1
+		   AND is_deleted = 'N'
+	`
+	const existingSQL = `
+		SELECT id, coalesce(front, ''), back, choices, card_type
+		  FROM tbl_flashcards
+		 WHERE set_id = $1
+		   AND is_deleted = 'N'
+		 ORDER BY id
+	`
+	const updateCardSQL = `
+		UPDATE tbl_flashcards
+		   SET back      = $2,
+		       choices   = $3,
+		       update_by = $4,
+		       card_type = $5,
+		       update_at = now(),
+		       version   = version + 1
+		 WHERE id = $1
+	`
+	const deleteMissingSQL = `
+		UPDATE tbl_flashcards
+		   SET is_deleted = 'Y',
+		       deleted_at = now(),
+		       update_by  = $3,
+		       update_at  = now()
+		 WHERE set_id = $1
+		   AND is_deleted = 'N'
+		   AND NOT (id = ANY($2::bigint[]))
+	`
+	type existingCard struct {
+		id       int64
+		back     string
+		choices  []string
+		cardType string
+	}
+
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req ImportIntoFlashCardsSetRequest,
+	) (result ImportIntoFlashCardsSetResult, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var setID int64
+		if err = tx.QueryRow(ctx, lockSetSQL, req.SetId, req.OwnerIdToken).Scan(&setID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return result, errors.New(api.NotFound)
+			}
+			logger.Error("lock flashcard set failed", zap.Error(err), zap.Any("set_id", req.SetId))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+
+		toInsert := req.Cards
+		if req.Mode == ImportModeReplace {
+			tag, execErr := tx.Exec(ctx, deleteAllSQL, setID, req.UserId)
+			if err = execErr; err != nil {
+				logger.Error("replace: delete cards failed", zap.Error(err), zap.Int64("set_id", setID))
+				return result, errors.New(api.SomeThingWentWrong)
+			}
+			result.Deleted = int(tag.RowsAffected())
+		}
+
+		if req.Mode == ImportModeUpsert {
+			rows, qErr := tx.Query(ctx, existingSQL, setID)
+			if err = qErr; err != nil {
+				logger.Error("upsert: load cards failed", zap.Error(err), zap.Int64("set_id", setID))
+				return result, errors.New(api.SomeThingWentWrong)
+			}
+			existing := map[string]existingCard{}
+			for rows.Next() {
+				var (
+					card  existingCard
+					front string
+				)
+				if err = rows.Scan(&card.id, &front, &card.back, &card.choices, &card.cardType); err != nil {
+					rows.Close()
+					logger.Error("upsert: scan card failed", zap.Error(err), zap.Int64("set_id", setID))
+					return result, errors.New(api.SomeThingWentWrong)
+				}
+				key := frontKey(front)
+				if _, dup := existing[key]; !dup {
+					existing[key] = card
+				}
+			}
+			rows.Close()
+			if err = rows.Err(); err != nil {
+				logger.Error("upsert: iterate cards failed", zap.Error(err), zap.Int64("set_id", setID))
+				return result, errors.New(api.SomeThingWentWrong)
+			}
+
+			toInsert = nil
+			keptIDs := []int64{}
+			for _, card := range req.Cards {
+				match, ok := existing[frontKey(card.Front)]
+				if !ok {
+					toInsert = append(toInsert, card)
+					continue
+				}
+				keptIDs = append(keptIDs, match.id)
+
+				choices := card.Choices
+				if len(choices) == 0 {
+					choices = match.choices
+				}
+				cardType := cardTypeOrBasic(card.CardType)
+				if card.Back == match.back && stringSliceEqual(choices, match.choices) && cardType == match.cardType {
+					result.Unchanged++
+					continue
+				}
+				if len(choices) < 4 || !containsString(choices, card.Back) {
+					choices = GetChoices(toPointerSlice(req.Cards), &card)
+				}
+				if err = seedFlashCardRevisionTx(ctx, tx, match.id); err != nil {
+					logger.Error("upsert: seed revision failed", zap.Error(err), zap.Int64("card_id", match.id))
+					return result, errors.New(api.SomeThingWentWrong)
+				}
+				if _, err = tx.Exec(ctx, updateCardSQL, match.id, card.Back, choices, req.UserId, cardType); err != nil {
+					logger.Error("upsert: update card failed", zap.Error(err), zap.Int64("card_id", match.id))
+					return result, errors.New(api.SomeThingWentWrong)
+				}
+				if err = appendFlashCardRevisionTx(ctx, tx, match.id, RevisionActionImport, nil, req.UserId); err != nil {
+					logger.Error("upsert: record revision failed", zap.Error(err), zap.Int64("card_id", match.id))
+					return result, errors.New(api.SomeThingWentWrong)
+				}
+				result.Updated++
+			}
+
+			if req.DeleteMissing {
+				tag, execErr := tx.Exec(ctx, deleteMissingSQL, setID, keptIDs, req.UserId)
+				if err = execErr; err != nil {
+					logger.Error("upsert: delete missing failed", zap.Error(err), zap.Int64("set_id", setID))
+					return result, errors.New(api.SomeThingWentWrong)
+				}
+				result.Deleted = int(tag.RowsAffected())
+			}
+		}
+
+		seq, err := nextFlashCardSeqTx(ctx, tx, setID)
+		if err != nil {
+			logger.Error("load next seq failed", zap.Error(err), zap.Int64("set_id", setID))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+		if err = insertFlashCardsTx(ctx, tx, setID, req.UserId, toInsert, seq); err != nil {
+			logger.Error("batch insert flashcards failed", zap.Error(err), zap.Int64("set_id", setID))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+		result.Inserted = len(toInsert)
+
+		return result, nil
+	}
+}
+
+func stringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
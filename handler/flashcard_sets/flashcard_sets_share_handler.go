@@ -0,0 +1,297 @@
+package flashcard_sets
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewShareLinkCreateHandler(
+	createShareLinkFunc CreateShareLinkFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req ShareLinkCreateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		res, err := createShareLinkFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewShareLinkListHandler(
+	listShareLinksFunc ListShareLinksFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Params("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+
+		res, err := listShareLinksFunc(ctx, logger, setId, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, res)
+	}
+}
+
+func NewShareLinkRevokeHandler(
+	revokeShareLinkFunc RevokeShareLinkFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req ShareLinkRevokeRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		if err := revokeShareLinkFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, nil)
+	}
+}
+
+// NewShareLinkCopyHandler copies the set of a COPY or EDIT link into the
+// caller's account. It needs a signed-in caller to own the copy.
+func NewShareLinkCopyHandler(
+	resolveShareLinkFunc ResolveShareLinkFunc,
+	copySharedFlashCardsSetFunc CopySharedFlashCardsSetFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req ShareLinkCopyRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = utils.GetUserID(c)
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		link, err := resolveShareLinkFunc(ctx, logger, req.Token)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		if !link.Permits(SharePermissionCopy) {
+			return api.Forbidden(c)
+		}
+
+		newSetId, err := copySharedFlashCardsSetFunc(ctx, logger, req, link.SetId)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, fiber.Map{"newSetId": newSetId})
+	}
+}
+
+// The handlers below serve /shared/:token without a JWT; the token alone
+// grants access, and only to its own set.
+
+func NewSharedSetHandler(
+	resolveShareLinkFunc ResolveShareLinkFunc,
+	inquiryFlashCardSetsFunc FlashCardSetsInquiryFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		link, err := resolveShareLinkFunc(ctx, logger, c.Params("token"))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		cards, err := inquiryFlashCardSetsFunc(ctx, logger, int(link.SetId), "")
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, SharedSetResponse{
+			SetId:          decimal.NewFromInt(link.SetId),
+			Title:          link.Title,
+			Description:    link.Description,
+			StudyDirection: link.StudyDirection,
+			FrontLang:      link.FrontLang,
+			BackLang:       link.BackLang,
+			Permission:     link.Permission,
+			ExpiresAt:      link.ExpiresAt,
+			Cards:          cards,
+		})
+	}
+}
+
+func NewSharedFlashCardsCreateHandler(
+	resolveShareLinkFunc ResolveShareLinkFunc,
+	insertFlashCardsFunc InsertFlashCardsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsCreateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		link, err := resolveShareLinkFunc(ctx, logger, c.Params("token"))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		if !link.Permits(SharePermissionEdit) {
+			return api.Forbidden(c)
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		req.SetId = decimal.NewFromInt(link.SetId)
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserId = link.Editor()
+		req.SharedVia = &link
+
+		if err := insertFlashCardsFunc(ctx, logger, req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		queueCardAudioFunc()
+		return api.Ok(c, nil)
+	}
+}
+
+func NewSharedFlashCardsUpdateHandler(
+	resolveShareLinkFunc ResolveShareLinkFunc,
+	updateFlashCardsFunc UpdateFlashCardsFunc,
+	queueCardAudioFunc tts.QueueCardAudioFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FlashCardsUpdateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		link, err := resolveShareLinkFunc(ctx, logger, c.Params("token"))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		if !link.Permits(SharePermissionEdit) {
+			return api.Forbidden(c)
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.BadRequest(c, err.Error())
+		}
+		if req.Version == nil {
+			version, ok, err := ifMatchVersion(c)
+			if err != nil {
+				return api.BadRequest(c, err.Error())
+			}
+			if ok {
+				req.Version = &version
+			}
+		}
+		// study status is kept per user; a link editor has no account to keep it for
+		req.Status = nil
+		req.SharedVia = &link
+		req.UserId = link.Editor()
+
+		version, err := updateFlashCardsFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, "flashcard not found")
+			case ErrInvalidCloze:
+				return api.BadRequest(c, err.Error())
+			case ErrVersionConflict:
+				return api.Conflict(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		queueCardAudioFunc()
+		setETag(c, version)
+		return api.Ok(c, fiber.Map{"version": version})
+	}
+}
@@ -0,0 +1,120 @@
+package flashcard_sets
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Share link permissions, each one includes the ones before it: VIEW reads
+// the set, COPY also copies it into the caller's account and EDIT also
+// creates and edits its cards.
+const (
+	SharePermissionView = "VIEW"
+	SharePermissionCopy = "COPY"
+	SharePermissionEdit = "EDIT"
+)
+
+var sharePermissionRank = map[string]int{
+	SharePermissionView: 1,
+	SharePermissionCopy: 2,
+	SharePermissionEdit: 3,
+}
+
+type ShareLinkCreateRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Permission  string          `json:"permission"`
+	ExpiresAt   *time.Time      `json:"expiresAt"`
+	UserIdToken string          // from middleware
+	UserId      string          // from middleware
+}
+
+func (r *ShareLinkCreateRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	r.Permission = strings.ToUpper(strings.TrimSpace(r.Permission))
+	if r.Permission == "" {
+		r.Permission = SharePermissionView
+	}
+	if _, ok := sharePermissionRank[r.Permission]; !ok {
+		return errors.New("permission must be VIEW, COPY or EDIT")
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expiresAt must be in the future")
+	}
+	return nil
+}
+
+type ShareLinkDto struct {
+	Id         decimal.Decimal `json:"id"`
+	SetId      decimal.Decimal `json:"setId"`
+	Token      string          `json:"token"`
+	Permission string          `json:"permission"`
+	ExpiresAt  *time.Time      `json:"expiresAt"`
+	LastUsedAt *time.Time      `json:"lastUsedAt"`
+	CreateAt   time.Time       `json:"createAt"`
+}
+
+type ShareLinkRevokeRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	UserIdToken string          // from middleware
+}
+
+func (r ShareLinkRevokeRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+type ShareLinkCopyRequest struct {
+	Token       string `json:"token"`
+	UserIdToken string // from middleware
+	UserId      string // from middleware
+}
+
+func (r ShareLinkCopyRequest) Validate() error {
+	if strings.TrimSpace(r.Token) == "" {
+		return errors.New("token is required")
+	}
+	return nil
+}
+
+// ShareLink is an active link resolved from its token.
+type ShareLink struct {
+	Id             int64
+	SetId          int64
+	Permission     string
+	ExpiresAt      *time.Time
+	Title          string
+	Description    string
+	StudyDirection string
+	FrontLang      string
+	BackLang       string
+}
+
+// Permits reports whether the link grants at least permission.
+func (l ShareLink) Permits(permission string) bool {
+	return sharePermissionRank[l.Permission] >= sharePermissionRank[permission]
+}
+
+// Editor is recorded as update_by/create_by for changes made through the link.
+func (l ShareLink) Editor() string {
+	return "share-link:" + strconv.FormatInt(l.Id, 10)
+}
+
+type SharedSetResponse struct {
+	SetId          decimal.Decimal                `json:"setId"`
+	Title          string                         `json:"title"`
+	Description    string                         `json:"description"`
+	StudyDirection string                         `json:"studyDirection"`
+	FrontLang      string                         `json:"frontLang"`
+	BackLang       string                         `json:"backLang"`
+	Permission     string                         `json:"permission"`
+	ExpiresAt      *time.Time                     `json:"expiresAt"`
+	Cards          []FlashCardSetsInquiryResponse `json:"cards"`
+}
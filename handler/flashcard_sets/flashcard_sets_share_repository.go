@@ -0,0 +1,303 @@
+package flashcard_sets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const shareTokenBytes = 24
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type CreateShareLinkFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req ShareLinkCreateRequest,
+) (ShareLinkDto, error)
+
+// NewCreateShareLink issues a new link for a set owned by the caller.
+func NewCreateShareLink(db *pgxpool.Pool) CreateShareLinkFunc {
+	const insertSQL = `
+        INSERT INTO tbl_flashcard_set_shares
+            (set_id, token, permission, expires_at, create_by)
+        SELECT s.id, $3, $4, $5, $6
+          FROM tbl_flashcard_sets s
+         WHERE s.id = $1
+           AND s.owner_user_token = $2
+           AND s.is_deleted = 'N'
+        RETURNING id, set_id, token, permission, expires_at, last_used_at, create_at
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req ShareLinkCreateRequest,
+	) (ShareLinkDto, error) {
+		var link ShareLinkDto
+
+		token, err := newShareToken()
+		if err != nil {
+			logger.Error("generate share token failed", zap.Error(err))
+			return link, errors.New(api.SomeThingWentWrong)
+		}
+
+		var id, setID int64
+		if err := db.QueryRow(ctx, insertSQL,
+			req.SetId, req.UserIdToken, token, req.Permission, req.ExpiresAt, req.UserId,
+		).Scan(&id, &setID, &link.Token, &link.Permission, &link.ExpiresAt, &link.LastUsedAt, &link.CreateAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return link, errors.New(api.NotFound)
+			}
+			logger.Error("insert share link failed", zap.Error(err))
+			return link, errors.New(api.SomeThingWentWrong)
+		}
+		link.Id = decimal.NewFromInt(id)
+		link.SetId = decimal.NewFromInt(setID)
+		return link, nil
+	}
+}
+
+type ListShareLinksFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	setID int64,
+	userIdToken string,
+) ([]ShareLinkDto, error)
+
+// NewListShareLinks lists the links of an owned set that are neither revoked
+// nor expired, newest first.
+func NewListShareLinks(db *pgxpool.Pool) ListShareLinksFunc {
+	const ownerSQL = `
+        SELECT id
+          FROM tbl_flashcard_sets
+         WHERE id = $1
+           AND owner_user_token = $2
+           AND is_deleted = 'N'
+    `
+	const listSQL = `
+        SELECT id, set_id, token, permission, expires_at, last_used_at, create_at
+          FROM tbl_flashcard_set_shares
+         WHERE set_id = $1
+           AND revoked_at IS NULL
+           AND (expires_at IS NULL OR expires_at > now())
+         ORDER BY create_at DESC, id DESC
+    `
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		setID int64,
+		userIdToken string,
+	) ([]ShareLinkDto, error) {
+		var ownedID int64
+		if err := db.QueryRow(ctx, ownerSQL, setID, userIdToken).Scan(&ownedID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, errors.New(api.NotFound)
+			}
+			logger.Error("check set owner failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+
+		rows, err := db.Query(ctx, listSQL, setID)
+		if err != nil {
+			logger.Error("query share links failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		links := []ShareLinkDto{}
+		for rows.Next() {
+			var (
+				link      ShareLinkDto
+				id, setId int64
+			)
+			if err := rows.Scan(&id, &setId, &link.Token, &link.Permission,
+				&link.ExpiresAt, &link.LastUsedAt, &link.CreateAt); err != nil {
+				logger.Error("scan share link failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			link.Id = decimal.NewFromInt(id)
+			link.SetId = decimal.NewFromInt(setId)
+			links = append(links, link)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate share links failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return links, nil
+	}
+}
+
+type RevokeShareLinkFunc func(ctx context.Context, logger *zap.Logger, req ShareLinkRevokeRequest) error
+
+func NewRevokeShareLink(db *pgxpool.Pool) RevokeShareLinkFunc {
+	const revokeSQL = `
+        UPDATE tbl_flashcard_set_shares sh
+           SET revoked_at = now()
+          FROM tbl_flashcard_sets s
+         WHERE sh.id = $1
+           AND s.id = sh.set_id
+           AND s.owner_user_token = $2
+           AND sh.revoked_at IS NULL
+    `
+	return func(ctx context.Context, logger *zap.Logger, req ShareLinkRevokeRequest) error {
+		tag, err := db.Exec(ctx, revokeSQL, req.Id, req.UserIdToken)
+		if err != nil {
+			logger.Error("revoke share link failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+type ResolveShareLinkFunc func(ctx context.Context, logger *zap.Logger, token string) (ShareLink, error)
+
+// NewResolveShareLink looks up an active link and records its use. Unknown,
+// revoked and expired tokens, and links of deleted sets, are NotFound.
+func NewResolveShareLink(db *pgxpool.Pool) ResolveShareLinkFunc {
+	const resolveSQL = `
+        UPDATE tbl_flashcard_set_shares sh
+           SET last_used_at = now()
+          FROM tbl_flashcard_sets s
+         WHERE sh.token = $1
+           AND s.id = sh.set_id
+           AND s.is_deleted = 'N'
+           AND sh.revoked_at IS NULL
+           AND (sh.expires_at IS NULL OR sh.expires_at > now())
+        RETURNING sh.id, sh.set_id, sh.permission, sh.expires_at,
+                  coalesce(s.title, ''), coalesce(s.description, ''), s.study_direction,
+                  coalesce(s.front_lang, ''), coalesce(s.back_lang, '')
+    `
+	return func(ctx context.Context, logger *zap.Logger, token string) (ShareLink, error) {
+		var link ShareLink
+		if err := db.QueryRow(ctx, resolveSQL, token).Scan(
+			&link.Id, &link.SetId, &link.Permission, &link.ExpiresAt,
+			&link.Title, &link.Description, &link.StudyDirection,
+			&link.FrontLang, &link.BackLang,
+		); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return link, errors.New(api.NotFound)
+			}
+			logger.Error("resolve share link failed", zap.Error(err))
+			return link, errors.New(api.SomeThingWentWrong)
+		}
+		return link, nil
+	}
+}
+
+type CopySharedFlashCardsSetFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req ShareLinkCopyRequest,
+	setID int64,
+) (newSetID int, err error)
+
+// NewCopySharedFlashCardsSet copies a set reached through a share link into a
+// new private set of the caller, with the media of its cards. Unlike a fork
+// it keeps no upstream link, as the source may stay private.
+func NewCopySharedFlashCardsSet(db *pgxpool.Pool) CopySharedFlashCardsSetFunc {
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req ShareLinkCopyRequest,
+		setID int64,
+	) (newSetID int, err error) {
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("tx begin failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+		const copySetSQL = `
+		INSERT INTO tbl_flashcard_sets
+				(owner_user_token, title, description, is_public, create_by, study_direction,
+				 front_lang, back_lang)
+		SELECT  $1       , title, description, 'N', $2, study_direction,
+				front_lang, back_lang
+		FROM    tbl_flashcard_sets
+		WHERE   id = $3
+		  AND   is_deleted = 'N'
+		RETURNING id;
+`
+		if err = tx.QueryRow(ctx, copySetSQL, req.UserIdToken, req.UserId, setID).Scan(&newSetID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("copy shared set failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+
+		const sourceCardsSQL = `
+			SELECT id
+			FROM   tbl_flashcards
+			WHERE  set_id = $1
+			  AND  is_deleted = 'N'
+			ORDER BY seq, id;
+		`
+		const copyCardSQL = `
+			INSERT INTO tbl_flashcards
+					(set_id, front, back, choices, create_by, seq, card_type)
+			SELECT  $1      , front, back, choices, $2, seq, card_type
+			FROM    tbl_flashcards
+			WHERE   id = $3
+			RETURNING id;
+		`
+		var sourceIDs []int64
+		rows, err := tx.Query(ctx, sourceCardsSQL, setID)
+		if err != nil {
+			logger.Error("load shared cards failed", zap.Error(err), zap.Int64("set_id", setID))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		for rows.Next() {
+			var id int64
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				logger.Error("scan shared card failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			sourceIDs = append(sourceIDs, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("load shared cards failed", zap.Error(err), zap.Int64("set_id", setID))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		for _, src := range sourceIDs {
+			var newID int64
+			if err = tx.QueryRow(ctx, copyCardSQL, newSetID, req.UserId, src).Scan(&newID); err != nil {
+				logger.Error("copy shared card failed", zap.Error(err), zap.Int64("card_id", src))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			if _, err = tx.Exec(ctx, copyCardMediaSQL, newID, src, req.UserId); err != nil {
+				logger.Error("copy shared card media failed", zap.Error(err), zap.Int64("card_id", src))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+		}
+		return newSetID, nil
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 )
 
 func NewUpdateHandler(
@@ -27,13 +28,34 @@ func NewUpdateHandler(
 			return api.BadRequest(c, err.Error())
 		}
 
+		if req.Version == nil {
+			version, ok, err := ifMatchVersion(c)
+			if err != nil {
+				return api.BadRequest(c, err.Error())
+			}
+			if ok {
+				req.Version = &version
+			}
+		}
+
 		req.UserId = c.Locals("userId").(string)
+		req.UserIdToken = utils.GetUserIDToken(c)
 
-		if err := updateFlashCardSetsFunc(ctx, logger, req); err != nil {
+		version, err := updateFlashCardSetsFunc(ctx, logger, req)
+		if err != nil {
 			logger.Error("update failed", zap.String("requestId", requestId), zap.Error(err))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrSetAccessDenied:
+				return api.Forbidden(c)
+			case ErrVersionConflict:
+				return api.Conflict(c, err.Error())
+			}
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
 
-		return api.Ok(c, nil)
+		setETag(c, version)
+		return api.Ok(c, fiber.Map{"version": version})
 	}
 }
@@ -5,7 +5,11 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/httputil"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/middleware"
 )
 
 func GetRouter(group fiber.Router,
@@ -13,14 +17,30 @@ func GetRouter(group fiber.Router,
 	redisCMD *redis.UniversalClient,
 	dbPool *pgxpool.Pool,
 	postFunc httputil.HTTPPostRequestFunc,
+	mediaStore blobstore.Store,
+	queueCardAudio tts.QueueCardAudioFunc,
 ) {
 	flashCardSetsGroup := group.Group("/flashcard-sets")
 
 	flashCardSetsGroup.Post("/create", NewCreateHandler(
 		NewInsertFlashCardsSet(dbPool),
+		queueCardAudio,
 	))
-	flashCardSetsGroup.Post("/import/csv", NewFlashCardSetsImportCsvHandler(
+	flashCardSetsGroup.Post("/import/csv", middleware.UploadLimit(config.ImportConfig.MaxFileBytes), NewFlashCardSetsImportCsvHandler(
 		NewInsertFlashCardsSet(dbPool),
+		NewImportIntoFlashCardsSet(dbPool),
+		NewCheckDuplicateFronts(dbPool),
+		queueCardAudio,
+	))
+
+	flashCardSetsGroup.Post("/import/apkg", middleware.UploadLimit(config.ImportConfig.MaxFileBytes), NewFlashCardSetsImportApkgHandler(
+		config.ImportConfig.MaxUnpackedBytes,
+		NewInsertFlashCardsSet(dbPool),
+		NewCheckDuplicateFronts(dbPool),
+		queueCardAudio,
+	))
+	flashCardSetsGroup.Get("/:setId/export/apkg", NewFlashCardSetsExportApkgHandler(
+		NewGetAnkiExportDeck(dbPool),
 	))
 
 	flashCardSetsGroup.Put("/update", NewUpdateHandler(
@@ -33,26 +53,89 @@ func GetRouter(group fiber.Router,
 		NewListFlashCardSets(dbPool),
 	))
 
-	flashCardSetsGroup.Post("/duplicate", NewDuplicateFlashCardsSetHandler(
-		NewDuplicateFlashCardsSet(dbPool),
+	flashCardSetsGroup.Post("/fork", NewForkFlashCardsSetHandler(
+		NewForkFlashCardsSet(dbPool),
 	))
-	// enhance
-	flashCardSetsGroup.Post("/track", NewInsertAndMergeFlashCardSetsTrackerHandler(
-		NewInsertAndMergeFlashCardSetsTracker(dbPool),
+	flashCardSetsGroup.Get("/:setId/upstream-changes", NewUpstreamChangesHandler(
+		NewGetUpstreamChanges(dbPool),
+	))
+	flashCardSetsGroup.Post("/sync", NewFlashCardSetsSyncHandler(
+		NewSyncFlashCardsSet(dbPool),
+	))
+	flashCardSetsGroup.Post("/merge", NewMergeFlashCardSetsHandler(
+		NewMergeFlashCardSets(dbPool),
+	))
+	flashCardSetsGroup.Post("/split", NewSplitFlashCardSetsHandler(
+		NewSplitFlashCardSets(dbPool),
 	))
 	// enhance
 	flashCardSetsGroup.Post("/reset", NewResetStatusHandler(
 		NewResetStatusFlashCards(dbPool),
 	))
 
+	flashCardSetsGroup.Post("/share/create", NewShareLinkCreateHandler(
+		NewCreateShareLink(dbPool),
+	))
+	flashCardSetsGroup.Post("/share/revoke", NewShareLinkRevokeHandler(
+		NewRevokeShareLink(dbPool),
+	))
+	flashCardSetsGroup.Post("/share/copy", NewShareLinkCopyHandler(
+		NewResolveShareLink(dbPool),
+		NewCopySharedFlashCardsSet(dbPool),
+	))
+	flashCardSetsGroup.Get("/:setId/shares", NewShareLinkListHandler(
+		NewListShareLinks(dbPool),
+	))
+
+	flashCardSetsGroup.Post("/collaborators/invite", NewCollaboratorInviteHandler(
+		NewInviteCollaborator(dbPool),
+	))
+	flashCardSetsGroup.Post("/collaborators/respond", NewCollaboratorRespondHandler(
+		NewRespondCollaborator(dbPool),
+	))
+	flashCardSetsGroup.Post("/collaborators/remove", NewCollaboratorRemoveHandler(
+		NewRemoveCollaborator(dbPool),
+	))
+	flashCardSetsGroup.Get("/invitations", NewInvitationListHandler(
+		NewListInvitations(dbPool),
+	))
+	flashCardSetsGroup.Get("/:setId/progress", NewSetProgressHandler(
+		NewGetSetProgress(dbPool),
+	))
+	flashCardSetsGroup.Get("/:setId/collaborators", NewCollaboratorListHandler(
+		NewListCollaborators(dbPool),
+	))
+	flashCardSetsGroup.Get("/:setId/activity", NewSetActivityHandler(
+		NewListSetActivity(dbPool),
+	))
+
 	flashCardSetsGroup.Get("/:setId", NewInquiryFlashCardSetsHandler(
-		NewFlashCardSetsInquiry(dbPool),
+		NewFlashCardSetsInquiry(dbPool, cardmedia.NewCardMediaLoader(dbPool, mediaStore), tts.NewCardAudioLoader(dbPool)),
+	))
+
+	// reached without a JWT, see middleware.JWTMiddleware
+	shared := group.Group("/shared/:token")
+	shared.Get("", NewSharedSetHandler(
+		NewResolveShareLink(dbPool),
+		NewFlashCardSetsInquiry(dbPool, cardmedia.NewCardMediaLoader(dbPool, mediaStore), tts.NewCardAudioLoader(dbPool)),
+	))
+	shared.Post("/flashcards/create", NewSharedFlashCardsCreateHandler(
+		NewResolveShareLink(dbPool),
+		NewInsertFlashCards(dbPool),
+		queueCardAudio,
+	))
+	shared.Put("/flashcards/update", NewSharedFlashCardsUpdateHandler(
+		NewResolveShareLink(dbPool),
+		NewUpdateFlashCards(dbPool),
+		queueCardAudio,
 	))
 
 	flashCards := group.Group("/flashcards")
 
 	flashCards.Post("/create", NewFlashCardCreateHandler(
 		NewInsertFlashCards(dbPool),
+		NewCheckDuplicateFronts(dbPool),
+		queueCardAudio,
 	))
 	flashCards.Post("/delete", NewFlashCardsDeleteHandler(
 		NewDeleteFlashCards(dbPool),
@@ -60,6 +143,31 @@ func GetRouter(group fiber.Router,
 
 	flashCards.Put("/update", NewFlashCardsUpdateHandler(
 		NewUpdateFlashCards(dbPool),
+		queueCardAudio,
+	))
+	flashCards.Put("/reorder", NewFlashCardsReorderHandler(
+		NewReorderFlashCards(dbPool),
+	))
+	flashCards.Put("/reposition", NewFlashCardsRepositionHandler(
+		NewRepositionFlashCard(dbPool),
+	))
+	flashCards.Post("/move", NewFlashCardsTransferHandler(
+		NewMoveFlashCards(dbPool),
+	))
+	flashCards.Post("/copy", NewFlashCardsTransferHandler(
+		NewCopyFlashCards(dbPool),
+	))
+	flashCards.Post("/revert", NewFlashCardRevertHandler(
+		NewRevertFlashCard(dbPool),
+	))
+	flashCards.Get("/:id/history", NewFlashCardHistoryHandler(
+		NewGetFlashCardHistory(dbPool),
+	))
+	flashCards.Get("/duplicates", NewFlashCardDuplicatesHandler(
+		NewListDuplicateGroups(dbPool),
+	))
+	flashCards.Post("/duplicates/merge", NewFlashCardDuplicatesMergeHandler(
+		NewMergeDuplicates(dbPool),
 	))
 
 }
@@ -2,7 +2,14 @@ package flashcard_sets
 
 import (
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/langcode"
 )
 
 const (
@@ -19,13 +26,14 @@ func init() {
 }
 
 func GetChoices(all []*InsertFlashCards, current *InsertFlashCards) []string {
-	if len(current.Choices) >= 4 {
+	// cloze choices are built per blank when an exam is started
+	if len(current.Choices) >= 4 || current.CardType == cloze.CardTypeCloze {
 		return current.Choices
 	}
 
 	var pool []string
 	for _, c := range all {
-		if c != current {
+		if c != current && c.CardType != cloze.CardTypeCloze {
 			pool = append(pool, c.Back)
 		}
 	}
@@ -45,3 +53,47 @@ func GetChoices(all []*InsertFlashCards, current *InsertFlashCards) []string {
 	})
 	return choices
 }
+
+// cardTypeOrBasic keeps rows built outside the validated request paths
+// (Anki packages, older clients) on the BASIC type.
+func cardTypeOrBasic(cardType string) string {
+	if cardType == "" {
+		return cloze.CardTypeBasic
+	}
+	return cardType
+}
+
+// validLanguages normalizes the given set language tags in place; nil
+// pointers are skipped. It reports false when any of them is not a tag.
+func validLanguages(codes ...*string) bool {
+	for _, c := range codes {
+		if c == nil {
+			continue
+		}
+		code, ok := langcode.Normalize(*c)
+		if !ok {
+			return false
+		}
+		*c = code
+	}
+	return true
+}
+
+// ifMatchVersion reads the version an edit is based on from an If-Match
+// header such as "3" or W/"3". ok is false when the header is absent.
+func ifMatchVersion(c *fiber.Ctx) (version int, ok bool, err error) {
+	raw := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if raw == "" {
+		return 0, false, nil
+	}
+	raw = strings.Trim(strings.TrimPrefix(raw, "W/"), `"`)
+	version, err = strconv.Atoi(raw)
+	if err != nil {
+		return 0, false, errors.New("If-Match must hold a version")
+	}
+	return version, true, nil
+}
+
+func setETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.Itoa(version)))
+}
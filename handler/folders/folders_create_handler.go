@@ -0,0 +1,48 @@
+package folders
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewCreateFolderHandler(
+	insertFolderFunc InsertFolderFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req FolderCreateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		id, err := insertFolderFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error("create folder failed", zap.String("requestId", requestId), zap.Error(err))
+			if err.Error() == ErrInvalidParentFolder {
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, FolderDto{
+			Id:       decimal.NewFromInt(id),
+			ParentId: req.ParentId,
+			Name:     req.Name,
+			Children: []*FolderDto{},
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package folders
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const maxFolderNameLength = 255
+
+type FolderCreateRequest struct {
+	Name        string           `json:"name"`
+	ParentId    *decimal.Decimal `json:"parentId"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r *FolderCreateRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	return validateFolderName(r.Name)
+}
+
+// FolderUpdateRequest renames a folder and sets its parent. A nil parentId
+// moves the folder to the top level.
+type FolderUpdateRequest struct {
+	Id          decimal.Decimal  `json:"id"`
+	Name        string           `json:"name"`
+	ParentId    *decimal.Decimal `json:"parentId"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r *FolderUpdateRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	if r.ParentId != nil && r.ParentId.Equal(r.Id) {
+		return errors.New("folder cannot be its own parent")
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	return validateFolderName(r.Name)
+}
+
+type FolderDeleteRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r FolderDeleteRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// FolderSetRequest files a set into a folder; a nil folderId unfiles it.
+type FolderSetRequest struct {
+	SetId       decimal.Decimal  `json:"setId"`
+	FolderId    *decimal.Decimal `json:"folderId"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r FolderSetRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	return nil
+}
+
+type FolderDto struct {
+	Id       decimal.Decimal  `json:"id"`
+	ParentId *decimal.Decimal `json:"parentId"`
+	Name     string           `json:"name"`
+	SetCount int              `json:"setCount"`
+	CreateAt time.Time        `json:"createAt"`
+	Children []*FolderDto     `json:"children"`
+}
+
+func validateFolderName(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(name)) > maxFolderNameLength {
+		return errors.Errorf("name must be at most %d characters", maxFolderNameLength)
+	}
+	return nil
+}
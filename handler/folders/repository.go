@@ -0,0 +1,358 @@
+package folders
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const ErrInvalidParentFolder = "parent folder is invalid"
+
+// lockFoldersSQL locks all of a user's folders. Every folder write takes it
+// before checking a folder, so checks and writes cannot interleave.
+const lockFoldersSQL = `
+	SELECT id
+	  FROM tbl_folders
+	 WHERE user_id_token = $1
+	 ORDER BY id
+	   FOR UPDATE
+`
+
+type ListFoldersFunc func(ctx context.Context, logger *zap.Logger, userIdToken string) ([]*FolderDto, error)
+
+func NewListFolders(db *pgxpool.Pool) ListFoldersFunc {
+	return func(ctx context.Context, logger *zap.Logger, userIdToken string) ([]*FolderDto, error) {
+		const sql = `
+			SELECT f.id, f.parent_id, f.name, f.create_at,
+			       (SELECT count(*)
+			          FROM tbl_flashcard_sets s
+			         WHERE s.folder_id = f.id
+			           AND s.is_deleted = 'N') AS set_count
+			  FROM tbl_folders f
+			 WHERE f.user_id_token = $1
+			   AND f.is_deleted = 'N'
+			 ORDER BY lower(f.name), f.id
+		`
+		rows, err := db.Query(ctx, sql, userIdToken)
+		if err != nil {
+			logger.Error("failed to list folders", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		var flat []*FolderDto
+		for rows.Next() {
+			var (
+				f        FolderDto
+				id       int64
+				parentId *int64
+			)
+			if err := rows.Scan(&id, &parentId, &f.Name, &f.CreateAt, &f.SetCount); err != nil {
+				logger.Error("scan folder failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			f.Id = decimal.NewFromInt(id)
+			if parentId != nil {
+				p := decimal.NewFromInt(*parentId)
+				f.ParentId = &p
+			}
+			f.Children = []*FolderDto{}
+			flat = append(flat, &f)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterating folders failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return buildFolderTree(flat), nil
+	}
+}
+
+type InsertFolderFunc func(ctx context.Context, logger *zap.Logger, req FolderCreateRequest) (int64, error)
+
+// NewInsertFolder creates a folder, optionally under another of the caller's
+// folders. The parent is checked with the caller's folders locked, so it
+// cannot be deleted between the check and the insert.
+func NewInsertFolder(db *pgxpool.Pool) InsertFolderFunc {
+	const sql = `
+		INSERT INTO tbl_folders (user_id_token, parent_id, name, create_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+	return func(ctx context.Context, logger *zap.Logger, req FolderCreateRequest) (id int64, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				id, err = 0, errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if req.ParentId != nil {
+			if _, err = tx.Exec(ctx, lockFoldersSQL, req.UserIdToken); err != nil {
+				logger.Error("lock folders failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			ok, err := ownsFolder(ctx, tx, *req.ParentId, req.UserIdToken)
+			if err != nil {
+				logger.Error("check parent folder failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			if !ok {
+				return 0, errors.New(ErrInvalidParentFolder)
+			}
+		}
+
+		if err = tx.QueryRow(ctx, sql, req.UserIdToken, req.ParentId, req.Name, req.UserId).Scan(&id); err != nil {
+			logger.Error("failed to insert folder", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+type UpdateFolderFunc func(ctx context.Context, logger *zap.Logger, req FolderUpdateRequest) error
+
+// NewUpdateFolder renames a folder and moves it under another of the
+// caller's folders. The caller's folders are locked first, so two moves
+// racing each other cannot both pass the cycle check.
+func NewUpdateFolder(db *pgxpool.Pool) UpdateFolderFunc {
+	// the new parent must not sit below the folder being moved
+	const cycleSQL = `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tbl_folders WHERE id = $1
+			UNION
+			SELECT f.id
+			  FROM tbl_folders f
+			  JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)
+	`
+	const sql = `
+		UPDATE tbl_folders
+		   SET name      = $1,
+		       parent_id = $2,
+		       update_at = now(),
+		       update_by = $3
+		 WHERE id = $4
+		   AND user_id_token = $5
+		   AND is_deleted = 'N'
+	`
+	return func(ctx context.Context, logger *zap.Logger, req FolderUpdateRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if _, err = tx.Exec(ctx, lockFoldersSQL, req.UserIdToken); err != nil {
+			logger.Error("lock folders failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		ok, err := ownsFolder(ctx, tx, req.Id, req.UserIdToken)
+		if err != nil {
+			logger.Error("check folder failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if !ok {
+			return errors.New(api.NotFound)
+		}
+
+		if req.ParentId != nil {
+			ok, err := ownsFolder(ctx, tx, *req.ParentId, req.UserIdToken)
+			if err != nil {
+				logger.Error("check parent folder failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if !ok {
+				return errors.New(ErrInvalidParentFolder)
+			}
+
+			var cycle bool
+			if err := tx.QueryRow(ctx, cycleSQL, req.Id, *req.ParentId).Scan(&cycle); err != nil {
+				logger.Error("check folder cycle failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if cycle {
+				return errors.New(ErrInvalidParentFolder)
+			}
+		}
+
+		if _, err = tx.Exec(ctx, sql, req.Name, req.ParentId, req.UserId, req.Id, req.UserIdToken); err != nil {
+			logger.Error("failed to update folder", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type DeleteFolderFunc func(ctx context.Context, logger *zap.Logger, req FolderDeleteRequest) error
+
+// NewDeleteFolder soft-deletes a folder with all of its subfolders. Sets filed
+// under any of them are moved back to the top level, not deleted. The
+// caller's folders are locked first, like every other folder write.
+func NewDeleteFolder(db *pgxpool.Pool) DeleteFolderFunc {
+	const subtreeCTE = `
+		WITH RECURSIVE subtree AS (
+			SELECT id
+			  FROM tbl_folders
+			 WHERE id = $1
+			   AND user_id_token = $2
+			   AND is_deleted = 'N'
+			UNION
+			SELECT f.id
+			  FROM tbl_folders f
+			  JOIN subtree s ON f.parent_id = s.id
+		)
+	`
+	const unfileSQL = subtreeCTE + `
+		UPDATE tbl_flashcard_sets
+		   SET folder_id = NULL
+		 WHERE folder_id IN (SELECT id FROM subtree)
+	`
+	const deleteSQL = subtreeCTE + `
+		UPDATE tbl_folders
+		   SET is_deleted = 'Y',
+		       update_at  = now(),
+		       update_by  = $3
+		 WHERE id IN (SELECT id FROM subtree)
+	`
+	return func(ctx context.Context, logger *zap.Logger, req FolderDeleteRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if _, err = tx.Exec(ctx, lockFoldersSQL, req.UserIdToken); err != nil {
+			logger.Error("lock folders failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, unfileSQL, req.Id, req.UserIdToken); err != nil {
+			logger.Error("unfile folder sets failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		tag, err := tx.Exec(ctx, deleteSQL, req.Id, req.UserIdToken, req.UserId)
+		if err != nil {
+			logger.Error("delete folders failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+type MoveSetToFolderFunc func(ctx context.Context, logger *zap.Logger, req FolderSetRequest) error
+
+// NewMoveSetToFolder files one of the caller's sets under one of their
+// folders, or back at the top level when FolderId is nil. The folder is
+// checked in the same transaction as the update, with the caller's folders
+// locked, so a concurrent delete cannot leave the set in a deleted folder.
+func NewMoveSetToFolder(db *pgxpool.Pool) MoveSetToFolderFunc {
+	const sql = `
+		UPDATE tbl_flashcard_sets
+		   SET folder_id = $1,
+		       update_at = now(),
+		       update_by = $2
+		 WHERE id = $3
+		   AND owner_user_token = $4
+		   AND is_deleted = 'N'
+	`
+	return func(ctx context.Context, logger *zap.Logger, req FolderSetRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if req.FolderId != nil {
+			if _, err = tx.Exec(ctx, lockFoldersSQL, req.UserIdToken); err != nil {
+				logger.Error("lock folders failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			ok, err := ownsFolder(ctx, tx, *req.FolderId, req.UserIdToken)
+			if err != nil {
+				logger.Error("check folder failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if !ok {
+				return errors.New(api.NotFound)
+			}
+		}
+
+		tag, err := tx.Exec(ctx, sql, req.FolderId, req.UserId, req.SetId, req.UserIdToken)
+		if err != nil {
+			logger.Error("failed to move set to folder", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func ownsFolder(ctx context.Context, db querier, id decimal.Decimal, userIdToken string) (bool, error) {
+	const sql = `
+		SELECT 1
+		  FROM tbl_folders
+		 WHERE id = $1
+		   AND user_id_token = $2
+		   AND is_deleted = 'N'
+	`
+	var one int
+	err := db.QueryRow(ctx, sql, id, userIdToken).Scan(&one)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
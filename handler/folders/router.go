@@ -0,0 +1,28 @@
+package folders
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetRouter(
+	group fiber.Router,
+	dbPool *pgxpool.Pool,
+) {
+	foldersGroup := group.Group("/folders")
+	foldersGroup.Get("", NewListFoldersHandler(
+		NewListFolders(dbPool),
+	))
+	foldersGroup.Post("/create", NewCreateFolderHandler(
+		NewInsertFolder(dbPool),
+	))
+	foldersGroup.Put("/update", NewUpdateFolderHandler(
+		NewUpdateFolder(dbPool),
+	))
+	foldersGroup.Post("/delete", NewDeleteFolderHandler(
+		NewDeleteFolder(dbPool),
+	))
+	foldersGroup.Put("/sets", NewMoveSetToFolderHandler(
+		NewMoveSetToFolder(dbPool),
+	))
+}
@@ -0,0 +1,21 @@
+package folders
+
+// buildFolderTree links a flat, name-ordered folder list into a forest.
+// Folders whose parent is missing from the list are kept at the top level.
+func buildFolderTree(flat []*FolderDto) []*FolderDto {
+	byId := make(map[string]*FolderDto, len(flat))
+	for _, f := range flat {
+		byId[f.Id.String()] = f
+	}
+	roots := []*FolderDto{}
+	for _, f := range flat {
+		if f.ParentId != nil {
+			if parent, ok := byId[f.ParentId.String()]; ok {
+				parent.Children = append(parent.Children, f)
+				continue
+			}
+		}
+		roots = append(roots, f)
+	}
+	return roots
+}
@@ -0,0 +1,33 @@
+package job
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"go.uber.org/zap"
+)
+
+func NewCardAudioCronHandler(
+	generateCardAudioFunc GenerateCardAudioFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+		var req GenerateCardAudioRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return api.BadRequest(c, "invalid request")
+			}
+		}
+		if err := req.Validate(); err != nil {
+			return api.BadRequest(c, err.Error())
+		}
+		res, err := generateCardAudioFunc(ctx, logger, requestId, req)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, err.Error())
+		}
+		return api.Ok(c, res)
+	}
+}
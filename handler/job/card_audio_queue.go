@@ -0,0 +1,40 @@
+package job
+
+import (
+	"context"
+
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"go.uber.org/zap"
+)
+
+// NewCardAudioQueue runs generateCardAudioFunc in the background whenever it
+// is asked to, batch after batch until no card is left waiting. Asking while
+// a run is going queues one more run after it, so a burst of edits does not
+// start a run each.
+func NewCardAudioQueue(generateCardAudioFunc GenerateCardAudioFunc) tts.QueueCardAudioFunc {
+	pending := make(chan struct{}, 1)
+	go func() {
+		logger := logz.NewLogger()
+		for range pending {
+			for {
+				req := GenerateCardAudioRequest{Limit: maxCardAudioBatch}
+				res, err := generateCardAudioFunc(context.Background(), logger, "", req)
+				if err != nil {
+					logger.Error("queued card audio run failed", zap.Error(err))
+					break
+				}
+				// failed cards back off, so a full batch means more are waiting
+				if res.Cards == 0 || res.Cards+res.Failed < req.Limit {
+					break
+				}
+			}
+		}
+	}()
+	return func() {
+		select {
+		case pending <- struct{}{}:
+		default:
+		}
+	}
+}
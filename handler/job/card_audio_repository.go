@@ -0,0 +1,199 @@
+package job
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/langcode"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"go.uber.org/zap"
+)
+
+type GenerateCardAudioFunc func(ctx context.Context, logger *zap.Logger, requestId string, req GenerateCardAudioRequest) (GenerateCardAudioResult, error)
+
+// NewGenerateCardAudioFunc speaks the cards that are missing a clip, or whose
+// clip was made from text or a set language they no longer have: new and
+// imported cards, and cards edited since. Creating, importing or editing a
+// card kicks off a run through NewCardAudioQueue; the cron route covers the
+// other writes, such as forks and copies, and the retries. Until a run has
+// reached it, card payloads carry no audio for the card. Each card gets its
+// front at normal and slow speed and its back, in the languages of its set;
+// a cloze card's front is spoken with every cloze revealed. A card that fails
+// is retried after a delay that doubles with every failure, up to a day, and
+// is queued behind the cards that have not failed; editing it retries it
+// right away.
+func NewGenerateCardAudioFunc(db *pgxpool.Pool, generate tts.GenerateFunc) GenerateCardAudioFunc {
+	const pendingSQL = `
+		SELECT f.id, coalesce(f.front, ''), coalesce(f.back, ''), f.card_type,
+		       coalesce(s.front_lang, ''), coalesce(s.back_lang, ''), coalesce(uc.locale, '')
+		  FROM tbl_flashcards f
+		  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+		  LEFT JOIN tbl_user_config uc ON uc.user_id_token = s.owner_user_token
+		  LEFT JOIN tbl_flashcard_audio_failure fl
+		         ON fl.flashcard_id = f.id
+		        AND fl.front_text = coalesce(f.front, '')
+		        AND fl.back_text = coalesce(f.back, '')
+		 WHERE f.is_deleted = 'N'
+		   AND s.is_deleted = 'N'
+		   AND ($1::int IS NULL OR f.set_id = $1)
+		   AND (fl.next_attempt_at IS NULL OR fl.next_attempt_at <= now())
+		   AND (EXISTS (SELECT 1
+		                  FROM unnest(ARRAY['front_normal', 'front_slow']) AS t(audio_type)
+		                 WHERE coalesce(f.front, '') <> ''
+		                   AND NOT EXISTS (SELECT 1
+		                                     FROM tbl_flashcard_audio a
+		                                    WHERE a.flashcard_id = f.id
+		                                      AND a.audio_type = t.audio_type
+		                                      AND a.source_text = f.front
+		                                      AND (s.front_lang IS NULL OR a.locale = s.front_lang)))
+		        OR (coalesce(f.back, '') <> ''
+		            AND NOT EXISTS (SELECT 1
+		                              FROM tbl_flashcard_audio a
+		                             WHERE a.flashcard_id = f.id
+		                               AND a.audio_type = 'back'
+		                               AND a.source_text = f.back
+		                               AND (s.back_lang IS NULL OR a.locale = s.back_lang))))
+		 ORDER BY coalesce(fl.attempts, 0), f.id
+		 LIMIT $2
+	`
+	const upsertSQL = `
+		INSERT INTO tbl_flashcard_audio (flashcard_id, audio_type, tts_cache_id, source_text, locale)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (flashcard_id, audio_type) DO UPDATE
+		   SET tts_cache_id = EXCLUDED.tts_cache_id,
+		       source_text  = EXCLUDED.source_text,
+		       locale       = EXCLUDED.locale,
+		       created_at   = now()
+	`
+	// attempts restart when the text differs from the one that failed
+	const failureSQL = `
+		INSERT INTO tbl_flashcard_audio_failure
+		    (flashcard_id, front_text, back_text, last_error, next_attempt_at)
+		VALUES ($1, $2, $3, $4, now() + interval '1 minute')
+		ON CONFLICT (flashcard_id) DO UPDATE
+		   SET attempts        = CASE WHEN tbl_flashcard_audio_failure.front_text = EXCLUDED.front_text
+		                               AND tbl_flashcard_audio_failure.back_text = EXCLUDED.back_text
+		                              THEN tbl_flashcard_audio_failure.attempts + 1 ELSE 1 END,
+		       front_text      = EXCLUDED.front_text,
+		       back_text       = EXCLUDED.back_text,
+		       last_error      = EXCLUDED.last_error,
+		       last_attempt_at = now()
+		RETURNING attempts
+	`
+	const backoffSQL = `
+		UPDATE tbl_flashcard_audio_failure
+		   SET next_attempt_at = now() + least(interval '1 minute' * power(2, $2::int - 1), interval '1 day')
+		 WHERE flashcard_id = $1
+	`
+	const clearFailureSQL = `
+		DELETE FROM tbl_flashcard_audio_failure
+		 WHERE flashcard_id = $1
+	`
+	type pendingCard struct {
+		id                  int64
+		front, back         string
+		cardType            string
+		frontLang, backLang string
+	}
+	type clipSpec struct {
+		audioType  string
+		sourceText string // the card text the clip is compared against
+		spoken     string
+		speed      float64
+		locale     string
+	}
+	return func(ctx context.Context, logger *zap.Logger, requestId string, req GenerateCardAudioRequest) (GenerateCardAudioResult, error) {
+		var result GenerateCardAudioResult
+		var setID *int64
+		if req.SetId != nil {
+			v := req.SetId.IntPart()
+			setID = &v
+		}
+		rows, err := db.Query(ctx, pendingSQL, setID, req.Limit)
+		if err != nil {
+			logger.Error("select cards without audio failed", zap.Error(err))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+		var cards []pendingCard
+		for rows.Next() {
+			var (
+				c          pendingCard
+				userLocale string
+			)
+			if err := rows.Scan(&c.id, &c.front, &c.back, &c.cardType, &c.frontLang, &c.backLang, &userLocale); err != nil {
+				rows.Close()
+				logger.Error("scan card without audio failed", zap.Error(err))
+				return result, errors.New(api.SomeThingWentWrong)
+			}
+			c.frontLang = langcode.Resolve(c.frontLang, userLocale)
+			c.backLang = langcode.Resolve(c.backLang, userLocale)
+			cards = append(cards, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate cards without audio failed", zap.Error(err))
+			return result, errors.New(api.SomeThingWentWrong)
+		}
+
+		for _, c := range cards {
+			spokenFront := c.front
+			if c.cardType == cloze.CardTypeCloze {
+				spokenFront = cloze.Plain(c.front)
+			}
+			var specs []clipSpec
+			if c.front != "" {
+				specs = append(specs,
+					clipSpec{tts.AudioFrontNormal, c.front, spokenFront, tts.SpeedNormal, c.frontLang},
+					clipSpec{tts.AudioFrontSlow, c.front, spokenFront, tts.SpeedSlow, c.frontLang},
+				)
+			}
+			if c.back != "" {
+				specs = append(specs, clipSpec{tts.AudioBack, c.back, c.back, tts.SpeedNormal, c.backLang})
+			}
+
+			var lastErr error
+			for _, spec := range specs {
+				clip, err := generate(ctx, logger, requestId, spec.spoken, tts.Options{
+					Voice:  tts.DefaultVoice,
+					Speed:  spec.speed,
+					Format: tts.DefaultFormat,
+					Locale: spec.locale,
+				})
+				if err != nil {
+					lastErr = err
+					continue
+				}
+				if _, err := db.Exec(ctx, upsertSQL, c.id, spec.audioType, clip.CacheId, spec.sourceText, spec.locale); err != nil {
+					logger.Error("upsert card audio failed", zap.Error(err), zap.Int64("card_id", c.id))
+					lastErr = err
+					continue
+				}
+				result.Clips++
+			}
+			if lastErr != nil {
+				result.Failed++
+				var attempts int
+				err := db.QueryRow(ctx, failureSQL, c.id, c.front, c.back, lastErr.Error()).Scan(&attempts)
+				if err == nil {
+					_, err = db.Exec(ctx, backoffSQL, c.id, attempts)
+				}
+				if err != nil {
+					logger.Error("record card audio failure failed", zap.Error(err), zap.Int64("card_id", c.id))
+				}
+				continue
+			}
+			if _, err := db.Exec(ctx, clearFailureSQL, c.id); err != nil {
+				logger.Error("clear card audio failure failed", zap.Error(err), zap.Int64("card_id", c.id))
+			}
+			result.Cards++
+		}
+		logger.Info("card audio generated",
+			zap.Int("cards", result.Cards),
+			zap.Int("clips", result.Clips),
+			zap.Int("failed", result.Failed))
+		return result, nil
+	}
+}
@@ -1 +1,45 @@
 package job
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+type PurgeTrashResult struct {
+	RetentionDays int   `json:"retentionDays"`
+	Sets          int64 `json:"sets"`
+	Cards         int64 `json:"cards"`
+}
+
+const (
+	defaultCardAudioBatch = 50
+	maxCardAudioBatch     = 200
+)
+
+// GenerateCardAudioRequest narrows a card audio run to one set; Limit caps
+// the cards handled per run, at most maxCardAudioBatch since every card is
+// spoken one call at a time.
+type GenerateCardAudioRequest struct {
+	SetId *decimal.Decimal `json:"setId"`
+	Limit int              `json:"limit"`
+}
+
+func (r *GenerateCardAudioRequest) Validate() error {
+	if r.Limit < 0 {
+		return errors.New("limit must be >= 0")
+	}
+	if r.Limit == 0 {
+		r.Limit = defaultCardAudioBatch
+	}
+	if r.Limit > maxCardAudioBatch {
+		r.Limit = maxCardAudioBatch
+	}
+	return nil
+}
+
+type GenerateCardAudioResult struct {
+	Cards  int `json:"cards"`
+	Clips  int `json:"clips"`
+	Failed int `json:"failed"`
+}
@@ -43,6 +43,19 @@ func NewInsertDailyPlansFunc(db *pgxpool.Pool) InsertDailyPlansFunc {
 			  WHERE uc.daily_active = 'Y'
 				AND uc.status = 'ACTIVE'
 			),
+			due_cards AS (
+			  -- cloze notes have one srs row per blank; plan the note once
+			  SELECT
+				s.user_id_token,
+				s.card_id,
+				min(s.next_review_at) AS next_review_at,
+				min(s.box) AS box,
+				min(s.last_review_at) AS last_review_at
+			  FROM tbl_user_flashcard_srs s
+			  JOIN cfg ON cfg.user_id_token = s.user_id_token
+			  WHERE s.next_review_at IS NULL OR s.next_review_at <= now()
+			  GROUP BY s.user_id_token, s.card_id
+			),
 			due_ranked AS (
 			  SELECT
 				s.user_id_token,
@@ -55,9 +68,7 @@ func NewInsertDailyPlansFunc(db *pgxpool.Pool) InsertDailyPlansFunc {
 					s.last_review_at NULLS FIRST,
 					s.card_id ASC
 				) AS rn
-			  FROM tbl_user_flashcard_srs s
-			  JOIN cfg ON cfg.user_id_token = s.user_id_token
-			  WHERE s.next_review_at IS NULL OR s.next_review_at <= now()
+			  FROM due_cards s
 			),
 			due_selected AS (
 			  SELECT
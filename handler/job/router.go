@@ -4,8 +4,11 @@ import (
 	"github.com/go-redis/redis/v9"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/adapter"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/httputil"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 )
 
 func GetRouter(group fiber.Router,
@@ -13,9 +16,19 @@ func GetRouter(group fiber.Router,
 	redisCMD *redis.UniversalClient,
 	dbPool *pgxpool.Pool,
 	postFunc httputil.HTTPPostRequestFunc,
+	mediaStore blobstore.Store,
+	homeProxy adapter.Adapter,
 ) {
 	jobGroup := group.Group("/job")
 	jobGroup.Post("/daily-plans/generate", NewDailyPlansCronHandler(
 		NewInsertDailyPlansFunc(dbPool),
 	))
+	jobGroup.Post("/trash/purge", NewTrashPurgeCronHandler(
+		NewPurgeTrashFunc(dbPool, mediaStore, config.TrashConfig.RetentionDays),
+	))
+	// card writes queue a run themselves; schedule this one as well for the
+	// cards they do not cover and for retries
+	jobGroup.Post("/card-audio/generate", NewCardAudioCronHandler(
+		NewGenerateCardAudioFunc(dbPool, tts.NewGenerate(dbPool, homeProxy)),
+	))
 }
@@ -0,0 +1,141 @@
+package job
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"go.uber.org/zap"
+)
+
+type PurgeTrashFunc func(ctx context.Context, logger *zap.Logger) (PurgeTrashResult, error)
+
+// NewPurgeTrashFunc hard-deletes sets and cards that have been in the trash
+// longer than retentionDays. Daily plan entries and config pointers are
+// cleaned up explicitly; SRS rows, review logs, revisions, tags, audio and
+// practice sessions go with the card or set through ON DELETE CASCADE.
+// Media blobs are removed from the store once the transaction has committed.
+func NewPurgeTrashFunc(db *pgxpool.Pool, mediaStore blobstore.Store, retentionDays int) PurgeTrashFunc {
+	const expiredSetsSQL = `
+		SELECT coalesce(array_agg(id), '{}')
+		  FROM tbl_flashcard_sets
+		 WHERE is_deleted = 'Y'
+		   AND deleted_at < now() - make_interval(days => $1)
+	`
+	const expiredCardsSQL = `
+		SELECT coalesce(array_agg(id), '{}')
+		  FROM tbl_flashcards
+		 WHERE (is_deleted = 'Y' AND deleted_at < now() - make_interval(days => $1))
+		    OR set_id = ANY($2::int[])
+	`
+	// copied cards share blobs; keep those still used by a surviving card
+	const mediaKeysSQL = `
+		SELECT coalesce(array_agg(DISTINCT m.storage_key), '{}')
+		  FROM tbl_flashcard_media m
+		 WHERE m.card_id = ANY($1::bigint[])
+		   AND NOT EXISTS (SELECT 1
+		                     FROM tbl_flashcard_media o
+		                    WHERE o.storage_key = m.storage_key
+		                      AND NOT (o.card_id = ANY($1::bigint[])))
+	`
+	const dailyPlansSQL = `
+		UPDATE tbl_daily_plans
+		   SET card_ids = ARRAY(
+		           SELECT c
+		             FROM unnest(card_ids) WITH ORDINALITY AS u(c, ord)
+		            WHERE NOT (c = ANY($1::bigint[]))
+		            ORDER BY ord
+		       )
+		 WHERE card_ids && $1::bigint[]
+	`
+	const userConfigSQL = `
+		UPDATE tbl_user_config
+		   SET daily_flash_card_set_id   = CASE WHEN daily_flash_card_set_id = ANY($1::int[]) THEN NULL ELSE daily_flash_card_set_id END,
+		       default_flash_card_set_id = CASE WHEN default_flash_card_set_id = ANY($1::int[]) THEN NULL ELSE default_flash_card_set_id END
+		 WHERE daily_flash_card_set_id = ANY($1::int[])
+		    OR default_flash_card_set_id = ANY($1::int[])
+	`
+	const deleteCardsSQL = `
+		DELETE FROM tbl_flashcards
+		 WHERE id = ANY($1::bigint[])
+	`
+	const deleteSetsSQL = `
+		DELETE FROM tbl_flashcard_sets
+		 WHERE id = ANY($1::int[])
+	`
+
+	return func(ctx context.Context, logger *zap.Logger) (result PurgeTrashResult, err error) {
+		result.RetentionDays = retentionDays
+		if retentionDays <= 0 {
+			return result, errors.New("trash retention days must be positive")
+		}
+
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to begin purge")
+		}
+		var mediaKeys []string
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+				return
+			}
+			if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New("failed to commit purge")
+				return
+			}
+			// the rows are gone; a blob that fails to delete is only orphaned
+			for _, key := range mediaKeys {
+				if delErr := mediaStore.Delete(ctx, key); delErr != nil {
+					logger.Warn("delete media blob failed", zap.String("key", key), zap.Error(delErr))
+				}
+			}
+		}()
+
+		var setIDs, cardIDs []int64
+		if err = tx.QueryRow(ctx, expiredSetsSQL, retentionDays).Scan(&setIDs); err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to find expired sets")
+		}
+		if err = tx.QueryRow(ctx, expiredCardsSQL, retentionDays, setIDs).Scan(&cardIDs); err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to find expired cards")
+		}
+		if len(setIDs) == 0 && len(cardIDs) == 0 {
+			return result, nil
+		}
+
+		if err = tx.QueryRow(ctx, mediaKeysSQL, cardIDs).Scan(&mediaKeys); err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to find card media")
+		}
+		if _, err = tx.Exec(ctx, dailyPlansSQL, cardIDs); err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to purge daily plan cards")
+		}
+		if _, err = tx.Exec(ctx, userConfigSQL, setIDs); err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to clear user config sets")
+		}
+		tag, err := tx.Exec(ctx, deleteCardsSQL, cardIDs)
+		if err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to purge cards")
+		}
+		result.Cards = tag.RowsAffected()
+		tag, err = tx.Exec(ctx, deleteSetsSQL, setIDs)
+		if err != nil {
+			logger.Error(err.Error())
+			return result, errors.New("failed to purge sets")
+		}
+		result.Sets = tag.RowsAffected()
+
+		logger.Info("trash purged", zap.Int64("sets", result.Sets), zap.Int64("cards", result.Cards))
+		return result, nil
+	}
+}
@@ -8,6 +8,8 @@ import (
 
 type ReviewSubMitRequest struct {
 	CardId       decimal.Decimal `json:"cardId"`
+	ClozeOrd     int             `json:"clozeOrd"`
+	Direction    string          `json:"direction"`
 	Source       string          `json:"source"`
 	Grade        int             `json:"grade"`
 	IsCorrect    string          `json:"isCorrect"`
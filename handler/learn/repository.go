@@ -13,6 +13,8 @@ import (
 type InsertReviewLogDto struct {
 	UserIdToken  string `json:"userIdToken"`
 	CardId       decimal.Decimal
+	ClozeOrd     int
+	Direction    string
 	Source       string
 	IsCorrect    string
 	Grade        int
@@ -28,10 +30,10 @@ func NewInsertReviewLogFunc() InsertReviewLogFunc {
 	return func(ctx context.Context, logger *zap.Logger, tx pgx.Tx, dto InsertReviewLogDto) error {
 
 		sql := `	
-		insert into tbl_review_log (user_id_token, card_id, source, grade, is_correct, answer_detail)
-		values ($1,$2,$3,$4,$5,$6);
+		insert into tbl_review_log (user_id_token, card_id, source, grade, is_correct, answer_detail, cloze_ord, direction)
+		values ($1,$2,$3,$4,$5,$6,$7,$8);
 		`
-		_, err := tx.Exec(ctx, sql, dto.UserIdToken, dto.CardId, dto.Source, dto.Grade, dto.IsCorrect, dto.AnswerDetail)
+		_, err := tx.Exec(ctx, sql, dto.UserIdToken, dto.CardId, dto.Source, dto.Grade, dto.IsCorrect, dto.AnswerDetail, dto.ClozeOrd, dto.Direction)
 		if err != nil {
 			return err
 		}
@@ -49,11 +51,13 @@ func NewInsertAndMergeUserFlashCardSrsFunc() InsertAndMergeUserFlashCardSrsFunc
 		  SELECT
 			$1::varchar(36) AS user_id_token,
 			$2::bigint      AS card_id,
-			$3::smallint    AS grade
+			$3::smallint    AS grade,
+			$4::smallint    AS cloze_ord,
+			$5::varchar(7)  AS direction
 		),
 		upsert AS (
 		  INSERT INTO tbl_user_flashcard_srs(
-			user_id_token, card_id,
+			user_id_token, card_id, cloze_ord, direction,
 			box, next_review_at, last_review_at,
 			streak, total_reviews, last_grade,
 			created_at, updated_at
@@ -61,6 +65,8 @@ func NewInsertAndMergeUserFlashCardSrsFunc() InsertAndMergeUserFlashCardSrsFunc
 		  SELECT
 			i.user_id_token,
 			i.card_id,
+			i.cloze_ord,
+			i.direction,
 			CASE
 			  WHEN i.grade <= 2 THEN 1
 			  WHEN i.grade = 3 THEN 1
@@ -81,7 +87,7 @@ func NewInsertAndMergeUserFlashCardSrsFunc() InsertAndMergeUserFlashCardSrsFunc
 			now() AS created_at,
 			now() AS updated_at
 		  FROM input i
-		  ON CONFLICT (user_id_token, card_id)
+		  ON CONFLICT (user_id_token, card_id, cloze_ord, direction)
 		  DO UPDATE SET
 			box = (
 			  CASE
@@ -135,7 +141,7 @@ func NewInsertAndMergeUserFlashCardSrsFunc() InsertAndMergeUserFlashCardSrsFunc
 		)
 		SELECT 1 FROM upsert;
 		`
-		_, err := tx.Exec(ctx, sql, dto.UserIdToken, dto.CardId, dto.Grade)
+		_, err := tx.Exec(ctx, sql, dto.UserIdToken, dto.CardId, dto.Grade, dto.ClozeOrd, dto.Direction)
 		return err
 	}
 }
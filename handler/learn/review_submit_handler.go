@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
@@ -28,6 +29,10 @@ func NewReviewSubmitHandler(
 			logger.Warn("failed to parse request", zap.Error(err))
 			return api.InternalError(c, "invalid request")
 		}
+		direction, ok := studyitem.NormalizeDirection(req.Direction)
+		if !ok {
+			return api.BadRequest(c, "direction must be FORWARD or REVERSE")
+		}
 		validate := validator.New()
 		err = validate.Struct(req)
 		if err := validate.Struct(req); err != nil {
@@ -36,6 +41,8 @@ func NewReviewSubmitHandler(
 		err = subMitReviewFunc(ctx, logger, InsertReviewLogDto{
 			UserIdToken:  utils.GetUserIDToken(c),
 			CardId:       req.CardId,
+			ClozeOrd:     req.ClozeOrd,
+			Direction:    direction,
 			Source:       req.Source,
 			IsCorrect:    req.IsCorrect,
 			Grade:        req.Grade,
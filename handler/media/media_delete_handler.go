@@ -0,0 +1,48 @@
+package media
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewMediaDeleteHandler(
+	store blobstore.Store,
+	deleteMediaFunc DeleteMediaFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req MediaDeleteRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		key, err := deleteMediaFunc(ctx, logger, req)
+		if err != nil {
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		// the row is gone; a failed blob delete only leaves an orphan file
+		if key != "" {
+			if err := store.Delete(ctx, key); err != nil {
+				logger.Error("delete media blob failed", zap.String("requestId", requestId), zap.Error(err))
+			}
+		}
+		return api.Ok(c, nil)
+	}
+}
@@ -0,0 +1,118 @@
+package media
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewMediaUploadHandler(
+	cfg config.BlobStoreConfig,
+	store blobstore.Store,
+	checkCardEditorFunc CheckCardEditorFunc,
+	insertMediaFunc InsertMediaFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req MediaUploadRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+		req.UserId = utils.GetUserID(c)
+
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return api.BadRequest(c, "file is required")
+		}
+		maxBytes := cfg.MaxImageBytes
+		if cfg.MaxAudioBytes > maxBytes {
+			maxBytes = cfg.MaxAudioBytes
+		}
+		if fh.Size <= 0 || fh.Size > maxBytes {
+			return api.BadRequest(c, fmt.Sprintf("file must be between 1 and %d bytes", maxBytes))
+		}
+
+		if err := checkCardEditorFunc(ctx, logger, req.CardId, req.UserIdToken); err != nil {
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			logger.Error("open upload failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+		_ = f.Close()
+		if err != nil {
+			logger.Error("read upload failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+
+		contentType, mt, ok := cardmedia.DetectType(data, fh.Filename)
+		if !ok {
+			return api.BadRequest(c, fmt.Sprintf("unsupported media type %s", contentType))
+		}
+		limit := cfg.MaxImageBytes
+		if mt.Kind == cardmedia.KindAudio {
+			limit = cfg.MaxAudioBytes
+		}
+		if int64(len(data)) > limit {
+			return api.BadRequest(c, fmt.Sprintf("%s files must be at most %d bytes", contentType, limit))
+		}
+
+		key := fmt.Sprintf("cards/%s/%s%s", req.CardId.String(), uuid.NewString(), mt.Ext)
+		if err := store.Put(ctx, key, data, contentType); err != nil {
+			logger.Error("store media failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		id, err := insertMediaFunc(ctx, logger, InsertMedia{
+			CardId:       req.CardId,
+			Side:         req.Side,
+			Kind:         mt.Kind,
+			ContentType:  contentType,
+			SizeBytes:    int64(len(data)),
+			StorageKey:   key,
+			OriginalName: fh.Filename,
+			UserId:       req.UserId,
+		})
+		if err != nil {
+			if delErr := store.Delete(ctx, key); delErr != nil {
+				logger.Error("remove orphan media failed", zap.String("requestId", requestId), zap.Error(delErr))
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		return api.Ok(c, cardmedia.Attachment{
+			Id:          decimal.NewFromInt(id),
+			Side:        req.Side,
+			Kind:        mt.Kind,
+			ContentType: contentType,
+			SizeBytes:   int64(len(data)),
+			Url:         store.URL(key),
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+)
+
+type MediaUploadRequest struct {
+	CardId      decimal.Decimal `form:"cardId"`
+	Side        string          `form:"side"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r *MediaUploadRequest) Validate() error {
+	if r.CardId.IsZero() {
+		return errors.New("cardId is required")
+	}
+	r.Side = strings.ToUpper(strings.TrimSpace(r.Side))
+	if r.Side == "" {
+		r.Side = cardmedia.SideFront
+	}
+	if r.Side != cardmedia.SideFront && r.Side != cardmedia.SideBack {
+		return errors.New("side must be 'FRONT' or 'BACK'")
+	}
+	return nil
+}
+
+type MediaDeleteRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	UserIdToken string
+}
+
+func (r MediaDeleteRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// InsertMedia is a stored blob ready to be linked to a card.
+type InsertMedia struct {
+	CardId       decimal.Decimal
+	Side         string
+	Kind         string
+	ContentType  string
+	SizeBytes    int64
+	StorageKey   string
+	OriginalName string
+	UserId       string
+}
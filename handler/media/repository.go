@@ -0,0 +1,106 @@
+package media
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+// canEditSetSQL holds for a set s that user $2 owns or is an accepted
+// editor of, the same roles that may edit its cards.
+const canEditSetSQL = `
+			   AND (s.owner_user_token = $2
+			        OR EXISTS (SELECT 1
+			                     FROM tbl_flashcard_set_collaborators c
+			                    WHERE c.set_id = s.id
+			                      AND c.user_id_token = $2
+			                      AND c.status = 'ACCEPTED'
+			                      AND c.role = 'EDITOR'))
+`
+
+type CheckCardEditorFunc func(ctx context.Context, logger *zap.Logger, cardId interface{}, userIdToken string) error
+
+// NewCheckCardEditor finds a live card the caller may edit; any other card
+// is not found.
+func NewCheckCardEditor(db *pgxpool.Pool) CheckCardEditorFunc {
+	return func(ctx context.Context, logger *zap.Logger, cardId interface{}, userIdToken string) error {
+		const sql = `
+			SELECT 1
+			  FROM tbl_flashcards f
+			  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+			 WHERE f.id = $1
+			   AND f.is_deleted = 'N'
+			   AND s.is_deleted = 'N'
+		` + canEditSetSQL
+		var one int
+		if err := db.QueryRow(ctx, sql, cardId, userIdToken).Scan(&one); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("check card editor failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type InsertMediaFunc func(ctx context.Context, logger *zap.Logger, m InsertMedia) (int64, error)
+
+func NewInsertMedia(db *pgxpool.Pool) InsertMediaFunc {
+	return func(ctx context.Context, logger *zap.Logger, m InsertMedia) (int64, error) {
+		const sql = `
+			INSERT INTO tbl_flashcard_media
+				(card_id, side, kind, content_type, size_bytes, storage_key, original_name, create_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`
+		var id int64
+		if err := db.QueryRow(ctx, sql,
+			m.CardId, m.Side, m.Kind, m.ContentType, m.SizeBytes, m.StorageKey, m.OriginalName, m.UserId,
+		).Scan(&id); err != nil {
+			logger.Error("insert media failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+type DeleteMediaFunc func(ctx context.Context, logger *zap.Logger, req MediaDeleteRequest) (string, error)
+
+// NewDeleteMedia unlinks media from a card the caller may edit and returns the
+// storage key so the blob can be removed, or "" while copies of the card
+// still use the blob.
+func NewDeleteMedia(db *pgxpool.Pool) DeleteMediaFunc {
+	return func(ctx context.Context, logger *zap.Logger, req MediaDeleteRequest) (string, error) {
+		const sql = `
+			WITH deleted AS (
+				DELETE FROM tbl_flashcard_media m
+				 USING tbl_flashcards f, tbl_flashcard_sets s
+				 WHERE m.id = $1
+				   AND f.id = m.card_id
+				   AND s.id = f.set_id
+				` + canEditSetSQL + `
+				RETURNING m.id, m.storage_key
+			)
+			SELECT CASE WHEN EXISTS (SELECT 1
+			                           FROM tbl_flashcard_media o
+			                          WHERE o.storage_key = d.storage_key
+			                            AND o.id <> d.id)
+			            THEN '' ELSE d.storage_key END
+			  FROM deleted d
+		`
+		var key string
+		if err := db.QueryRow(ctx, sql, req.Id, req.UserIdToken).Scan(&key); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return "", errors.New(api.NotFound)
+			}
+			logger.Error("delete media failed", zap.Error(err))
+			return "", errors.New(api.SomeThingWentWrong)
+		}
+		return key, nil
+	}
+}
@@ -0,0 +1,29 @@
+package media
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/middleware"
+)
+
+func GetRouter(
+	group fiber.Router,
+	config config.Config,
+	dbPool *pgxpool.Pool,
+	store blobstore.Store,
+) {
+	mediaGroup := group.Group("/media")
+	maxUpload := max(config.BlobStoreConfig.MaxImageBytes, config.BlobStoreConfig.MaxAudioBytes)
+	mediaGroup.Post("/upload", middleware.UploadLimit(maxUpload), NewMediaUploadHandler(
+		config.BlobStoreConfig,
+		store,
+		NewCheckCardEditor(dbPool),
+		NewInsertMedia(dbPool),
+	))
+	mediaGroup.Post("/delete", NewMediaDeleteHandler(
+		store,
+		NewDeleteMedia(dbPool),
+	))
+}
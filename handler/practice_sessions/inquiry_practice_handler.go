@@ -0,0 +1,70 @@
+package practice_sessions
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewInquiryPracticeHandler(
+	getPracticeSessionFunc GetPracticeSessionFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		sessionId, err := strconv.ParseInt(c.Params("sessionId"), 10, 64)
+		if err != nil || sessionId <= 0 {
+			return api.BadRequest(c, "sessionId is required")
+		}
+
+		resp, err := getPracticeSessionFunc(ctx, logger, sessionId, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
+
+// NewResumePracticeHandler returns the caller's latest active session on a
+// set, optionally of one name, so another device can carry on with it.
+func NewResumePracticeHandler(
+	findActivePracticeSessionFunc FindActivePracticeSessionFunc,
+	getPracticeSessionFunc GetPracticeSessionFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		setId, err := strconv.ParseInt(c.Query("setId"), 10, 64)
+		if err != nil || setId <= 0 {
+			return api.BadRequest(c, "setId is required")
+		}
+		userIdToken := utils.GetUserIDToken(c)
+
+		sessionId, err := findActivePracticeSessionFunc(ctx, logger, setId, c.Query("name"), userIdToken)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			if err.Error() == api.NotFound {
+				return api.NotFoundError(c, api.NotFound)
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		resp, err := getPracticeSessionFunc(ctx, logger, sessionId, userIdToken)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("requestId", requestId))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
@@ -0,0 +1,205 @@
+package practice_sessions
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cloze"
+)
+
+const (
+	SessionStatusActive    = "ACTIVE"
+	SessionStatusCompleted = "COMPLETED"
+	SessionStatusAbandoned = "ABANDONED"
+
+	ResultCorrect = "CORRECT"
+	ResultWrong   = "WRONG"
+	ResultSkipped = "SKIPPED"
+
+	DefaultSessionName   = "default"
+	maxSessionNameLength = 100
+)
+
+// PracticeFilters narrows the cards a session is started over. Empty fields
+// do not filter; statuses are the caller's own card statuses and tags are the
+// set owner's tags.
+type PracticeFilters struct {
+	Statuses []string `json:"statuses,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	CardType string   `json:"cardType,omitempty"`
+	DueOnly  bool     `json:"dueOnly,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+}
+
+// PracticeStartRequest starts a named session over a set. With shuffle the
+// cards are ordered by seed, a random one when none is given, so the same
+// seed and filters always give the same order.
+type PracticeStartRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Name        string          `json:"name"`
+	Shuffle     bool            `json:"shuffle"`
+	Seed        *int64          `json:"seed"`
+	Filters     PracticeFilters `json:"filters"`
+	UserIdToken string
+}
+
+func (r *PracticeStartRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		r.Name = DefaultSessionName
+	}
+	if len([]rune(r.Name)) > maxSessionNameLength {
+		return errors.Errorf("name must be at most %d characters", maxSessionNameLength)
+	}
+	if r.Filters.Limit < 0 {
+		return errors.New("filters.limit must be >= 0")
+	}
+	if r.Filters.CardType != "" {
+		cardType, ok := cloze.NormalizeCardType(r.Filters.CardType)
+		if !ok {
+			return errors.New("filters.cardType must be BASIC or CLOZE")
+		}
+		r.Filters.CardType = cardType
+	}
+	tags := make([]string, 0, len(r.Filters.Tags))
+	for _, t := range r.Filters.Tags {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	r.Filters.Tags = tags
+	statuses := make([]string, 0, len(r.Filters.Statuses))
+	for _, s := range r.Filters.Statuses {
+		if s = strings.TrimSpace(s); s != "" {
+			statuses = append(statuses, s)
+		}
+	}
+	r.Filters.Statuses = statuses
+	return nil
+}
+
+// PracticeAnswerRequest records the result of one card and moves the session
+// past it.
+type PracticeAnswerRequest struct {
+	SessionId   decimal.Decimal `json:"sessionId"`
+	CardId      decimal.Decimal `json:"cardId"`
+	Result      string          `json:"result"`
+	UserIdToken string
+}
+
+func (r *PracticeAnswerRequest) Validate() error {
+	if r.SessionId.IsZero() {
+		return errors.New("sessionId is required")
+	}
+	if r.CardId.IsZero() {
+		return errors.New("cardId is required")
+	}
+	r.Result = strings.ToUpper(strings.TrimSpace(r.Result))
+	switch r.Result {
+	case ResultCorrect, ResultWrong, ResultSkipped:
+		return nil
+	}
+	return errors.New("result must be CORRECT, WRONG or SKIPPED")
+}
+
+// PracticeSeekRequest moves a session to another position without answering.
+type PracticeSeekRequest struct {
+	SessionId   decimal.Decimal `json:"sessionId"`
+	Position    int             `json:"position"`
+	UserIdToken string
+}
+
+func (r PracticeSeekRequest) Validate() error {
+	if r.SessionId.IsZero() {
+		return errors.New("sessionId is required")
+	}
+	if r.Position < 0 {
+		return errors.New("position must be >= 0")
+	}
+	return nil
+}
+
+type PracticeFinishRequest struct {
+	SessionId   decimal.Decimal `json:"sessionId"`
+	UserIdToken string
+}
+
+func (r PracticeFinishRequest) Validate() error {
+	if r.SessionId.IsZero() {
+		return errors.New("sessionId is required")
+	}
+	return nil
+}
+
+type PracticeSessionListRequest struct {
+	Page   decimal.Decimal  `json:"page"`
+	Size   decimal.Decimal  `json:"size"`
+	SetId  *decimal.Decimal `json:"setId"`
+	Status string           `json:"status"`
+}
+
+func (r *PracticeSessionListRequest) Validate() error {
+	if r.Page.LessThanOrEqual(decimal.Zero) {
+		r.Page = decimal.NewFromInt(1)
+	}
+	if r.Size.LessThanOrEqual(decimal.Zero) {
+		r.Size = decimal.NewFromInt(20)
+	}
+	r.Status = strings.ToUpper(strings.TrimSpace(r.Status))
+	switch r.Status {
+	case "", SessionStatusActive, SessionStatusCompleted, SessionStatusAbandoned:
+		return nil
+	}
+	return errors.New("status must be ACTIVE, COMPLETED or ABANDONED")
+}
+
+// PracticeSessionSummary is where a session stands. Cards deleted or moved
+// out of the set since the session started are not counted.
+type PracticeSessionSummary struct {
+	Id             decimal.Decimal `json:"id"`
+	SetId          decimal.Decimal `json:"setId"`
+	SetTitle       string          `json:"setTitle"`
+	Name           string          `json:"name"`
+	Status         string          `json:"status"`
+	Seed           *int64          `json:"seed"`
+	Filters        json.RawMessage `json:"filters"`
+	Position       int             `json:"position"`
+	TotalCards     int             `json:"totalCards"`
+	Answered       int             `json:"answered"`
+	Correct        int             `json:"correct"`
+	Wrong          int             `json:"wrong"`
+	Skipped        int             `json:"skipped"`
+	Accuracy       float64         `json:"accuracy"`
+	StartedAt      time.Time       `json:"startedAt"`
+	LastActivityAt time.Time       `json:"lastActivityAt"`
+	CompletedAt    *time.Time      `json:"completedAt"`
+	DurationSec    int64           `json:"durationSec"`
+}
+
+type PracticeSessionCard struct {
+	Seq        int             `json:"seq"`
+	CardId     decimal.Decimal `json:"cardId"`
+	Front      string          `json:"front"`
+	Back       string          `json:"back"`
+	Choices    []string        `json:"choices"`
+	CardType   string          `json:"cardType"`
+	Result     *string         `json:"result"`
+	AnsweredAt *time.Time      `json:"answeredAt"`
+}
+
+type PracticeSessionResponse struct {
+	PracticeSessionSummary
+	Cards []PracticeSessionCard `json:"cards"`
+}
+
+type PracticeSessionListResponse struct {
+	Content       []PracticeSessionSummary `json:"content"`
+	TotalPage     decimal.Decimal          `json:"totalPage"`
+	TotalElements decimal.Decimal          `json:"totalElements"`
+}
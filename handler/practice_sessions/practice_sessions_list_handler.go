@@ -0,0 +1,36 @@
+package practice_sessions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewPracticeSessionsListHandler(
+	listPracticeSessionsFunc ListPracticeSessionsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req PracticeSessionListRequest
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+
+		resp, err := listPracticeSessionsFunc(ctx, logger, req, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error("list practice sessions failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
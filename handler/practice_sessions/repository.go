@@ -0,0 +1,604 @@
+package practice_sessions
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrNoCardsToPractice = "no cards match the session filters"
+	ErrSessionNotActive  = "practice session is not active"
+	ErrCardNotInSession  = "card is not part of this practice session"
+	ErrInvalidPosition   = "position is out of range"
+)
+
+// summarySQL selects a session summary; callers append their WHERE clause.
+// Cards deleted or moved out of the set since the session started are left
+// out of every count.
+const summarySQL = `
+    SELECT ps.id, ps.set_id, coalesce(s.title, ''), ps.name, ps.status, ps.shuffle_seed,
+           coalesce(ps.filters, '{}'::jsonb), ps.position, ps.started_at, ps.last_activity_at,
+           ps.completed_at, r.total, r.answered, r.correct, r.wrong, r.skipped
+      FROM tbl_practice_sessions ps
+      JOIN tbl_flashcard_sets s ON s.id = ps.set_id
+     CROSS JOIN LATERAL (
+            SELECT count(*)                                        AS total,
+                   count(c.result)                                 AS answered,
+                   count(*) FILTER (WHERE c.result = 'CORRECT')    AS correct,
+                   count(*) FILTER (WHERE c.result = 'WRONG')      AS wrong,
+                   count(*) FILTER (WHERE c.result = 'SKIPPED')    AS skipped
+              FROM tbl_practice_session_cards c
+              JOIN tbl_flashcards f ON f.id = c.card_id
+             WHERE c.session_id = ps.id
+               AND f.set_id = ps.set_id
+               AND f.is_deleted = 'N'
+           ) r
+`
+
+type summaryScanner interface {
+	Scan(dest ...any) error
+}
+
+// rowQuerier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func scanSummary(row summaryScanner) (PracticeSessionSummary, error) {
+	var (
+		s       PracticeSessionSummary
+		id      int64
+		setId   int64
+		filters []byte
+	)
+	if err := row.Scan(
+		&id, &setId, &s.SetTitle, &s.Name, &s.Status, &s.Seed,
+		&filters, &s.Position, &s.StartedAt, &s.LastActivityAt,
+		&s.CompletedAt, &s.TotalCards, &s.Answered, &s.Correct, &s.Wrong, &s.Skipped,
+	); err != nil {
+		return s, err
+	}
+	s.Id = decimal.NewFromInt(id)
+	s.SetId = decimal.NewFromInt(setId)
+	s.Filters = json.RawMessage(filters)
+	s.fillStats()
+	return s, nil
+}
+
+// loadSummary returns the caller's session summary or api.NotFound.
+func loadSummary(ctx context.Context, q rowQuerier, sessionId int64, userIdToken string) (PracticeSessionSummary, error) {
+	const sql = summarySQL + `
+     WHERE ps.id = $1
+       AND ps.user_id_token = $2
+    `
+	s, err := scanSummary(q.QueryRow(ctx, sql, sessionId, userIdToken))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, errors.New(api.NotFound)
+	}
+	return s, err
+}
+
+type StartPracticeSessionFunc func(ctx context.Context, logger *zap.Logger, req PracticeStartRequest) (int64, error)
+
+// NewStartPracticeSession starts a session over a set the caller can see.
+// The card order is fixed at start; an active session of the same name on
+// the set is abandoned, so a name always resumes the latest run.
+func NewStartPracticeSession(db *pgxpool.Pool) StartPracticeSessionFunc {
+	const setSQL = `
+        SELECT 1
+          FROM tbl_flashcard_sets s
+         WHERE s.id = $1
+           AND s.is_deleted = 'N'
+           AND (s.owner_user_token = $2
+                OR s.is_public = 'Y'
+                OR EXISTS (SELECT 1
+                             FROM tbl_flashcard_set_collaborators c
+                            WHERE c.set_id = s.id
+                              AND c.user_id_token = $2
+                              AND c.status = 'ACCEPTED'))
+    `
+	const cardsSQL = `
+        SELECT f.id
+          FROM tbl_flashcards f
+          JOIN tbl_flashcard_sets s ON s.id = f.set_id
+          LEFT JOIN tbl_user_flashcard_status us
+                 ON us.card_id = f.id
+                AND us.user_id_token = $2
+         WHERE f.set_id = $1
+           AND f.is_deleted = 'N'
+           AND (cardinality($3::text[]) = 0 OR coalesce(us.status, 'studying') = ANY($3::text[]))
+           AND (cardinality($4::text[]) = 0 OR EXISTS (
+                    SELECT 1
+                      FROM tbl_flashcard_tags ct
+                      JOIN tbl_tags t ON t.id = ct.tag_id
+                     WHERE ct.card_id = f.id
+                       AND t.user_id_token = $2
+                       AND lower(t.name) = ANY($4::text[])))
+           AND ($5 = '' OR f.card_type = $5)
+           AND (NOT $6::boolean
+                OR NOT EXISTS (SELECT 1
+                                 FROM tbl_user_flashcard_srs r
+                                WHERE r.card_id = f.id
+                                  AND r.user_id_token = $2)
+                OR EXISTS (SELECT 1
+                             FROM tbl_user_flashcard_srs r
+                            WHERE r.card_id = f.id
+                              AND r.user_id_token = $2
+                              AND (r.next_review_at IS NULL OR r.next_review_at <= now())))
+         ORDER BY f.seq, f.id
+    `
+	const abandonSQL = `
+        UPDATE tbl_practice_sessions
+           SET status           = 'ABANDONED',
+               last_activity_at = now()
+         WHERE user_id_token = $1
+           AND set_id = $2
+           AND name = $3
+           AND status = 'ACTIVE'
+    `
+	const insertSQL = `
+        INSERT INTO tbl_practice_sessions (user_id_token, set_id, name, shuffle_seed, filters)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id
+    `
+	const insertCardsSQL = `
+        INSERT INTO tbl_practice_session_cards (session_id, seq, card_id)
+        SELECT $1, u.ord - 1, u.card_id
+          FROM unnest($2::bigint[]) WITH ORDINALITY AS u(card_id, ord)
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PracticeStartRequest) (id int64, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var one int
+		if err = tx.QueryRow(ctx, setSQL, req.SetId, req.UserIdToken).Scan(&one); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("check set access failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+
+		rows, err := tx.Query(ctx, cardsSQL,
+			req.SetId, req.UserIdToken, req.Filters.Statuses, req.Filters.Tags,
+			req.Filters.CardType, req.Filters.DueOnly,
+		)
+		if err != nil {
+			logger.Error("select practice cards failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		var ids []int64
+		for rows.Next() {
+			var cardId int64
+			if err = rows.Scan(&cardId); err != nil {
+				rows.Close()
+				logger.Error("scan practice card failed", zap.Error(err))
+				return 0, errors.New(api.SomeThingWentWrong)
+			}
+			ids = append(ids, cardId)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			logger.Error("iterate practice cards failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if len(ids) == 0 {
+			return 0, errors.New(ErrNoCardsToPractice)
+		}
+
+		var seed *int64
+		if req.Shuffle {
+			s := time.Now().UnixNano()
+			if req.Seed != nil {
+				s = *req.Seed
+			}
+			seed = &s
+			shuffleCardIds(ids, s)
+		}
+		if req.Filters.Limit > 0 && len(ids) > req.Filters.Limit {
+			ids = ids[:req.Filters.Limit]
+		}
+
+		filters, err := json.Marshal(req.Filters)
+		if err != nil {
+			logger.Error("marshal filters failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, abandonSQL, req.UserIdToken, req.SetId, req.Name); err != nil {
+			logger.Error("abandon previous session failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if err = tx.QueryRow(ctx, insertSQL,
+			req.UserIdToken, req.SetId, req.Name, seed, filters,
+		).Scan(&id); err != nil {
+			logger.Error("insert practice session failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, insertCardsSQL, id, ids); err != nil {
+			logger.Error("insert practice session cards failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+type GetPracticeSessionFunc func(ctx context.Context, logger *zap.Logger, sessionId int64, userIdToken string) (PracticeSessionResponse, error)
+
+// NewGetPracticeSession returns a session with its cards in session order, so
+// any device can resume it at Position.
+func NewGetPracticeSession(db *pgxpool.Pool) GetPracticeSessionFunc {
+	const cardsSQL = `
+        SELECT c.seq, c.card_id, coalesce(f.front, ''), coalesce(f.back, ''), f.choices,
+               f.card_type, c.result, c.answered_at
+          FROM tbl_practice_session_cards c
+          JOIN tbl_practice_sessions ps ON ps.id = c.session_id
+          JOIN tbl_flashcards f ON f.id = c.card_id
+         WHERE c.session_id = $1
+           AND f.set_id = ps.set_id
+           AND f.is_deleted = 'N'
+         ORDER BY c.seq
+    `
+	return func(ctx context.Context, logger *zap.Logger, sessionId int64, userIdToken string) (PracticeSessionResponse, error) {
+		var resp PracticeSessionResponse
+		summary, err := loadSummary(ctx, db, sessionId, userIdToken)
+		if err != nil {
+			if err.Error() == api.NotFound {
+				return resp, err
+			}
+			logger.Error("load practice session failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		resp.PracticeSessionSummary = summary
+
+		rows, err := db.Query(ctx, cardsSQL, sessionId)
+		if err != nil {
+			logger.Error("query practice session cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		resp.Cards = []PracticeSessionCard{}
+		for rows.Next() {
+			var (
+				card   PracticeSessionCard
+				cardId int64
+			)
+			if err := rows.Scan(
+				&card.Seq, &cardId, &card.Front, &card.Back, &card.Choices,
+				&card.CardType, &card.Result, &card.AnsweredAt,
+			); err != nil {
+				logger.Error("scan practice session card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			card.CardId = decimal.NewFromInt(cardId)
+			if card.Choices == nil {
+				card.Choices = []string{}
+			}
+			resp.Cards = append(resp.Cards, card)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate practice session cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
+
+type FindActivePracticeSessionFunc func(ctx context.Context, logger *zap.Logger, setId int64, name, userIdToken string) (int64, error)
+
+// NewFindActivePracticeSession returns the caller's latest active session on
+// a set, limited to one name when name is not empty.
+func NewFindActivePracticeSession(db *pgxpool.Pool) FindActivePracticeSessionFunc {
+	const sql = `
+        SELECT id
+          FROM tbl_practice_sessions
+         WHERE user_id_token = $1
+           AND set_id = $2
+           AND ($3 = '' OR name = $3)
+           AND status = 'ACTIVE'
+         ORDER BY last_activity_at DESC, id DESC
+         LIMIT 1
+    `
+	return func(ctx context.Context, logger *zap.Logger, setId int64, name, userIdToken string) (int64, error) {
+		var id int64
+		if err := db.QueryRow(ctx, sql, userIdToken, setId, name).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return 0, errors.New(api.NotFound)
+			}
+			logger.Error("find active practice session failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+// lockActiveSessionTx locks the caller's session and checks it is still
+// active.
+func lockActiveSessionTx(ctx context.Context, tx pgx.Tx, sessionId decimal.Decimal, userIdToken string) error {
+	const sql = `
+        SELECT status
+          FROM tbl_practice_sessions
+         WHERE id = $1
+           AND user_id_token = $2
+           FOR UPDATE
+    `
+	var status string
+	if err := tx.QueryRow(ctx, sql, sessionId, userIdToken).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New(api.NotFound)
+		}
+		return err
+	}
+	if status != SessionStatusActive {
+		return errors.New(ErrSessionNotActive)
+	}
+	return nil
+}
+
+type AnswerPracticeCardFunc func(ctx context.Context, logger *zap.Logger, req PracticeAnswerRequest) (PracticeSessionSummary, error)
+
+// NewAnswerPracticeCard records the result of a card and moves the session to
+// the card after it. Answering the last card completes the session; a card
+// can be answered again, the latest result wins.
+func NewAnswerPracticeCard(db *pgxpool.Pool) AnswerPracticeCardFunc {
+	const answerSQL = `
+        UPDATE tbl_practice_session_cards
+           SET result      = $3,
+               answered_at = now()
+         WHERE session_id = $1
+           AND card_id = $2
+        RETURNING seq
+    `
+	const countSQL = `
+        SELECT count(*)
+          FROM tbl_practice_session_cards
+         WHERE session_id = $1
+    `
+	const moveSQL = `
+        UPDATE tbl_practice_sessions
+           SET position         = $2,
+               status           = CASE WHEN $3 THEN 'COMPLETED' ELSE status END,
+               completed_at     = CASE WHEN $3 THEN now() ELSE completed_at END,
+               last_activity_at = now()
+         WHERE id = $1
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PracticeAnswerRequest) (summary PracticeSessionSummary, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if err = lockActiveSessionTx(ctx, tx, req.SessionId, req.UserIdToken); err != nil {
+			switch err.Error() {
+			case api.NotFound, ErrSessionNotActive:
+				return summary, err
+			}
+			logger.Error("lock practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+
+		var seq int
+		if err = tx.QueryRow(ctx, answerSQL, req.SessionId, req.CardId, req.Result).Scan(&seq); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return summary, errors.New(ErrCardNotInSession)
+			}
+			logger.Error("record practice result failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		var total int
+		if err = tx.QueryRow(ctx, countSQL, req.SessionId).Scan(&total); err != nil {
+			logger.Error("count practice session cards failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		position := seq + 1
+		if _, err = tx.Exec(ctx, moveSQL, req.SessionId, position, position >= total); err != nil {
+			logger.Error("move practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+
+		if summary, err = loadSummary(ctx, tx, req.SessionId.IntPart(), req.UserIdToken); err != nil {
+			logger.Error("load practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		return summary, nil
+	}
+}
+
+type SeekPracticeSessionFunc func(ctx context.Context, logger *zap.Logger, req PracticeSeekRequest) (PracticeSessionSummary, error)
+
+// NewSeekPracticeSession moves an active session to another card, e.g. to go
+// back and look at a card again.
+func NewSeekPracticeSession(db *pgxpool.Pool) SeekPracticeSessionFunc {
+	const seekSQL = `
+        UPDATE tbl_practice_sessions ps
+           SET position         = $2,
+               last_activity_at = now()
+         WHERE ps.id = $1
+           AND $2 < (SELECT count(*)
+                       FROM tbl_practice_session_cards c
+                      WHERE c.session_id = ps.id)
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PracticeSeekRequest) (summary PracticeSessionSummary, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if err = lockActiveSessionTx(ctx, tx, req.SessionId, req.UserIdToken); err != nil {
+			switch err.Error() {
+			case api.NotFound, ErrSessionNotActive:
+				return summary, err
+			}
+			logger.Error("lock practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		tag, err := tx.Exec(ctx, seekSQL, req.SessionId, req.Position)
+		if err != nil {
+			logger.Error("seek practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return summary, errors.New(ErrInvalidPosition)
+		}
+
+		if summary, err = loadSummary(ctx, tx, req.SessionId.IntPart(), req.UserIdToken); err != nil {
+			logger.Error("load practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		return summary, nil
+	}
+}
+
+type FinishPracticeSessionFunc func(ctx context.Context, logger *zap.Logger, req PracticeFinishRequest) (PracticeSessionSummary, error)
+
+// NewFinishPracticeSession completes an active session before its last card
+// and returns the final summary.
+func NewFinishPracticeSession(db *pgxpool.Pool) FinishPracticeSessionFunc {
+	const finishSQL = `
+        UPDATE tbl_practice_sessions
+           SET status           = 'COMPLETED',
+               completed_at     = now(),
+               last_activity_at = now()
+         WHERE id = $1
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PracticeFinishRequest) (summary PracticeSessionSummary, err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		if err = lockActiveSessionTx(ctx, tx, req.SessionId, req.UserIdToken); err != nil {
+			switch err.Error() {
+			case api.NotFound, ErrSessionNotActive:
+				return summary, err
+			}
+			logger.Error("lock practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, finishSQL, req.SessionId); err != nil {
+			logger.Error("finish practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+
+		if summary, err = loadSummary(ctx, tx, req.SessionId.IntPart(), req.UserIdToken); err != nil {
+			logger.Error("load practice session failed", zap.Error(err))
+			return summary, errors.New(api.SomeThingWentWrong)
+		}
+		return summary, nil
+	}
+}
+
+type ListPracticeSessionsFunc func(ctx context.Context, logger *zap.Logger, req PracticeSessionListRequest, userIdToken string) (PracticeSessionListResponse, error)
+
+// NewListPracticeSessions pages the caller's session summaries, latest
+// activity first.
+func NewListPracticeSessions(db *pgxpool.Pool) ListPracticeSessionsFunc {
+	const whereSQL = `
+     WHERE ps.user_id_token = $1
+       AND s.is_deleted = 'N'
+       AND ($2::int IS NULL OR ps.set_id = $2)
+       AND ($3 = '' OR ps.status = $3)
+    `
+	const countSQL = `
+    SELECT count(*)
+      FROM tbl_practice_sessions ps
+      JOIN tbl_flashcard_sets s ON s.id = ps.set_id
+    ` + whereSQL
+	const listSQL = summarySQL + whereSQL + `
+     ORDER BY ps.last_activity_at DESC, ps.id DESC
+    OFFSET $4 LIMIT $5
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PracticeSessionListRequest, userIdToken string) (PracticeSessionListResponse, error) {
+		resp := PracticeSessionListResponse{Content: []PracticeSessionSummary{}}
+		size := int(req.Size.IntPart())
+		offset := (int(req.Page.IntPart()) - 1) * size
+
+		var setId *int64
+		if req.SetId != nil {
+			id := req.SetId.IntPart()
+			setId = &id
+		}
+
+		if err := db.QueryRow(ctx, countSQL, userIdToken, setId, req.Status).Scan(&resp.TotalElements); err != nil {
+			logger.Error("count practice sessions failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		totalPage, _ := resp.TotalElements.Div(req.Size).Float64()
+		resp.TotalPage = decimal.NewFromFloat(math.Ceil(totalPage))
+
+		rows, err := db.Query(ctx, listSQL, userIdToken, setId, req.Status, offset, size)
+		if err != nil {
+			logger.Error("list practice sessions failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s, err := scanSummary(rows)
+			if err != nil {
+				logger.Error("scan practice session failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			resp.Content = append(resp.Content, s)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate practice sessions failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
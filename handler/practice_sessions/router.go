@@ -0,0 +1,36 @@
+package practice_sessions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetRouter(
+	group fiber.Router,
+	dbPool *pgxpool.Pool,
+) {
+	practiceGroup := group.Group("/practice-sessions")
+	practiceGroup.Post("", NewPracticeSessionsListHandler(
+		NewListPracticeSessions(dbPool),
+	))
+	practiceGroup.Post("/start", NewStartPracticeHandler(
+		NewStartPracticeSession(dbPool),
+		NewGetPracticeSession(dbPool),
+	))
+	practiceGroup.Get("/resume", NewResumePracticeHandler(
+		NewFindActivePracticeSession(dbPool),
+		NewGetPracticeSession(dbPool),
+	))
+	practiceGroup.Put("/answer", NewAnswerPracticeHandler(
+		NewAnswerPracticeCard(dbPool),
+	))
+	practiceGroup.Put("/seek", NewSeekPracticeHandler(
+		NewSeekPracticeSession(dbPool),
+	))
+	practiceGroup.Put("/finish", NewFinishPracticeHandler(
+		NewFinishPracticeSession(dbPool),
+	))
+	practiceGroup.Get("/:sessionId", NewInquiryPracticeHandler(
+		NewGetPracticeSession(dbPool),
+	))
+}
@@ -0,0 +1,50 @@
+package practice_sessions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewStartPracticeHandler(
+	startPracticeSessionFunc StartPracticeSessionFunc,
+	getPracticeSessionFunc GetPracticeSessionFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req PracticeStartRequest
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		sessionId, err := startPracticeSessionFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error("start practice session failed", zap.String("requestId", requestId), zap.Error(err))
+			switch err.Error() {
+			case api.NotFound:
+				return api.NotFoundError(c, api.NotFound)
+			case ErrNoCardsToPractice:
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		resp, err := getPracticeSessionFunc(ctx, logger, sessionId, req.UserIdToken)
+		if err != nil {
+			logger.Error("load practice session failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
@@ -0,0 +1,30 @@
+package practice_sessions
+
+import (
+	"math"
+	"math/rand"
+)
+
+// shuffleCardIds orders ids by seed in place; the same seed over the same ids
+// always gives the same order, so a session can be replayed.
+func shuffleCardIds(ids []int64, seed int64) {
+	r := rand.New(rand.NewSource(seed))
+	r.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+}
+
+// fillStats derives accuracy, over the cards answered right or wrong, and the
+// time spent so far.
+func (s *PracticeSessionSummary) fillStats() {
+	if graded := s.Correct + s.Wrong; graded > 0 {
+		s.Accuracy = math.Round(float64(s.Correct)*10000/float64(graded)) / 100
+	}
+	end := s.LastActivityAt
+	if s.CompletedAt != nil {
+		end = *s.CompletedAt
+	}
+	if d := end.Sub(s.StartedAt); d > 0 {
+		s.DurationSec = int64(d.Seconds())
+	}
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ScopeAll   = "all"
+	ScopeSets  = "sets"
+	ScopeCards = "cards"
+
+	HitTypeSet  = "set"
+	HitTypeCard = "card"
+)
+
+type SearchRequest struct {
+	Query string          `json:"query"`
+	Scope string          `json:"scope"` // all|sets|cards
+	Page  decimal.Decimal `json:"page"`
+	Size  decimal.Decimal `json:"size"`
+}
+
+func (r *SearchRequest) Validate() error {
+	r.Query = strings.TrimSpace(r.Query)
+	if r.Query == "" {
+		return errors.New("query is required")
+	}
+	if len([]rune(r.Query)) > 200 {
+		return errors.New("query must be at most 200 characters")
+	}
+	switch r.Scope {
+	case "":
+		r.Scope = ScopeAll
+	case ScopeAll, ScopeSets, ScopeCards:
+	default:
+		return errors.New("scope must be all, sets or cards")
+	}
+	if r.Page.IsZero() || r.Page.IsNegative() {
+		return errors.New("page is required")
+	}
+	if r.Size.IsZero() || r.Size.IsNegative() {
+		return errors.New("size is required")
+	}
+	if r.Size.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("size must be at most 100")
+	}
+	return nil
+}
+
+type SearchHit struct {
+	Type     string           `json:"type"` // set|card
+	SetId    decimal.Decimal  `json:"setId"`
+	CardId   *decimal.Decimal `json:"cardId,omitempty"`
+	SetTitle string           `json:"setTitle"`
+	Front    string           `json:"front,omitempty"`
+	Back     string           `json:"back,omitempty"`
+	Rank     float64          `json:"rank"`
+	Snippet  string           `json:"snippet"` // escaped HTML, matches in <mark>
+}
+
+type SearchResponse struct {
+	Content       []SearchHit     `json:"content"`
+	TotalPage     decimal.Decimal `json:"totalPage"`
+	TotalElements decimal.Decimal `json:"totalElements"`
+}
@@ -0,0 +1,162 @@
+package search
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+type SearchFunc func(
+	ctx context.Context,
+	logger *zap.Logger,
+	req SearchRequest,
+	userIdToken string,
+) (SearchResponse, error)
+
+// NewSearch ranks set titles/descriptions and card front/back visible to the
+// caller. Word matches use the tsvector columns; substring matches through
+// the trigram indexes cover scripts without word boundaries.
+func NewSearch(db *pgxpool.Pool) SearchFunc {
+	const searchSQL = `
+		WITH q AS (
+			SELECT websearch_to_tsquery('simple', $1) AS tsq,
+			       lower($1)                          AS raw,
+			       '%' || $2 || '%'                   AS pattern
+		),
+		hits AS (
+			SELECT 'set'                                                      AS hit_type,
+			       s.id                                                       AS set_id,
+			       NULL::bigint                                               AS card_id,
+			       coalesce(s.title, '')                                      AS set_title,
+			       ''                                                         AS front,
+			       ''                                                         AS back,
+			       coalesce(s.title, '') || ' ' || coalesce(s.description, '') AS doc,
+			       GREATEST(
+			           ts_rank(s.search_vector, q.tsq),
+			           similarity(lower(coalesce(s.title, '') || ' ' || coalesce(s.description, '')), q.raw)
+			       ) * 1.2                                                    AS rank
+			  FROM tbl_flashcard_sets s, q
+			 WHERE $3 IN ('all', 'sets')
+			   AND s.is_deleted = 'N'
+			   AND (s.owner_user_token = $4
+			        OR s.is_public = 'Y'
+			        OR EXISTS (SELECT 1
+			                     FROM tbl_flashcard_set_collaborators c
+			                    WHERE c.set_id = s.id
+			                      AND c.user_id_token = $4
+			                      AND c.status = 'ACCEPTED'))
+			   AND (s.search_vector @@ q.tsq
+			        OR lower(coalesce(s.title, '') || ' ' || coalesce(s.description, '')) LIKE q.pattern)
+			UNION ALL
+			SELECT 'card',
+			       f.set_id,
+			       f.id,
+			       coalesce(s.title, ''),
+			       coalesce(f.front, ''),
+			       coalesce(f.back, ''),
+			       coalesce(f.front, '') || ' ' || coalesce(f.back, ''),
+			       GREATEST(
+			           ts_rank(f.search_vector, q.tsq),
+			           similarity(lower(coalesce(f.front, '') || ' ' || coalesce(f.back, '')), q.raw)
+			       )
+			  FROM tbl_flashcards f
+			  JOIN tbl_flashcard_sets s ON s.id = f.set_id, q
+			 WHERE $3 IN ('all', 'cards')
+			   AND f.is_deleted = 'N'
+			   AND s.is_deleted = 'N'
+			   AND (s.owner_user_token = $4
+			        OR s.is_public = 'Y'
+			        OR EXISTS (SELECT 1
+			                     FROM tbl_flashcard_set_collaborators c
+			                    WHERE c.set_id = s.id
+			                      AND c.user_id_token = $4
+			                      AND c.status = 'ACCEPTED'))
+			   AND (f.search_vector @@ q.tsq
+			        OR lower(coalesce(f.front, '') || ' ' || coalesce(f.back, '')) LIKE q.pattern)
+		)
+		SELECT h.hit_type, h.set_id, h.card_id, h.set_title, h.front, h.back, h.rank, h.doc,
+		       ts_headline('simple', translate(h.doc, $8, ''), q.tsq, $7),
+		       count(*) OVER ()
+		  FROM hits h, q
+		 ORDER BY h.rank DESC, h.set_id, h.card_id NULLS FIRST
+		OFFSET $5 LIMIT $6
+	`
+	return func(
+		ctx context.Context,
+		logger *zap.Logger,
+		req SearchRequest,
+		userIdToken string,
+	) (SearchResponse, error) {
+		resp := SearchResponse{Content: []SearchHit{}}
+		page := int(req.Page.IntPart())
+		size := int(req.Size.IntPart())
+		offset := (page - 1) * size
+
+		rows, err := db.Query(ctx, searchSQL,
+			req.Query,
+			utils.EscapeLike(strings.ToLower(req.Query)),
+			req.Scope,
+			userIdToken,
+			offset,
+			size,
+			headlineOptions,
+			markStart+markStop,
+		)
+		if err != nil {
+			logger.Error("search query failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		var total int64
+		for rows.Next() {
+			var (
+				hit      SearchHit
+				setID    int64
+				cardID   *int64
+				doc      string
+				headline string
+			)
+			if err := rows.Scan(
+				&hit.Type,
+				&setID,
+				&cardID,
+				&hit.SetTitle,
+				&hit.Front,
+				&hit.Back,
+				&hit.Rank,
+				&doc,
+				&headline,
+				&total,
+			); err != nil {
+				logger.Error("scan search hit failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			hit.SetId = decimal.NewFromInt(setID)
+			if cardID != nil {
+				id := decimal.NewFromInt(*cardID)
+				hit.CardId = &id
+			}
+			hit.Snippet = escapeHeadline(headline)
+			if !strings.Contains(headline, markStart) {
+				hit.Snippet = substringSnippet(doc, req.Query)
+			}
+			resp.Content = append(resp.Content, hit)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterating search hits failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		resp.TotalElements = decimal.NewFromInt(total)
+		resp.TotalPage = decimal.NewFromInt(int64(math.Ceil(float64(total) / float64(size))))
+		return resp, nil
+	}
+}
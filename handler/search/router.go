@@ -0,0 +1,16 @@
+package search
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetRouter(
+	group fiber.Router,
+	dbPool *pgxpool.Pool,
+) {
+	searchGroup := group.Group("/search")
+	searchGroup.Post("", NewSearchHandler(
+		NewSearch(dbPool),
+	))
+}
@@ -1,20 +1,18 @@
-package flashcard_sets
+package search
 
 import (
 	"github.com/gofiber/fiber/v2"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
-func NewInsertAndMergeFlashCardSetsTrackerHandler(
-	insertAndMergeFlashCardSetsTrackerFunc InsertAndMergeFlashCardSetsTrackerFunc,
+func NewSearchHandler(
+	searchFunc SearchFunc,
 ) fiber.Handler {
-
 	return func(c *fiber.Ctx) error {
-		var (
-			req FlashCardSetsTrackerUpsert
-		)
+		var req SearchRequest
 		ctx := c.Context()
 		logger := logz.NewLogger()
 		requestId := c.Get("requestId")
@@ -26,13 +24,12 @@ func NewInsertAndMergeFlashCardSetsTrackerHandler(
 			logger.Error(err.Error(), zap.String("requestId", requestId))
 			return api.BadRequest(c, err.Error())
 		}
-		userIdToken := c.Locals("userIdToken").(string)
-		req.OwnerIDToken = userIdToken
-		err := insertAndMergeFlashCardSetsTrackerFunc(ctx, logger, req)
+
+		res, err := searchFunc(ctx, logger, req, utils.GetUserIDToken(c))
 		if err != nil {
 			logger.Error(err.Error(), zap.String("requestId", requestId))
 			return api.InternalError(c, api.SomeThingWentWrong)
 		}
-		return api.Ok(c, nil)
+		return api.Ok(c, res)
 	}
 }
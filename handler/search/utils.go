@@ -0,0 +1,72 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	snippetRadius  = 40
+
+	// ts_headline marks matches with control characters that are stripped
+	// from the text first; they become <mark> tags once the text is escaped.
+	markStart       = "\x02"
+	markStop        = "\x03"
+	headlineOptions = `StartSel="` + markStart + `", StopSel="` + markStop + `", MaxFragments=2, MaxWords=20, MinWords=5`
+)
+
+var headlineMarks = strings.NewReplacer(markStart, highlightStart, markStop, highlightStop)
+
+// escapeHeadline HTML-escapes a ts_headline result, keeping only its
+// highlights as markup.
+func escapeHeadline(headline string) string {
+	return headlineMarks.Replace(html.EscapeString(headline))
+}
+
+// substringSnippet highlights the first case-insensitive occurrence of query
+// in doc, HTML-escaped. It covers text ts_headline cannot, such as Thai
+// without spaces.
+func substringSnippet(doc, query string) string {
+	docRunes := []rune(doc)
+	lowerDoc := []rune(strings.ToLower(doc))
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 || len(lowerDoc) != len(docRunes) {
+		return html.EscapeString(doc)
+	}
+
+	at := -1
+	for i := 0; i+len(q) <= len(lowerDoc); i++ {
+		if string(lowerDoc[i:i+len(q)]) == string(q) {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return html.EscapeString(doc)
+	}
+
+	start := at - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := at + len(q) + snippetRadius
+	if end > len(docRunes) {
+		end = len(docRunes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(html.EscapeString(string(docRunes[start:at])))
+	b.WriteString(highlightStart)
+	b.WriteString(html.EscapeString(string(docRunes[at : at+len(q)])))
+	b.WriteString(highlightStop)
+	b.WriteString(html.EscapeString(string(docRunes[at+len(q) : end])))
+	if end < len(docRunes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
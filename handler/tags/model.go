@@ -0,0 +1,124 @@
+package tags
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const maxTagLength = 50
+
+type TagCreateRequest struct {
+	Name        string `json:"name"`
+	UserIdToken string
+}
+
+func (r *TagCreateRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	return validateTagName(r.Name)
+}
+
+type TagUpdateRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	Name        string          `json:"name"`
+	UserIdToken string
+}
+
+func (r *TagUpdateRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	return validateTagName(r.Name)
+}
+
+type TagDeleteRequest struct {
+	Id          decimal.Decimal `json:"id"`
+	UserIdToken string
+}
+
+func (r TagDeleteRequest) Validate() error {
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+// SetTagsRequest replaces the caller's tags on a set. Unknown names are
+// created on the fly; an empty list removes every tag.
+type SetTagsRequest struct {
+	SetId       decimal.Decimal `json:"setId"`
+	Tags        []string        `json:"tags"`
+	UserIdToken string
+}
+
+func (r *SetTagsRequest) Validate() error {
+	if r.SetId.IsZero() {
+		return errors.New("setId is required")
+	}
+	names, err := NormalizeTagNames(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = names
+	return nil
+}
+
+type CardTagsRequest struct {
+	CardId      decimal.Decimal `json:"cardId"`
+	Tags        []string        `json:"tags"`
+	UserIdToken string
+}
+
+func (r *CardTagsRequest) Validate() error {
+	if r.CardId.IsZero() {
+		return errors.New("cardId is required")
+	}
+	names, err := NormalizeTagNames(r.Tags)
+	if err != nil {
+		return err
+	}
+	r.Tags = names
+	return nil
+}
+
+type TagDto struct {
+	Id        decimal.Decimal `json:"id"`
+	Name      string          `json:"name"`
+	SetCount  int             `json:"setCount"`
+	CardCount int             `json:"cardCount"`
+}
+
+func validateTagName(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(name)) > maxTagLength {
+		return errors.Errorf("name must be at most %d characters", maxTagLength)
+	}
+	return nil
+}
+
+// NormalizeTagNames trims names and drops case-insensitive duplicates,
+// keeping the first spelling.
+func NormalizeTagNames(in []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, name := range in {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := validateTagName(name); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, name)
+	}
+	return out, nil
+}
@@ -0,0 +1,290 @@
+package tags
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrTagAlreadyExists = "tag already exists"
+	uniqueViolation     = "23505"
+)
+
+// canEditSetSQL holds for a set s that user $2 owns or is an accepted
+// editor of.
+const canEditSetSQL = `
+		   AND (s.owner_user_token = $2
+		        OR EXISTS (SELECT 1
+		                     FROM tbl_flashcard_set_collaborators c
+		                    WHERE c.set_id = s.id
+		                      AND c.user_id_token = $2
+		                      AND c.status = 'ACCEPTED'
+		                      AND c.role = 'EDITOR'))
+`
+
+type ListTagsFunc func(ctx context.Context, logger *zap.Logger, userIdToken string) ([]TagDto, error)
+
+func NewListTags(db *pgxpool.Pool) ListTagsFunc {
+	return func(ctx context.Context, logger *zap.Logger, userIdToken string) ([]TagDto, error) {
+		const sql = `
+			SELECT t.id, t.name,
+			       (SELECT count(*) FROM tbl_flashcard_set_tags st WHERE st.tag_id = t.id) AS set_count,
+			       (SELECT count(*) FROM tbl_flashcard_tags ct WHERE ct.tag_id = t.id)     AS card_count
+			  FROM tbl_tags t
+			 WHERE t.user_id_token = $1
+			 ORDER BY lower(t.name)
+		`
+		rows, err := db.Query(ctx, sql, userIdToken)
+		if err != nil {
+			logger.Error("failed to list tags", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+
+		result := []TagDto{}
+		for rows.Next() {
+			var (
+				tag TagDto
+				id  int64
+			)
+			if err := rows.Scan(&id, &tag.Name, &tag.SetCount, &tag.CardCount); err != nil {
+				logger.Error("scan tag failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			tag.Id = decimal.NewFromInt(id)
+			result = append(result, tag)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterating tags failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return result, nil
+	}
+}
+
+type InsertTagFunc func(ctx context.Context, logger *zap.Logger, req TagCreateRequest) (int64, error)
+
+func NewInsertTag(db *pgxpool.Pool) InsertTagFunc {
+	return func(ctx context.Context, logger *zap.Logger, req TagCreateRequest) (int64, error) {
+		const sql = `
+			INSERT INTO tbl_tags (user_id_token, name)
+			VALUES ($1, $2)
+			RETURNING id
+		`
+		var id int64
+		if err := db.QueryRow(ctx, sql, req.UserIdToken, req.Name).Scan(&id); err != nil {
+			if isUniqueViolation(err) {
+				return 0, errors.New(ErrTagAlreadyExists)
+			}
+			logger.Error("failed to insert tag", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+type UpdateTagFunc func(ctx context.Context, logger *zap.Logger, req TagUpdateRequest) error
+
+func NewUpdateTag(db *pgxpool.Pool) UpdateTagFunc {
+	return func(ctx context.Context, logger *zap.Logger, req TagUpdateRequest) error {
+		const sql = `
+			UPDATE tbl_tags
+			   SET name      = $1,
+			       update_at = now()
+			 WHERE id = $2
+			   AND user_id_token = $3
+		`
+		tag, err := db.Exec(ctx, sql, req.Name, req.Id, req.UserIdToken)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return errors.New(ErrTagAlreadyExists)
+			}
+			logger.Error("failed to update tag", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+type DeleteTagFunc func(ctx context.Context, logger *zap.Logger, req TagDeleteRequest) error
+
+func NewDeleteTag(db *pgxpool.Pool) DeleteTagFunc {
+	return func(ctx context.Context, logger *zap.Logger, req TagDeleteRequest) error {
+		const sql = `
+			DELETE FROM tbl_tags
+			 WHERE id = $1
+			   AND user_id_token = $2
+		`
+		tag, err := db.Exec(ctx, sql, req.Id, req.UserIdToken)
+		if err != nil {
+			logger.Error("failed to delete tag", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if tag.RowsAffected() == 0 {
+			return errors.New(api.NotFound)
+		}
+		return nil
+	}
+}
+
+type ReplaceSetTagsFunc func(ctx context.Context, logger *zap.Logger, req SetTagsRequest) error
+
+// NewReplaceSetTags replaces the caller's own tags on a set they can edit.
+// Tags belong to the user, so an editor's tags never touch the owner's.
+func NewReplaceSetTags(db *pgxpool.Pool) ReplaceSetTagsFunc {
+	const editSQL = `
+		SELECT 1
+		  FROM tbl_flashcard_sets s
+		 WHERE s.id = $1
+		   AND s.is_deleted = 'N'` + canEditSetSQL
+	const deleteSQL = `
+		DELETE FROM tbl_flashcard_set_tags st
+		 USING tbl_tags t
+		 WHERE st.tag_id = t.id
+		   AND st.set_id = $1
+		   AND t.user_id_token = $2
+	`
+	const insertSQL = `
+		INSERT INTO tbl_flashcard_set_tags (set_id, tag_id)
+		SELECT $1, t.id
+		  FROM tbl_tags t
+		 WHERE t.user_id_token = $2
+		   AND lower(t.name) = ANY (SELECT lower(unnest($3::text[])))
+		ON CONFLICT DO NOTHING
+	`
+	return func(ctx context.Context, logger *zap.Logger, req SetTagsRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var one int
+		if err = tx.QueryRow(ctx, editSQL, req.SetId, req.UserIdToken).Scan(&one); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("check set editor failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = upsertTagNames(ctx, tx, req.UserIdToken, req.Tags); err != nil {
+			logger.Error("upsert tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, deleteSQL, req.SetId, req.UserIdToken); err != nil {
+			logger.Error("clear set tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, insertSQL, req.SetId, req.UserIdToken, req.Tags); err != nil {
+			logger.Error("insert set tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+type ReplaceCardTagsFunc func(ctx context.Context, logger *zap.Logger, req CardTagsRequest) error
+
+// NewReplaceCardTags replaces the caller's own tags on a card in a set they
+// can edit.
+func NewReplaceCardTags(db *pgxpool.Pool) ReplaceCardTagsFunc {
+	const editSQL = `
+		SELECT 1
+		  FROM tbl_flashcards f
+		  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+		 WHERE f.id = $1
+		   AND f.is_deleted = 'N'
+		   AND s.is_deleted = 'N'` + canEditSetSQL
+	const deleteSQL = `
+		DELETE FROM tbl_flashcard_tags ct
+		 USING tbl_tags t
+		 WHERE ct.tag_id = t.id
+		   AND ct.card_id = $1
+		   AND t.user_id_token = $2
+	`
+	const insertSQL = `
+		INSERT INTO tbl_flashcard_tags (card_id, tag_id)
+		SELECT $1, t.id
+		  FROM tbl_tags t
+		 WHERE t.user_id_token = $2
+		   AND lower(t.name) = ANY (SELECT lower(unnest($3::text[])))
+		ON CONFLICT DO NOTHING
+	`
+	return func(ctx context.Context, logger *zap.Logger, req CardTagsRequest) (err error) {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			logger.Error("failed to begin tx", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := tx.Rollback(ctx); rbErr != nil {
+					logger.Error("tx rollback failed", zap.Error(rbErr))
+				}
+			} else if cmErr := tx.Commit(ctx); cmErr != nil {
+				logger.Error("tx commit failed", zap.Error(cmErr))
+				err = errors.New(api.SomeThingWentWrong)
+			}
+		}()
+
+		var one int
+		if err = tx.QueryRow(ctx, editSQL, req.CardId, req.UserIdToken).Scan(&one); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("check card editor failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if err = upsertTagNames(ctx, tx, req.UserIdToken, req.Tags); err != nil {
+			logger.Error("upsert tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, deleteSQL, req.CardId, req.UserIdToken); err != nil {
+			logger.Error("clear card tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if _, err = tx.Exec(ctx, insertSQL, req.CardId, req.UserIdToken, req.Tags); err != nil {
+			logger.Error("insert card tags failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
+
+func upsertTagNames(ctx context.Context, tx pgx.Tx, userIdToken string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	const sql = `
+		INSERT INTO tbl_tags (user_id_token, name)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT (user_id_token, lower(name)) DO NOTHING
+	`
+	_, err := tx.Exec(ctx, sql, userIdToken, names)
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
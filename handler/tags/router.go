@@ -0,0 +1,31 @@
+package tags
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func GetRouter(
+	group fiber.Router,
+	dbPool *pgxpool.Pool,
+) {
+	tagsGroup := group.Group("/tags")
+	tagsGroup.Get("", NewListTagsHandler(
+		NewListTags(dbPool),
+	))
+	tagsGroup.Post("/create", NewCreateTagHandler(
+		NewInsertTag(dbPool),
+	))
+	tagsGroup.Put("/update", NewUpdateTagHandler(
+		NewUpdateTag(dbPool),
+	))
+	tagsGroup.Post("/delete", NewDeleteTagHandler(
+		NewDeleteTag(dbPool),
+	))
+	tagsGroup.Put("/sets", NewSetTagsHandler(
+		NewReplaceSetTags(dbPool),
+	))
+	tagsGroup.Put("/cards", NewCardTagsHandler(
+		NewReplaceCardTags(dbPool),
+	))
+}
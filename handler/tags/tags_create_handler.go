@@ -0,0 +1,42 @@
+package tags
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewCreateTagHandler(
+	insertTagFunc InsertTagFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req TagCreateRequest
+
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			logger.Error("validation error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, err.Error())
+		}
+		req.UserIdToken = utils.GetUserIDToken(c)
+
+		id, err := insertTagFunc(ctx, logger, req)
+		if err != nil {
+			logger.Error("create tag failed", zap.String("requestId", requestId), zap.Error(err))
+			if err.Error() == ErrTagAlreadyExists {
+				return api.BadRequest(c, err.Error())
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, TagDto{Id: decimal.NewFromInt(id), Name: req.Name})
+	}
+}
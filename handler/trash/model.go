@@ -0,0 +1,56 @@
+package trash
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ItemTypeSet  = "set"
+	ItemTypeCard = "card"
+)
+
+type TrashListResponse struct {
+	RetentionDays int            `json:"retentionDays"`
+	Sets          []TrashSetDto  `json:"sets"`
+	Cards         []TrashCardDto `json:"cards"`
+}
+
+type TrashSetDto struct {
+	SetId     decimal.Decimal `json:"setId"`
+	Title     string          `json:"title"`
+	Term      int             `json:"term"`
+	DeletedAt time.Time       `json:"deletedAt"`
+	PurgeAt   time.Time       `json:"purgeAt"`
+}
+
+// TrashCardDto is a card deleted on its own; cards of a deleted set come back
+// with the set and are not listed here.
+type TrashCardDto struct {
+	CardId    decimal.Decimal `json:"cardId"`
+	SetId     decimal.Decimal `json:"setId"`
+	SetTitle  string          `json:"setTitle"`
+	Front     string          `json:"front"`
+	Back      string          `json:"back"`
+	DeletedAt time.Time       `json:"deletedAt"`
+	PurgeAt   time.Time       `json:"purgeAt"`
+}
+
+type TrashRestoreRequest struct {
+	Type        string          `json:"type"`
+	Id          decimal.Decimal `json:"id"`
+	UserIdToken string
+	UserId      string
+}
+
+func (r TrashRestoreRequest) Validate() error {
+	if r.Type != ItemTypeSet && r.Type != ItemTypeCard {
+		return errors.New("type must be 'set' or 'card'")
+	}
+	if r.Id.IsZero() {
+		return errors.New("id is required")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package trash
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"go.uber.org/zap"
+)
+
+const ErrSetInTrash = "restore the set before restoring its cards"
+
+type ListTrashFunc func(ctx context.Context, logger *zap.Logger, userIdToken string) (TrashListResponse, error)
+
+func NewListTrash(db *pgxpool.Pool, retentionDays int) ListTrashFunc {
+	const setsSQL = `
+		SELECT s.id, coalesce(s.title, ''),
+		       (SELECT count(*)
+		          FROM tbl_flashcards f
+		         WHERE f.set_id = s.id
+		           AND f.is_deleted = 'N') AS term,
+		       s.deleted_at
+		  FROM tbl_flashcard_sets s
+		 WHERE s.owner_user_token = $1
+		   AND s.is_deleted = 'Y'
+		   AND s.deleted_at IS NOT NULL
+		 ORDER BY s.deleted_at DESC
+	`
+	const cardsSQL = `
+		SELECT f.id, f.set_id, coalesce(s.title, ''), f.front, f.back, f.deleted_at
+		  FROM tbl_flashcards f
+		  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+		 WHERE s.owner_user_token = $1
+		   AND s.is_deleted = 'N'
+		   AND f.is_deleted = 'Y'
+		   AND f.deleted_at IS NOT NULL
+		 ORDER BY f.deleted_at DESC
+	`
+	retention := time.Duration(retentionDays) * 24 * time.Hour
+
+	return func(ctx context.Context, logger *zap.Logger, userIdToken string) (TrashListResponse, error) {
+		resp := TrashListResponse{
+			RetentionDays: retentionDays,
+			Sets:          []TrashSetDto{},
+			Cards:         []TrashCardDto{},
+		}
+
+		rows, err := db.Query(ctx, setsSQL, userIdToken)
+		if err != nil {
+			logger.Error("list trashed sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		for rows.Next() {
+			var (
+				d  TrashSetDto
+				id int64
+			)
+			if err := rows.Scan(&id, &d.Title, &d.Term, &d.DeletedAt); err != nil {
+				rows.Close()
+				logger.Error("scan trashed set failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			d.SetId = decimal.NewFromInt(id)
+			d.PurgeAt = d.DeletedAt.Add(retention)
+			resp.Sets = append(resp.Sets, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate trashed sets failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+
+		rows, err = db.Query(ctx, cardsSQL, userIdToken)
+		if err != nil {
+			logger.Error("list trashed cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				d         TrashCardDto
+				id, setID int64
+			)
+			if err := rows.Scan(&id, &setID, &d.SetTitle, &d.Front, &d.Back, &d.DeletedAt); err != nil {
+				logger.Error("scan trashed card failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			d.CardId = decimal.NewFromInt(id)
+			d.SetId = decimal.NewFromInt(setID)
+			d.PurgeAt = d.DeletedAt.Add(retention)
+			resp.Cards = append(resp.Cards, d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate trashed cards failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
+
+type RestoreTrashFunc func(ctx context.Context, logger *zap.Logger, req TrashRestoreRequest) error
+
+// NewRestoreTrash brings a set or a card owned by the caller back from the
+// trash. A card can only be restored while its set is live.
+func NewRestoreTrash(db *pgxpool.Pool) RestoreTrashFunc {
+	const restoreSetSQL = `
+		UPDATE tbl_flashcard_sets
+		   SET is_deleted = 'N',
+		       deleted_at = NULL,
+		       update_by  = $3,
+		       update_at  = now()
+		 WHERE id = $1
+		   AND owner_user_token = $2
+		   AND is_deleted = 'Y'
+	`
+	const cardSetSQL = `
+		SELECT s.is_deleted
+		  FROM tbl_flashcards f
+		  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+		 WHERE f.id = $1
+		   AND s.owner_user_token = $2
+		   AND f.is_deleted = 'Y'
+	`
+	const restoreCardSQL = `
+		UPDATE tbl_flashcards
+		   SET is_deleted = 'N',
+		       deleted_at = NULL,
+		       update_by  = $2,
+		       update_at  = now()
+		 WHERE id = $1
+		   AND is_deleted = 'Y'
+	`
+	return func(ctx context.Context, logger *zap.Logger, req TrashRestoreRequest) error {
+		if req.Type == ItemTypeSet {
+			tag, err := db.Exec(ctx, restoreSetSQL, req.Id, req.UserIdToken, req.UserId)
+			if err != nil {
+				logger.Error("restore set failed", zap.Error(err))
+				return errors.New(api.SomeThingWentWrong)
+			}
+			if tag.RowsAffected() == 0 {
+				return errors.New(api.NotFound)
+			}
+			return nil
+		}
+
+		var setDeleted string
+		if err := db.QueryRow(ctx, cardSetSQL, req.Id, req.UserIdToken).Scan(&setDeleted); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errors.New(api.NotFound)
+			}
+			logger.Error("load trashed card failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		if setDeleted == "Y" {
+			return errors.New(ErrSetInTrash)
+		}
+		if _, err := db.Exec(ctx, restoreCardSQL, req.Id, req.UserId); err != nil {
+			logger.Error("restore card failed", zap.Error(err))
+			return errors.New(api.SomeThingWentWrong)
+		}
+		return nil
+	}
+}
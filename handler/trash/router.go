@@ -0,0 +1,21 @@
+package trash
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+)
+
+func GetRouter(
+	group fiber.Router,
+	config config.Config,
+	dbPool *pgxpool.Pool,
+) {
+	trashGroup := group.Group("/trash")
+	trashGroup.Get("", NewListTrashHandler(
+		NewListTrash(dbPool, config.TrashConfig.RetentionDays),
+	))
+	trashGroup.Post("/restore", NewRestoreTrashHandler(
+		NewRestoreTrash(dbPool),
+	))
+}
@@ -1,21 +1,72 @@
 package voice
 
-type VoiceRequest struct {
-	Text string `json:"text,required"`
-}
+import (
+	"strings"
+	"time"
 
-type TtsRequestToHomeProxy struct {
-	Prompt string `json:"prompt"`
-}
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/langcode"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+)
+
+const (
+	SideFront = "front"
+	SideBack  = "back"
 
-type TtsResponseFromHomeProxy struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-	Body    TtsResponseBodyFromHomeProxy
+	minSpeed    = 0.5
+	maxSpeed    = 2.0
+	maxVoiceLen = 50
+)
+
+var ttsFormats = map[string]bool{"mp3": true, "wav": true, "ogg": true}
+
+// VoiceRequest speaks text. Without a locale, the language of the given side
+// of the card's set is used, else the user's locale.
+type VoiceRequest struct {
+	Text   string           `json:"text,required"`
+	CardId *decimal.Decimal `json:"cardId"`
+	Side   string           `json:"side"`   // front (default) or back
+	Voice  string           `json:"voice"`  // DEFAULT when empty
+	Speed  float64          `json:"speed"`  // 1.0 when 0, e.g. 0.7 for a slow clip
+	Format string           `json:"format"` // mp3 (default), wav or ogg
+	Locale string           `json:"locale"`
 }
-type TtsResponseBodyFromHomeProxy struct {
-	Key string `json:"key"`
-	Url string `json:"url"`
+
+// Validate fills in the defaults and checks the TTS options.
+func (r *VoiceRequest) Validate() error {
+	if r.Side == "" {
+		r.Side = SideFront
+	}
+	if r.Side != SideFront && r.Side != SideBack {
+		return errors.New("side must be front or back")
+	}
+	r.Voice = strings.TrimSpace(r.Voice)
+	if r.Voice == "" {
+		r.Voice = tts.DefaultVoice
+	}
+	if len(r.Voice) > maxVoiceLen {
+		return errors.Errorf("voice must be at most %d characters", maxVoiceLen)
+	}
+	if r.Speed == 0 {
+		r.Speed = tts.SpeedNormal
+	}
+	if r.Speed < minSpeed || r.Speed > maxSpeed {
+		return errors.Errorf("speed must be between %.1f and %.1f", minSpeed, maxSpeed)
+	}
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format == "" {
+		r.Format = tts.DefaultFormat
+	}
+	if !ttsFormats[r.Format] {
+		return errors.New("format must be mp3, wav or ogg")
+	}
+	locale, ok := langcode.Normalize(r.Locale)
+	if !ok {
+		return errors.New("locale must be a language tag like th-TH or en-US")
+	}
+	r.Locale = locale
+	return nil
 }
 
 type SttResponse struct {
@@ -26,3 +77,99 @@ type SttResponse struct {
 type SttResponseDetails struct {
 	Text string `json:"text"`
 }
+
+const (
+	defaultTrendDays       = 30
+	maxTrendDays           = 365
+	defaultMispronounced   = 10
+	maxMispronounced       = 100
+	defaultHistorySize     = 20
+	maxHistorySize         = 100
+	pronunciationAudioPath = "pronunciation"
+)
+
+// PronunciationAttempt is one scored recording, as stored.
+type PronunciationAttempt struct {
+	UserIdToken    string
+	CardId         *decimal.Decimal
+	ExpectedText   string
+	RecognizedText string
+	Locale         string
+	Report         WerReport
+	AudioKey       string
+}
+
+type PronunciationHistoryRequest struct {
+	Page   decimal.Decimal  `json:"page"`
+	Size   decimal.Decimal  `json:"size"`
+	CardId *decimal.Decimal `json:"cardId"`
+}
+
+// Validate fills in the first page of defaultHistorySize attempts and caps
+// the page size.
+func (r *PronunciationHistoryRequest) Validate() error {
+	if r.Page.IsNegative() {
+		return errors.New("page must not be negative")
+	}
+	if r.Size.IsNegative() {
+		return errors.New("size must not be negative")
+	}
+	if r.Page.IsZero() {
+		r.Page = decimal.NewFromInt(1)
+	}
+	if r.Size.IsZero() {
+		r.Size = decimal.NewFromInt(defaultHistorySize)
+	}
+	if r.Size.GreaterThan(decimal.NewFromInt(maxHistorySize)) {
+		r.Size = decimal.NewFromInt(maxHistorySize)
+	}
+	return nil
+}
+
+type PronunciationAttemptDto struct {
+	Id             decimal.Decimal  `json:"id"`
+	CardId         *decimal.Decimal `json:"cardId"`
+	ExpectedText   string           `json:"expectedText"`
+	RecognizedText string           `json:"recognizedText"`
+	Locale         string           `json:"locale"`
+	WER            float64          `json:"wer"`
+	Score          int              `json:"score"`
+	Mismatches     []Mismatch       `json:"mismatches"`
+	AudioUrl       *string          `json:"audioUrl"`
+	CreatedAt      time.Time        `json:"createdAt"`
+}
+
+type PronunciationHistoryResponse struct {
+	Content       []PronunciationAttemptDto `json:"content"`
+	TotalPage     decimal.Decimal           `json:"totalPage"`
+	TotalElements decimal.Decimal           `json:"totalElements"`
+}
+
+// PronunciationTrendDay sums up the attempts of one day.
+type PronunciationTrendDay struct {
+	Date     string  `json:"date"` // YYYY-MM-DD
+	Attempts int     `json:"attempts"`
+	Best     int     `json:"best"`
+	Average  float64 `json:"average"`
+}
+
+// PronunciationTrendResponse covers the last Days days, of one card or of
+// every attempt.
+type PronunciationTrendResponse struct {
+	Days     int                     `json:"days"`
+	Attempts int                     `json:"attempts"`
+	Best     int                     `json:"best"`
+	Average  float64                 `json:"average"`
+	Latest   *int                    `json:"latest"`
+	Trend    []PronunciationTrendDay `json:"trend"`
+}
+
+// MispronouncedWord is a source word the recognizer missed or heard as
+// something else, with what it was heard as.
+type MispronouncedWord struct {
+	Word         string    `json:"word"`
+	Misses       int       `json:"misses"`
+	Attempts     int       `json:"attempts"`
+	HeardAs      []string  `json:"heardAs"`
+	LastMissedAt time.Time `json:"lastMissedAt"`
+}
@@ -0,0 +1,95 @@
+package voice
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+func NewPronunciationHistoryHandler(
+	listPronunciationAttemptsFunc ListPronunciationAttemptsFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req PronunciationHistoryRequest
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		if err := c.BodyParser(&req); err != nil {
+			logger.Error("body parse error", zap.String("requestId", requestId), zap.Error(err))
+			return api.BadRequest(c, api.InvalidateBody)
+		}
+		if err := req.Validate(); err != nil {
+			return api.BadRequest(c, err.Error())
+		}
+
+		resp, err := listPronunciationAttemptsFunc(ctx, logger, req, utils.GetUserIDToken(c))
+		if err != nil {
+			logger.Error("list pronunciation attempts failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
+
+// NewPronunciationTrendHandler returns the caller's best and average scores
+// per day, of one card with ?cardId= or of every attempt.
+func NewPronunciationTrendHandler(
+	pronunciationTrendFunc PronunciationTrendFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		cardId := c.QueryInt("cardId", 0)
+		days := c.QueryInt("days", defaultTrendDays)
+		if cardId < 0 {
+			return api.BadRequest(c, "invalid cardId")
+		}
+		if days < 1 || days > maxTrendDays {
+			return api.BadRequest(c, "days must be between 1 and 365")
+		}
+
+		resp, err := pronunciationTrendFunc(ctx, logger, utils.GetUserIDToken(c), int64(cardId), days)
+		if err != nil {
+			logger.Error("pronunciation trend failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
+
+// NewMostMispronouncedHandler returns the words the caller most often
+// mispronounces; ?days= limits it to recent attempts.
+func NewMostMispronouncedHandler(
+	mostMispronouncedFunc MostMispronouncedFunc,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := c.Context()
+		logger := logz.NewLogger()
+		requestId := c.Get("requestId")
+
+		cardId := c.QueryInt("cardId", 0)
+		days := c.QueryInt("days", 0)
+		limit := c.QueryInt("limit", defaultMispronounced)
+		if cardId < 0 {
+			return api.BadRequest(c, "invalid cardId")
+		}
+		if days < 0 {
+			return api.BadRequest(c, "days must be >= 0")
+		}
+		if limit < 1 || limit > maxMispronounced {
+			return api.BadRequest(c, "limit must be between 1 and 100")
+		}
+
+		resp, err := mostMispronouncedFunc(ctx, logger, utils.GetUserIDToken(c), int64(cardId), days, limit)
+		if err != nil {
+			logger.Error("most mispronounced words failed", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+		return api.Ok(c, resp)
+	}
+}
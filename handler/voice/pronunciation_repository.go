@@ -0,0 +1,252 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"go.uber.org/zap"
+)
+
+type InsertPronunciationAttemptFunc func(ctx context.Context, logger *zap.Logger, attempt PronunciationAttempt) (int64, error)
+
+// NewInsertPronunciationAttempt stores a scored attempt. A card id that no
+// longer exists is stored as NULL, as it would be once the card is purged.
+func NewInsertPronunciationAttempt(db *pgxpool.Pool) InsertPronunciationAttemptFunc {
+	const sql = `
+		INSERT INTO tbl_pronunciation_attempt
+		    (user_id_token, card_id, expected_text, recognized_text, wer, score_overall, score_detail,
+		     locale, audio_key)
+		VALUES ($1, (SELECT id FROM tbl_flashcards WHERE id = $2::bigint), $3, $4, $5, $6, $7,
+		        $8, NULLIF($9, ''))
+		RETURNING id
+	`
+	return func(ctx context.Context, logger *zap.Logger, attempt PronunciationAttempt) (int64, error) {
+		var cardId *int64
+		if attempt.CardId != nil {
+			v := attempt.CardId.IntPart()
+			cardId = &v
+		}
+		// mismatches are always an array, so they can be expanded in SQL
+		if attempt.Report.Mismatches == nil {
+			attempt.Report.Mismatches = []Mismatch{}
+		}
+		detail, err := json.Marshal(attempt.Report)
+		if err != nil {
+			logger.Error("marshal wer report failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		var id int64
+		err = db.QueryRow(ctx, sql,
+			attempt.UserIdToken, cardId, attempt.ExpectedText, attempt.RecognizedText,
+			math.Round(attempt.Report.WER*10000)/10000, attempt.Report.Score, detail,
+			attempt.Locale, attempt.AudioKey,
+		).Scan(&id)
+		if err != nil {
+			logger.Error("insert pronunciation attempt failed", zap.Error(err))
+			return 0, errors.New(api.SomeThingWentWrong)
+		}
+		return id, nil
+	}
+}
+
+type ListPronunciationAttemptsFunc func(ctx context.Context, logger *zap.Logger, req PronunciationHistoryRequest, userIdToken string) (PronunciationHistoryResponse, error)
+
+// NewListPronunciationAttempts pages through the caller's attempts, newest
+// first, optionally of one card. Kept recordings are linked through the blob
+// store on every read, as its URLs may expire.
+func NewListPronunciationAttempts(db *pgxpool.Pool, store blobstore.Store) ListPronunciationAttemptsFunc {
+	const whereSQL = `
+     WHERE user_id_token = $1
+       AND ($2::bigint IS NULL OR card_id = $2)
+    `
+	const countSQL = `
+    SELECT count(*)
+      FROM tbl_pronunciation_attempt
+    ` + whereSQL
+	const listSQL = `
+    SELECT id, card_id, expected_text, recognized_text, coalesce(locale, ''),
+           coalesce(wer, 0)::float8, coalesce(score_overall, 0),
+           coalesce(score_detail -> 'mismatches', '[]'::jsonb), audio_key, audio_url, created_at
+      FROM tbl_pronunciation_attempt
+    ` + whereSQL + `
+     ORDER BY created_at DESC, id DESC
+    OFFSET $3 LIMIT $4
+    `
+	return func(ctx context.Context, logger *zap.Logger, req PronunciationHistoryRequest, userIdToken string) (PronunciationHistoryResponse, error) {
+		resp := PronunciationHistoryResponse{Content: []PronunciationAttemptDto{}}
+		size := int(req.Size.IntPart())
+		offset := (int(req.Page.IntPart()) - 1) * size
+
+		var cardId *int64
+		if req.CardId != nil {
+			v := req.CardId.IntPart()
+			cardId = &v
+		}
+
+		if err := db.QueryRow(ctx, countSQL, userIdToken, cardId).Scan(&resp.TotalElements); err != nil {
+			logger.Error("count pronunciation attempts failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		totalPage, _ := resp.TotalElements.Div(req.Size).Float64()
+		resp.TotalPage = decimal.NewFromFloat(math.Ceil(totalPage))
+
+		rows, err := db.Query(ctx, listSQL, userIdToken, cardId, offset, size)
+		if err != nil {
+			logger.Error("list pronunciation attempts failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				a          PronunciationAttemptDto
+				id         int64
+				card       *int64
+				mismatches []byte
+				audioKey   *string
+			)
+			if err := rows.Scan(&id, &card, &a.ExpectedText, &a.RecognizedText, &a.Locale,
+				&a.WER, &a.Score, &mismatches, &audioKey, &a.AudioUrl, &a.CreatedAt); err != nil {
+				logger.Error("scan pronunciation attempt failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			a.Id = decimal.NewFromInt(id)
+			if audioKey != nil && *audioKey != "" {
+				url := store.URL(*audioKey)
+				a.AudioUrl = &url
+			}
+			if card != nil {
+				v := decimal.NewFromInt(*card)
+				a.CardId = &v
+			}
+			if err := json.Unmarshal(mismatches, &a.Mismatches); err != nil || a.Mismatches == nil {
+				a.Mismatches = []Mismatch{}
+			}
+			resp.Content = append(resp.Content, a)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate pronunciation attempts failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
+
+type PronunciationTrendFunc func(ctx context.Context, logger *zap.Logger, userIdToken string, cardId int64, days int) (PronunciationTrendResponse, error)
+
+// NewPronunciationTrend sums the caller's scores per day over the last days
+// days; cardId 0 covers every attempt.
+func NewPronunciationTrend(db *pgxpool.Pool) PronunciationTrendFunc {
+	const sql = `
+		SELECT to_char(created_at::date, 'YYYY-MM-DD'),
+		       count(*),
+		       coalesce(max(score_overall), 0),
+		       coalesce(avg(score_overall), 0)::float8
+		  FROM tbl_pronunciation_attempt
+		 WHERE user_id_token = $1
+		   AND ($2::bigint = 0 OR card_id = $2)
+		   AND created_at >= current_date - ($3::int - 1)
+		 GROUP BY created_at::date
+		 ORDER BY created_at::date
+	`
+	const latestSQL = `
+		SELECT coalesce(score_overall, 0)
+		  FROM tbl_pronunciation_attempt
+		 WHERE user_id_token = $1
+		   AND ($2::bigint = 0 OR card_id = $2)
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT 1
+	`
+	return func(ctx context.Context, logger *zap.Logger, userIdToken string, cardId int64, days int) (PronunciationTrendResponse, error) {
+		resp := PronunciationTrendResponse{Days: days, Trend: []PronunciationTrendDay{}}
+		rows, err := db.Query(ctx, sql, userIdToken, cardId, days)
+		if err != nil {
+			logger.Error("select pronunciation trend failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		var total float64
+		for rows.Next() {
+			var d PronunciationTrendDay
+			if err := rows.Scan(&d.Date, &d.Attempts, &d.Best, &d.Average); err != nil {
+				logger.Error("scan pronunciation trend failed", zap.Error(err))
+				return resp, errors.New(api.SomeThingWentWrong)
+			}
+			total += d.Average * float64(d.Attempts)
+			resp.Attempts += d.Attempts
+			resp.Best = max(resp.Best, d.Best)
+			d.Average = math.Round(d.Average*100) / 100
+			resp.Trend = append(resp.Trend, d)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate pronunciation trend failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		if resp.Attempts > 0 {
+			resp.Average = math.Round(total*100/float64(resp.Attempts)) / 100
+		}
+
+		var latest int
+		err = db.QueryRow(ctx, latestSQL, userIdToken, cardId).Scan(&latest)
+		if err == nil {
+			resp.Latest = &latest
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			logger.Error("select latest pronunciation score failed", zap.Error(err))
+			return resp, errors.New(api.SomeThingWentWrong)
+		}
+		return resp, nil
+	}
+}
+
+type MostMispronouncedFunc func(ctx context.Context, logger *zap.Logger, userIdToken string, cardId int64, days, limit int) ([]MispronouncedWord, error)
+
+// NewMostMispronounced counts the source words of the caller's attempts that
+// were substituted or dropped, from the mismatches of each WER report, most
+// missed first. cardId 0 covers every attempt and days 0 all time.
+func NewMostMispronounced(db *pgxpool.Pool) MostMispronouncedFunc {
+	const sql = `
+		SELECT m ->> 'sourceWord' AS word,
+		       count(*),
+		       count(DISTINCT a.id),
+		       coalesce(array_agg(DISTINCT m ->> 'sttWord') FILTER (WHERE m ->> 'type' = 'sub'), '{}'),
+		       max(a.created_at)
+		  FROM tbl_pronunciation_attempt a
+		 CROSS JOIN LATERAL jsonb_array_elements(coalesce(a.score_detail -> 'mismatches', '[]'::jsonb)) AS m
+		 WHERE a.user_id_token = $1
+		   AND ($2::bigint = 0 OR a.card_id = $2)
+		   AND ($3::int = 0 OR a.created_at >= now() - make_interval(days => $3))
+		   AND m ->> 'type' IN ('sub', 'del')
+		   AND coalesce(m ->> 'sourceWord', '') <> ''
+		 GROUP BY word
+		 ORDER BY count(*) DESC, max(a.created_at) DESC
+		 LIMIT $4
+	`
+	return func(ctx context.Context, logger *zap.Logger, userIdToken string, cardId int64, days, limit int) ([]MispronouncedWord, error) {
+		result := []MispronouncedWord{}
+		rows, err := db.Query(ctx, sql, userIdToken, cardId, days, limit)
+		if err != nil {
+			logger.Error("select mispronounced words failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var w MispronouncedWord
+			if err := rows.Scan(&w.Word, &w.Misses, &w.Attempts, &w.HeardAs, &w.LastMissedAt); err != nil {
+				logger.Error("scan mispronounced word failed", zap.Error(err))
+				return nil, errors.New(api.SomeThingWentWrong)
+			}
+			result = append(result, w)
+		}
+		if err := rows.Err(); err != nil {
+			logger.Error("iterate mispronounced words failed", zap.Error(err))
+			return nil, errors.New(api.SomeThingWentWrong)
+		}
+		return result, nil
+	}
+}
@@ -3,20 +3,31 @@ package voice
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mime"
 	"path/filepath"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/adapter"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	cardmedia "gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
 	"go.uber.org/zap"
 )
 
 func NewPronunciationScoreHandler(
 	homeProxyAdapter adapter.Adapter,
+	store blobstore.Store,
+	maxAudioBytes int64,
+	canSeeCardFunc CanSeeCardFunc,
+	resolveLocaleFunc ResolveLocaleFunc,
+	insertPronunciationAttemptFunc InsertPronunciationAttemptFunc,
 ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx := c.Context()
@@ -43,22 +54,70 @@ func NewPronunciationScoreHandler(
 		if !isAllowedMedia(mediaType) {
 			return api.BadRequest(c, "unsupported mediaType")
 		}
+		// the text read aloud is usually a card side; score it in that language
+		var cardId *decimal.Decimal
+		if v := c.FormValue("cardId"); v != "" {
+			id, err := decimal.NewFromString(v)
+			if err != nil {
+				return api.BadRequest(c, "invalid cardId")
+			}
+			cardId = &id
+		}
+		side := c.FormValue("side")
+		if side == "" {
+			side = SideFront
+		}
+		if side != SideFront && side != SideBack {
+			return api.BadRequest(c, "side must be front or back")
+		}
+		userIdToken := utils.GetUserIDToken(c)
+		// a card of someone else's private set is neither scored against nor
+		// linked to the attempt
+		if cardId != nil {
+			ok, err := canSeeCardFunc(ctx, logger, *cardId, userIdToken)
+			if err != nil {
+				return api.InternalError(c, api.SomeThingWentWrong)
+			}
+			if !ok {
+				return api.NotFoundError(c, "card not found")
+			}
+		}
+		locale, err := resolveLocaleFunc(ctx, logger, cardId, side, userIdToken)
+		if err != nil {
+			return api.InternalError(c, err.Error())
+		}
 
 		src, err := fh.Open()
 		if err != nil {
 			return api.InternalError(c, err.Error())
 		}
 		defer src.Close()
-		audioBytes, err := io.ReadAll(src)
+		audioBytes, err := io.ReadAll(io.LimitReader(src, maxAudioBytes+1))
 		if err != nil {
 			return api.InternalError(c, err.Error())
 		}
+		if int64(len(audioBytes)) > maxAudioBytes {
+			return api.BadRequest(c, fmt.Sprintf("audio files must be at most %d bytes", maxAudioBytes))
+		}
+		// a kept recording is served back from the blob store, so its type and
+		// extension come from its content, never from what the client claims
+		keepAudio := c.FormValue("keepAudio") == utils.FlagY
+		var storedType string
+		var stored cardmedia.Type
+		if keepAudio {
+			var ok bool
+			storedType, stored, ok = cardmedia.DetectType(audioBytes, fh.Filename)
+			if !ok || stored.Kind != cardmedia.KindAudio {
+				return api.BadRequest(c, fmt.Sprintf("unsupported audio type %s", storedType))
+			}
+		}
 
 		_, body, err := homeProxyAdapter.Post(ctx, api.SttPath, &adapter.RequestOptions{
 			Headers: map[string]string{"requestId": requestId},
 			Form: &adapter.FormData{
 				Fields: map[string]string{
 					"mediaType": mediaType,
+					"locale":    locale,
 				},
 				Files: []adapter.FormFile{
 					{
@@ -70,6 +129,10 @@ func NewPronunciationScoreHandler(
 				},
 			},
 		})
+		if err != nil {
+			logger.Error("failed to post to home proxy", zap.String("requestId", requestId), zap.Error(err))
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
 		err = json.Unmarshal(body, &resp)
 		if err != nil {
 			logger.Error(err.Error())
@@ -77,11 +140,46 @@ func NewPronunciationScoreHandler(
 			return err
 		}
 		logger.Info("afterCallAPI", zap.Any("resp", resp))
-		// TODO insert tbl_pronunciation_attempt
 
 		sttText := resp.Body.Text
-		report := ScoreByWER(sourceText, sttText)
+		report := ScoreByWER(sourceText, sttText, locale)
+
+		attempt := PronunciationAttempt{
+			UserIdToken:    userIdToken,
+			CardId:         cardId,
+			ExpectedText:   sourceText,
+			RecognizedText: sttText,
+			Locale:         locale,
+			Report:         report,
+		}
+		// the recording is only kept when asked for, to be played back from
+		// the history
+		if keepAudio {
+			key := fmt.Sprintf("%s/%s/%s%s", pronunciationAudioPath, userIdToken, uuid.NewString(), stored.Ext)
+			if err := store.Put(ctx, key, audioBytes, storedType); err != nil {
+				logger.Error("store pronunciation audio failed", zap.String("requestId", requestId), zap.Error(err))
+				return api.InternalError(c, api.SomeThingWentWrong)
+			}
+			attempt.AudioKey = key
+		}
+		attemptId, err := insertPronunciationAttemptFunc(ctx, logger, attempt)
+		if err != nil {
+			if attempt.AudioKey != "" {
+				if delErr := store.Delete(ctx, attempt.AudioKey); delErr != nil {
+					logger.Error("remove orphan pronunciation audio failed", zap.String("requestId", requestId), zap.Error(delErr))
+				}
+			}
+			return api.InternalError(c, api.SomeThingWentWrong)
+		}
+
+		var audioUrl string
+		if attempt.AudioKey != "" {
+			audioUrl = store.URL(attempt.AudioKey)
+		}
 		return api.Ok(c, fiber.Map{
+			"attemptId":  attemptId,
+			"audioUrl":   audioUrl,
+			"locale":     locale,
 			"sourceText": sourceText,
 			"sttText":    sttText,
 			"score":      report.Score,
@@ -5,52 +5,68 @@ import (
 	"errors"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/langcode"
 	"go.uber.org/zap"
 )
 
-type UpdateHitCacheAndReturnAudio func(ctx context.Context, logger *zap.Logger, cacheKey string) (string, error)
+type CanSeeCardFunc func(ctx context.Context, logger *zap.Logger, cardId decimal.Decimal, userIdToken string) (bool, error)
 
-func NewUpdateHitCacheAndReturnAudio(db *pgxpool.Pool) UpdateHitCacheAndReturnAudio {
-	return func(ctx context.Context, logger *zap.Logger, cacheKey string) (string, error) {
-		var audioUrl string
-		const updateCacheAndGetAudioUrl = `
-			update tbl_tts_cache
-			set hit_count=hit_count+1 ,
-				last_accessed_at = now()
-			where cache_key = $1
-			returning audio_url
-		`
-
-		rows, err := db.Query(ctx, updateCacheAndGetAudioUrl, cacheKey)
-		if err != nil {
+// NewCanSeeCard reports whether the card is in a set the user owns, is an
+// accepted collaborator of, or that is public.
+func NewCanSeeCard(db *pgxpool.Pool) CanSeeCardFunc {
+	const sql = `
+		select exists (
+		    select 1
+		      from tbl_flashcards f
+		      join tbl_flashcard_sets s on s.id = f.set_id
+		     where f.id = $1::bigint
+		       and f.is_deleted = 'N'
+		       and s.is_deleted = 'N'
+		       and (s.owner_user_token = $2
+		            or s.is_public = 'Y'
+		            or exists (select 1
+		                         from tbl_flashcard_set_collaborators c
+		                        where c.set_id = s.id
+		                          and c.user_id_token = $2
+		                          and c.status = 'ACCEPTED')))
+	`
+	return func(ctx context.Context, logger *zap.Logger, cardId decimal.Decimal, userIdToken string) (bool, error) {
+		var ok bool
+		if err := db.QueryRow(ctx, sql, cardId.IntPart(), userIdToken).Scan(&ok); err != nil {
 			logger.Error(err.Error())
-			return audioUrl, errors.New("failed to check daily plans")
-		}
-
-		for rows.Next() {
-			err := rows.Scan(&audioUrl)
-			if err != nil {
-				return audioUrl, err
-			}
+			return false, errors.New("failed to check card access")
 		}
-
-		return audioUrl, nil
+		return ok, nil
 	}
 }
 
-type InsertAudioUrlAndKeyToCacheFunc func(ctx context.Context, logger *zap.Logger, cacheKey, text, audioUrl, key string) error
+type ResolveLocaleFunc func(ctx context.Context, logger *zap.Logger, cardId *decimal.Decimal, side, userIdToken string) (string, error)
 
-func NewInsertAudioUrlAndKeyToCacheFunc(db *pgxpool.Pool) InsertAudioUrlAndKeyToCacheFunc {
-	return func(ctx context.Context, logger *zap.Logger, cacheKey, text, audioUrl, key string) error {
-		sql := `
-			insert into tbl_tts_cache (cache_key, text, voice, speed,audio_url, last_accessed_at, audio_key)
-			values ($1,$2,'DEFAULT',1.0,$3,now(),$4);
-		`
-		_, err := db.Exec(ctx, sql, cacheKey, text, audioUrl, key)
-		if err != nil {
-			return err
+// NewResolveLocale picks the language to speak or score in: the language of
+// the card's set for the given side, else the user's locale, else
+// langcode.Default.
+func NewResolveLocale(db *pgxpool.Pool) ResolveLocaleFunc {
+	const sql = `
+		select coalesce(
+		           (select case when $2 = 'back' then s.back_lang else s.front_lang end
+		              from tbl_flashcards f
+		              join tbl_flashcard_sets s on s.id = f.set_id
+		             where f.id = $1::bigint),
+		           (select locale from tbl_user_config where user_id_token = $3),
+		           '')
+	`
+	return func(ctx context.Context, logger *zap.Logger, cardId *decimal.Decimal, side, userIdToken string) (string, error) {
+		var id *int64
+		if cardId != nil {
+			v := cardId.IntPart()
+			id = &v
 		}
-
-		return nil
+		var locale string
+		if err := db.QueryRow(ctx, sql, id, side, userIdToken).Scan(&locale); err != nil {
+			logger.Error(err.Error())
+			return "", errors.New("failed to resolve locale")
+		}
+		return langcode.Resolve(locale), nil
 	}
 }
@@ -4,19 +4,40 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/adapter"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/middleware"
 )
 
 func GetRouter(
 	group fiber.Router,
+	config config.Config,
 	dbPool *pgxpool.Pool,
 	homeProxy adapter.Adapter,
+	mediaStore blobstore.Store,
 ) {
 	voiceGroup := group.Group("/voice")
 	voiceGroup.Post("/generate", NewVoceHandler(
+		NewResolveLocale(dbPool),
+		tts.NewGenerate(dbPool, homeProxy),
+	))
+	voiceGroup.Post("/pronunciation/score", middleware.UploadLimit(config.BlobStoreConfig.MaxAudioBytes), NewPronunciationScoreHandler(
 		homeProxy,
-		NewUpdateHitCacheAndReturnAudio(dbPool),
-		NewInsertAudioUrlAndKeyToCacheFunc(dbPool),
+		mediaStore,
+		config.BlobStoreConfig.MaxAudioBytes,
+		NewCanSeeCard(dbPool),
+		NewResolveLocale(dbPool),
+		NewInsertPronunciationAttempt(dbPool),
+	))
+	voiceGroup.Post("/pronunciation/attempts", NewPronunciationHistoryHandler(
+		NewListPronunciationAttempts(dbPool, mediaStore),
+	))
+	voiceGroup.Get("/pronunciation/trend", NewPronunciationTrendHandler(
+		NewPronunciationTrend(dbPool),
+	))
+	voiceGroup.Get("/pronunciation/mispronounced", NewMostMispronouncedHandler(
+		NewMostMispronounced(dbPool),
 	))
-	voiceGroup.Post("/pronunciation/score", NewPronunciationScoreHandler(homeProxy))
 
 }
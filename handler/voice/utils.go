@@ -1,23 +1,24 @@
 package voice
 
 import (
-	"regexp"
 	"strings"
-)
+	"unicode"
 
-var (
-	rePunct = regexp.MustCompile(`[^\p{L}\p{N}\s']+`) // keep letters, numbers, spaces, apostrophe
-	reSpace = regexp.MustCompile(`\s+`)
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/langcode"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/textnorm"
 )
 
 func normalizeText(s string) string {
-	s = strings.ToLower(strings.TrimSpace(s))
-	s = rePunct.ReplaceAllString(s, " ")
-	s = reSpace.ReplaceAllString(s, " ")
-	return strings.TrimSpace(s)
+	return textnorm.Normalize(s)
 }
 
-func tokenizeWords(s string) []string {
+// tokenizeWords splits s into the units the error rate is counted over:
+// words, or characters with their combining marks for languages written
+// without spaces, such as Thai.
+func tokenizeWords(s, locale string) []string {
+	if langcode.Unspaced(locale) {
+		return tokenizeChars(s)
+	}
 	s = normalizeText(s)
 	if s == "" {
 		return nil
@@ -25,6 +26,21 @@ func tokenizeWords(s string) []string {
 	return strings.Split(s, " ")
 }
 
+func tokenizeChars(s string) []string {
+	var out []string
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsMark(r):
+			if len(out) > 0 {
+				out[len(out)-1] += string(r)
+			}
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			out = append(out, string(r))
+		}
+	}
+	return out
+}
+
 type WerReport struct {
 	SourceWords []string `json:"sourceWords"`
 	SttWords    []string `json:"sttWords"`
@@ -57,9 +73,9 @@ func clampInt(v, lo, hi int) int {
 	return v
 }
 
-func ScoreByWER(sourceText, sttText string) WerReport {
-	src := tokenizeWords(sourceText)
-	hyp := tokenizeWords(sttText)
+func ScoreByWER(sourceText, sttText, locale string) WerReport {
+	src := tokenizeWords(sourceText, locale)
+	hyp := tokenizeWords(sttText, locale)
 
 	n := len(src)
 	// If source is empty, define score as 0 unless hyp also empty
@@ -1,21 +1,17 @@
 package voice
 
 import (
-	"encoding/json"
-
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
-	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/adapter"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
-	"go.uber.org/zap"
 )
 
 func NewVoceHandler(
-	homeProxyAdapter adapter.Adapter,
-	updateHitCacheAndReturnAudio UpdateHitCacheAndReturnAudio,
-	insertAudioUrlAndKeyToCacheFunc InsertAudioUrlAndKeyToCacheFunc,
+	resolveLocaleFunc ResolveLocaleFunc,
+	generateFunc tts.GenerateFunc,
 ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req VoiceRequest
@@ -31,34 +27,20 @@ func NewVoceHandler(
 		if err := validate.Struct(req); err != nil {
 			return api.ValidationErrorResponse(c, err, req)
 		}
-		cacheKey := utils.BuildCacheKey(req.Text)
-		audioUrl, err := updateHitCacheAndReturnAudio(ctx, logger, cacheKey)
-		if err != nil || audioUrl == "" {
-			logger.Info("audio url not found in cache")
-			var ttsResp TtsResponseFromHomeProxy
-			_, body, err := homeProxyAdapter.Post(ctx, api.TtsPath, &adapter.RequestOptions{
-				Headers: map[string]string{"requestId": requestId},
-				JSON: TtsRequestToHomeProxy{
-					Prompt: req.Text,
-				},
-			})
-			if err != nil {
-				logger.Warn("failed to post to home proxy", zap.Error(err))
-				return api.InternalError(c, "cannot get audio url")
-			}
-			err = json.Unmarshal(body, &ttsResp)
-			if err != nil {
-				logger.Warn("failed to post to home proxy", zap.Error(err))
-				return api.InternalError(c, "cannot get audio url")
-			}
-			audioUrl = ttsResp.Body.Url
-
-			err = insertAudioUrlAndKeyToCacheFunc(ctx, logger, cacheKey, req.Text, audioUrl, ttsResp.Body.Key)
+		if err := req.Validate(); err != nil {
+			return api.BadRequest(c, err.Error())
+		}
+		opts := tts.Options{Voice: req.Voice, Speed: req.Speed, Format: req.Format, Locale: req.Locale}
+		if opts.Locale == "" {
+			opts.Locale, err = resolveLocaleFunc(ctx, logger, req.CardId, req.Side, utils.GetUserIDToken(c))
 			if err != nil {
-				logger.Warn("failed to post to home proxy", zap.Error(err))
 				return api.InternalError(c, "cannot get audio url")
 			}
 		}
+		clip, err := generateFunc(ctx, logger, requestId, req.Text, opts)
+		if err != nil {
+			return api.InternalError(c, "cannot get audio url")
+		}
 		//err := insertDailyPlansFunc(ctx, logger)
 		//if err != nil {
 		//	logger.Error(err.Error(), zap.String("requestId", requestId))
@@ -66,7 +48,11 @@ func NewVoceHandler(
 		//}
 		// TODO
 		return api.Ok(c, fiber.Map{
-			"audioUrl": audioUrl,
+			"audioUrl": clip.AudioUrl,
+			"voice":    opts.Voice,
+			"speed":    opts.Speed,
+			"format":   opts.Format,
+			"locale":   opts.Locale,
 		})
 	}
 }
@@ -0,0 +1,45 @@
+package blobstore
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+)
+
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// Store keeps opaque blobs under slash-separated keys chosen by the caller.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL returns an address clients can fetch the blob from.
+	URL(key string) string
+}
+
+func New(cfg config.BlobStoreConfig, client *http.Client) (Store, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", DriverLocal:
+		return NewLocalStore(cfg.Local)
+	case DriverS3:
+		return NewS3Store(cfg.S3, client)
+	}
+	return nil, errors.Errorf("unknown blob store driver %q", cfg.Driver)
+}
+
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
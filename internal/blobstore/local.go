@@ -0,0 +1,63 @@
+package blobstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+)
+
+// LocalStore writes blobs below a directory for self-hosted installs. The
+// directory is served by the API itself at ServePath unless BaseURL points to
+// another server.
+type LocalStore struct {
+	root    string
+	baseURL string
+}
+
+func NewLocalStore(cfg config.LocalBlobStoreConfig) (*LocalStore, error) {
+	if strings.TrimSpace(cfg.Root) == "" {
+		return nil, errors.New("local blob store root is required")
+	}
+	if err := os.MkdirAll(cfg.Root, 0o755); err != nil {
+		return nil, errors.Wrap(err, "create blob store root")
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "/" + strings.Trim(cfg.ServePath, "/")
+	}
+	return &LocalStore{root: cfg.Root, baseURL: baseURL}, nil
+}
+
+func (s *LocalStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	if !validKey(key) {
+		return errors.Errorf("invalid blob key %q", key)
+	}
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "create blob dir")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.Wrap(err, "write blob")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "commit blob")
+}
+
+func (s *LocalStore) Delete(_ context.Context, key string) error {
+	if !validKey(key) {
+		return errors.Errorf("invalid blob key %q", key)
+	}
+	err := os.Remove(filepath.Join(s.root, filepath.FromSlash(key)))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "delete blob")
+	}
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
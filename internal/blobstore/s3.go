@@ -0,0 +1,254 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3Service         = "s3"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+// S3Store talks to AWS S3 or any compatible server (MinIO, R2, Ceph) with
+// Signature V4. Objects are private; URLs are presigned unless PublicBaseURL
+// is set, e.g. for a bucket behind a CDN.
+type S3Store struct {
+	endpoint      *url.URL
+	region        string
+	bucket        string
+	accessKey     string
+	secretKey     string
+	pathStyle     bool
+	publicBaseURL string
+	presignExpiry time.Duration
+	client        *http.Client
+	now           func() time.Time
+}
+
+func NewS3Store(cfg config.S3BlobStoreConfig, client *http.Client) (*S3Store, error) {
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 bucket, access key and secret key are required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	expiry := cfg.PresignExpiry
+	if expiry <= 0 || expiry > 7*24*time.Hour {
+		expiry = 24 * time.Hour
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Store{
+		endpoint:      u,
+		region:        cfg.Region,
+		bucket:        cfg.Bucket,
+		accessKey:     cfg.AccessKey,
+		secretKey:     cfg.SecretKey,
+		pathStyle:     cfg.UsePathStyle,
+		publicBaseURL: strings.TrimRight(cfg.PublicBaseURL, "/"),
+		presignExpiry: expiry,
+		client:        client,
+		now:           time.Now,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return errors.Errorf("invalid blob key %q", key)
+	}
+	sum := sha256.Sum256(data)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "build s3 put")
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, hex.EncodeToString(sum[:]))
+	return s.do(req)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return errors.Errorf("invalid blob key %q", key)
+	}
+	sum := sha256.Sum256(nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "build s3 delete")
+	}
+	s.sign(req, hex.EncodeToString(sum[:]))
+	return s.do(req)
+}
+
+func (s *S3Store) URL(key string) string {
+	if s.publicBaseURL != "" {
+		return s.publicBaseURL + "/" + key
+	}
+	return s.presign(http.MethodGet, key, s.presignExpiry)
+}
+
+func (s *S3Store) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "s3 request")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("s3 %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+// sign adds Signature V4 headers over host, x-amz-content-sha256 and
+// x-amz-date.
+func (s *S3Store) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	req.Header.Set("x-amz-date", now.Format(s3TimeFormat))
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + now.Format(s3TimeFormat),
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+func (s *S3Store) presign(method, key string, expiry time.Duration) string {
+	now := s.now().UTC()
+	u := s.objectURL(key)
+	scope := s.scope(now)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	rawQuery := s3CanonicalQuery(query)
+
+	canonical := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		rawQuery,
+		"host:" + u.Host,
+		"",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	u.RawQuery = rawQuery + "&X-Amz-Signature=" + s.signature(now, scope, canonical)
+	return u.String()
+}
+
+func (s *S3Store) scope(now time.Time) string {
+	return strings.Join([]string{now.Format(s3DateFormat), s.region, s3Service, "aws4_request"}, "/")
+}
+
+func (s *S3Store) signature(now time.Time, scope, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		scope,
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every path segment as Signature V4 expects.
+func s3EscapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = s3Escape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func s3CanonicalQuery(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, s3Escape(k)+"="+s3Escape(v.Get(k)))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -12,6 +12,8 @@ import (
 const (
 	KeyWebhook = "webhook_%s"
 	KeyAiModel = "flash-card:ai_model"
+
+	KeyCatalogRanking = "flash-card:catalog:ranking:%s"
 )
 
 var mode string
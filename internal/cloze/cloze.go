@@ -0,0 +1,95 @@
+package cloze
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	CardTypeBasic = "BASIC"
+	CardTypeCloze = "CLOZE"
+
+	// Blank replaces the hidden text when a cloze has no hint.
+	Blank = "[...]"
+)
+
+// markerPattern matches {{c1::word}} and {{c1::word::hint}}.
+var markerPattern = regexp.MustCompile(`(?s)\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+// NormalizeCardType maps a user supplied card type to BASIC or CLOZE; empty
+// means BASIC. ok is false for unknown types.
+func NormalizeCardType(s string) (cardType string, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", CardTypeBasic:
+		return CardTypeBasic, true
+	case CardTypeCloze:
+		return CardTypeCloze, true
+	}
+	return "", false
+}
+
+// Ordinals returns the distinct cloze numbers found in text, ascending. A
+// cloze note produces one study item per ordinal.
+func Ordinals(text string) []int {
+	seen := map[int]bool{}
+	var ords []int
+	for _, m := range markerPattern.FindAllStringSubmatch(text, -1) {
+		ord, err := strconv.Atoi(m[1])
+		if err != nil || ord <= 0 || seen[ord] {
+			continue
+		}
+		seen[ord] = true
+		ords = append(ords, ord)
+	}
+	sort.Ints(ords)
+	return ords
+}
+
+// Prompt renders text for study item ord: its clozes become a blank (or the
+// hint in brackets) and every other cloze is shown as plain text.
+func Prompt(text string, ord int) string {
+	return markerPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := markerPattern.FindStringSubmatch(s)
+		if n, _ := strconv.Atoi(m[1]); n != ord {
+			return m[2]
+		}
+		if hint := strings.TrimSpace(m[3]); hint != "" {
+			return "[" + hint + "]"
+		}
+		return Blank
+	})
+}
+
+// Answer returns the text hidden by ord; several occurrences of the same
+// ordinal are joined with ", ".
+func Answer(text string, ord int) string {
+	var parts []string
+	for _, m := range markerPattern.FindAllStringSubmatch(text, -1) {
+		if n, _ := strconv.Atoi(m[1]); n == ord {
+			parts = append(parts, strings.TrimSpace(m[2]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Plain renders text with every cloze revealed.
+func Plain(text string) string {
+	return markerPattern.ReplaceAllString(text, "$2")
+}
+
+// Match reports whether a typed answer equals the expected one, ignoring case,
+// surrounding punctuation and repeated whitespace.
+func Match(expected, typed string) bool {
+	e := normalizeAnswer(expected)
+	return e != "" && e == normalizeAnswer(typed)
+}
+
+func normalizeAnswer(s string) string {
+	s = strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	return strings.TrimFunc(s, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
@@ -0,0 +1,68 @@
+// Package langcode handles the BCP 47 language tags ("th-TH", "en-US") that
+// sets use for their front and back sides and that TTS and STT run in.
+package langcode
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Default is used when neither the set nor the user names a language.
+const Default = "en-US"
+
+var reTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Normalize canonicalizes the case of a tag: "TH-th" becomes "th-TH" and
+// "zh-hant-tw" becomes "zh-Hant-TW". Empty stays empty; ok is false when s
+// is not a language tag.
+func Normalize(s string) (code string, ok bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "_", "-"))
+	if s == "" {
+		return "", true
+	}
+	if !reTag.MatchString(s) {
+		return "", false
+	}
+	parts := strings.Split(s, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// Resolve returns the first valid, non-empty code, or Default.
+func Resolve(codes ...string) string {
+	for _, c := range codes {
+		if code, ok := Normalize(c); ok && code != "" {
+			return code
+		}
+	}
+	return Default
+}
+
+// Language returns the primary language subtag, "th" for "th-TH".
+func Language(code string) string {
+	code, _ = Normalize(code)
+	lang, _, _ := strings.Cut(code, "-")
+	return lang
+}
+
+// unspaced languages do not put spaces between words.
+var unspaced = map[string]bool{
+	"th": true, "lo": true, "km": true, "my": true,
+	"ja": true, "zh": true,
+}
+
+// Unspaced reports whether text in code is written without spaces between
+// words, so it has to be compared by character rather than by word.
+func Unspaced(code string) bool {
+	return unspaced[Language(code)]
+}
@@ -0,0 +1,54 @@
+package media
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Type is what an uploaded file was sniffed as, with the extension it is
+// stored under.
+type Type struct {
+	Kind string
+	Ext  string
+}
+
+// allowedMediaTypes is keyed by the sniffed content type, never by what the
+// client claims.
+var allowedMediaTypes = map[string]Type{
+	"image/png":       {KindImage, ".png"},
+	"image/jpeg":      {KindImage, ".jpg"},
+	"image/gif":       {KindImage, ".gif"},
+	"image/webp":      {KindImage, ".webp"},
+	"audio/mpeg":      {KindAudio, ".mp3"},
+	"audio/wave":      {KindAudio, ".wav"},
+	"application/ogg": {KindAudio, ".ogg"},
+	"audio/mp4":       {KindAudio, ".m4a"},
+}
+
+// DetectType sniffs data. MP3 files without an ID3 tag and M4A files are
+// not recognised by the sniffer, so for those the file extension decides.
+func DetectType(data []byte, filename string) (string, Type, bool) {
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if contentType == "application/octet-stream" || contentType == "video/mp4" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".mp3":
+			if isMpegAudioFrame(data) {
+				contentType = "audio/mpeg"
+			}
+		case ".m4a":
+			if contentType == "video/mp4" {
+				contentType = "audio/mp4"
+			}
+		}
+	}
+	t, ok := allowedMediaTypes[contentType]
+	return contentType, t, ok
+}
+
+func isMpegAudioFrame(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0
+}
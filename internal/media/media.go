@@ -0,0 +1,70 @@
+package media
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
+	"go.uber.org/zap"
+)
+
+const (
+	SideFront = "FRONT"
+	SideBack  = "BACK"
+
+	KindImage = "IMAGE"
+	KindAudio = "AUDIO"
+)
+
+// Attachment is a media file linked to one side of a card, as returned in
+// card payloads.
+type Attachment struct {
+	Id          decimal.Decimal `json:"id"`
+	Side        string          `json:"side"`
+	Kind        string          `json:"kind"`
+	ContentType string          `json:"contentType"`
+	SizeBytes   int64           `json:"sizeBytes"`
+	Url         string          `json:"url"`
+}
+
+type CardMediaLoaderFunc func(ctx context.Context, logger *zap.Logger, cardIds []int64) (map[int64][]Attachment, error)
+
+// NewCardMediaLoader fetches the attachments of many cards in one query and
+// resolves their URLs through the blob store.
+func NewCardMediaLoader(db *pgxpool.Pool, store blobstore.Store) CardMediaLoaderFunc {
+	const sql = `
+		SELECT id, card_id, side, kind, content_type, size_bytes, storage_key
+		  FROM tbl_flashcard_media
+		 WHERE card_id = ANY($1::bigint[])
+		 ORDER BY card_id, side DESC, id
+	`
+	return func(ctx context.Context, logger *zap.Logger, cardIds []int64) (map[int64][]Attachment, error) {
+		result := map[int64][]Attachment{}
+		if len(cardIds) == 0 {
+			return result, nil
+		}
+		rows, err := db.Query(ctx, sql, cardIds)
+		if err != nil {
+			logger.Error("load card media failed", zap.Error(err))
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				a          Attachment
+				id, cardID int64
+				key        string
+			)
+			if err := rows.Scan(&id, &cardID, &a.Side, &a.Kind, &a.ContentType, &a.SizeBytes, &key); err != nil {
+				logger.Error("scan card media failed", zap.Error(err))
+				return nil, err
+			}
+			a.Id = decimal.NewFromInt(id)
+			a.Url = store.URL(key)
+			result[cardID] = append(result[cardID], a)
+		}
+		return result, rows.Err()
+	}
+}
@@ -0,0 +1,69 @@
+package studyitem
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// Directions of a study item. A card is always studied FORWARD (front is the
+// prompt); sets with study direction BOTH also study every basic card REVERSE.
+const (
+	DirectionForward = "FORWARD"
+	DirectionReverse = "REVERSE"
+
+	SetDirectionForward = "FORWARD"
+	SetDirectionBoth    = "BOTH"
+)
+
+const maxChoices = 4
+
+// NormalizeDirection maps an item direction to FORWARD or REVERSE; empty
+// means FORWARD. ok is false for unknown values.
+func NormalizeDirection(s string) (direction string, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", DirectionForward:
+		return DirectionForward, true
+	case DirectionReverse:
+		return DirectionReverse, true
+	}
+	return "", false
+}
+
+// NormalizeSetDirection maps a set study direction to FORWARD or BOTH; empty
+// means FORWARD. ok is false for unknown values.
+func NormalizeSetDirection(s string) (direction string, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", SetDirectionForward:
+		return SetDirectionForward, true
+	case SetDirectionBoth:
+		return SetDirectionBoth, true
+	}
+	return "", false
+}
+
+// Choices picks up to three distinct distractors from pool and mixes them
+// with the answer.
+func Choices(answer string, pool []string) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(answer)): true}
+	var distractors []string
+	for _, p := range pool {
+		key := strings.ToLower(strings.TrimSpace(p))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		distractors = append(distractors, p)
+	}
+	rand.Shuffle(len(distractors), func(i, j int) {
+		distractors[i], distractors[j] = distractors[j], distractors[i]
+	})
+	if len(distractors) > maxChoices-1 {
+		distractors = distractors[:maxChoices-1]
+	}
+
+	choices := append([]string{answer}, distractors...)
+	rand.Shuffle(len(choices), func(i, j int) {
+		choices[i], choices[j] = choices[j], choices[i]
+	})
+	return choices
+}
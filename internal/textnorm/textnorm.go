@@ -0,0 +1,20 @@
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	rePunct = regexp.MustCompile(`[^\p{L}\p{N}\s']+`) // keep letters, numbers, spaces, apostrophe
+	reSpace = regexp.MustCompile(`\s+`)
+)
+
+// Normalize lowercases s, turns punctuation into spaces and collapses
+// whitespace, so "Hello,  World!" and "hello world" compare equal.
+func Normalize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = rePunct.ReplaceAllString(s, " ")
+	s = reSpace.ReplaceAllString(s, " ")
+	return strings.TrimSpace(s)
+}
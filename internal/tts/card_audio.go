@@ -0,0 +1,94 @@
+package tts
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/studyitem"
+	"go.uber.org/zap"
+)
+
+// Audio types of tbl_flashcard_audio.
+const (
+	AudioFrontNormal = "front_normal"
+	AudioFrontSlow   = "front_slow"
+	AudioBack        = "back"
+)
+
+// CardAudio is the pre-generated speech of a card, as returned in card
+// payloads. A clip is left out until the card audio job has made it for the
+// card's current text.
+type CardAudio struct {
+	Front     string `json:"front,omitempty"`
+	FrontSlow string `json:"frontSlow,omitempty"`
+	Back      string `json:"back,omitempty"`
+}
+
+// ForItem fits the card's clips to a study item: a reverse item shows the
+// back first, and a cloze item only speaks the full sentence once answered.
+func (a CardAudio) ForItem(isCloze bool, direction string) CardAudio {
+	if isCloze {
+		return CardAudio{Back: a.Front}
+	}
+	if direction == studyitem.DirectionReverse {
+		return CardAudio{Front: a.Back, Back: a.Front}
+	}
+	return a
+}
+
+// QueueCardAudioFunc asks for the card audio job to run soon, once cards were
+// created, imported or edited. It does not block.
+type QueueCardAudioFunc func()
+
+type CardAudioLoaderFunc func(ctx context.Context, logger *zap.Logger, cardIds []int64) (map[int64]CardAudio, error)
+
+// NewCardAudioLoader fetches the clips of many cards in one query, skipping
+// those made from text the card no longer has or in another language than
+// its set now names for that side. These are the clips the card audio job
+// makes again.
+func NewCardAudioLoader(db *pgxpool.Pool) CardAudioLoaderFunc {
+	const sql = `
+		SELECT a.flashcard_id, a.audio_type, c.audio_url
+		  FROM tbl_flashcard_audio a
+		  JOIN tbl_tts_cache c ON c.id = a.tts_cache_id
+		  JOIN tbl_flashcards f ON f.id = a.flashcard_id
+		  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+		 WHERE a.flashcard_id = ANY($1::bigint[])
+		   AND a.source_text = CASE WHEN a.audio_type = 'back' THEN coalesce(f.back, '') ELSE coalesce(f.front, '') END
+		   AND a.locale = coalesce(CASE WHEN a.audio_type = 'back' THEN s.back_lang ELSE s.front_lang END, a.locale)
+	`
+	return func(ctx context.Context, logger *zap.Logger, cardIds []int64) (map[int64]CardAudio, error) {
+		result := map[int64]CardAudio{}
+		if len(cardIds) == 0 {
+			return result, nil
+		}
+		rows, err := db.Query(ctx, sql, cardIds)
+		if err != nil {
+			logger.Error("load card audio failed", zap.Error(err))
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				cardID              int64
+				audioType, audioUrl string
+			)
+			if err := rows.Scan(&cardID, &audioType, &audioUrl); err != nil {
+				logger.Error("scan card audio failed", zap.Error(err))
+				return nil, err
+			}
+			a := result[cardID]
+			switch audioType {
+			case AudioFrontNormal:
+				a.Front = audioUrl
+			case AudioFrontSlow:
+				a.FrontSlow = audioUrl
+			case AudioBack:
+				a.Back = audioUrl
+			}
+			result[cardID] = a
+		}
+		return result, rows.Err()
+	}
+}
@@ -0,0 +1,128 @@
+// Package tts speaks text through the home proxy and caches the clips in
+// tbl_tts_cache, keyed by the text and the options it was spoken with.
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/adapter"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultVoice  = "DEFAULT"
+	DefaultFormat = "mp3"
+	SpeedNormal   = 1.0
+	SpeedSlow     = 0.7 // for learners, the front_slow clip
+)
+
+// Options is how a text is spoken; each combination is cached on its own.
+type Options struct {
+	Voice  string
+	Speed  float64
+	Format string
+	Locale string
+}
+
+func (o Options) CacheKey(text string) string {
+	return utils.BuildCacheKey(text, o.Voice, o.Speed, o.Format, o.Locale)
+}
+
+type requestToHomeProxy struct {
+	Prompt string  `json:"prompt"`
+	Locale string  `json:"locale"`
+	Voice  string  `json:"voice"`
+	Speed  float64 `json:"speed"`
+	Format string  `json:"format"`
+}
+
+type responseFromHomeProxy struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Body    struct {
+		Key string `json:"key"`
+		Url string `json:"url"`
+	}
+}
+
+// Clip is a cached recording of a text.
+type Clip struct {
+	CacheId  int64
+	AudioUrl string
+}
+
+type GenerateFunc func(ctx context.Context, logger *zap.Logger, requestId, text string, opts Options) (Clip, error)
+
+// NewGenerate returns the cached clip for text spoken with opts, asking the
+// home proxy for it only on a cache miss.
+func NewGenerate(db *pgxpool.Pool, homeProxy adapter.Adapter) GenerateFunc {
+	const hitSQL = `
+		UPDATE tbl_tts_cache
+		   SET hit_count        = hit_count + 1,
+		       last_accessed_at = now()
+		 WHERE cache_key = $1
+		RETURNING id, audio_url
+	`
+	// a concurrent miss on the same key may have stored it first; its clip
+	// is kept
+	const insertSQL = `
+		INSERT INTO tbl_tts_cache (cache_key, text, voice, speed, format, locale, audio_url, last_accessed_at, audio_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now(), $8)
+		ON CONFLICT (cache_key) DO UPDATE
+		   SET last_accessed_at = now()
+		RETURNING id, audio_url
+	`
+	return func(ctx context.Context, logger *zap.Logger, requestId, text string, opts Options) (Clip, error) {
+		var clip Clip
+		cacheKey := opts.CacheKey(text)
+		rows, err := db.Query(ctx, hitSQL, cacheKey)
+		if err != nil {
+			logger.Error("tts cache lookup failed", zap.Error(err))
+			return clip, errors.New("cannot get audio url")
+		}
+		for rows.Next() {
+			if err := rows.Scan(&clip.CacheId, &clip.AudioUrl); err != nil {
+				rows.Close()
+				return clip, err
+			}
+		}
+		rows.Close()
+		if clip.AudioUrl != "" {
+			return clip, nil
+		}
+
+		logger.Info("audio url not found in cache")
+		var resp responseFromHomeProxy
+		_, body, err := homeProxy.Post(ctx, api.TtsPath, &adapter.RequestOptions{
+			Headers: map[string]string{"requestId": requestId},
+			JSON: requestToHomeProxy{
+				Prompt: text,
+				Locale: opts.Locale,
+				Voice:  opts.Voice,
+				Speed:  opts.Speed,
+				Format: opts.Format,
+			},
+		})
+		if err != nil {
+			logger.Warn("failed to post to home proxy", zap.Error(err))
+			return clip, errors.New("cannot get audio url")
+		}
+		if err := json.Unmarshal(body, &resp); err != nil || resp.Body.Url == "" {
+			logger.Warn("unexpected tts response from home proxy", zap.Error(err), zap.String("code", resp.Code))
+			return clip, errors.New("cannot get audio url")
+		}
+
+		err = db.QueryRow(ctx, insertSQL, cacheKey, text, opts.Voice, opts.Speed, opts.Format, opts.Locale,
+			resp.Body.Url, resp.Body.Key).Scan(&clip.CacheId, &clip.AudioUrl)
+		if err != nil {
+			logger.Error("tts cache insert failed", zap.Error(err))
+			return clip, errors.New("cannot get audio url")
+		}
+		return clip, nil
+	}
+}
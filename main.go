@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-redis/redis/v9"
@@ -16,16 +17,25 @@ import (
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/config"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/auth"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/catalog"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/daily_plans"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/exam_sessions"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/flashcard_sets"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/folders"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/job"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/learn"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/media"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/practice_sessions"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/search"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/tags"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/trash"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/handler/voice"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/blobstore"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/cache"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/db"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/httputil"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/logz"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/internal/tts"
 	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/middleware"
 	"go.uber.org/zap"
 )
@@ -34,7 +44,6 @@ func main() {
 	currentTime := time.Now()
 	versionDeploy := currentTime.Unix()
 	ctx := context.Background()
-	app := initFiber()
 	config.InitTimeZone()
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -46,6 +55,7 @@ func main() {
 
 	logz.Init(cfg.LogConfig.Level, cfg.Server.Name)
 	defer logz.Drop()
+	app := initFiber(cfg.BlobStoreConfig)
 
 	ctx, cancel = context.WithCancel(ctx)
 	defer cancel()
@@ -73,6 +83,10 @@ func main() {
 		cfg.HTTP.MaxConnPerHost,
 	)
 	_ = httpClient
+	mediaStore, err := blobstore.New(cfg.BlobStoreConfig, httpClient)
+	if err != nil {
+		logger.Fatal("init media store", zap.Error(err))
+	}
 	redisClient, err := cache.Initialize(ctx, cfg.RedisConfig)
 	if err != nil {
 		logger.Fatal("server connect to redis", zap.Error(err))
@@ -86,8 +100,13 @@ func main() {
 	}()
 	logger.Info("Redis Connected")
 
+	app.Use(middleware.BodyLimit(defaultBodyLimit, max(
+		cfg.ImportConfig.MaxFileBytes,
+		cfg.BlobStoreConfig.MaxImageBytes,
+		cfg.BlobStoreConfig.MaxAudioBytes,
+	)))
 	app.Use(middleware.AuditLogger())
-	app.Use(middleware.JWTMiddleware(cfg.JwtAuthConfig.JwtSecret))
+	app.Use(middleware.JWTMiddleware(cfg.JwtAuthConfig.JwtSecret, cfg.Server.Name))
 	group := app.Group(fmt.Sprintf("/%s/api/v1", cfg.Server.Name))
 	group.Get("/health", func(c *fiber.Ctx) error {
 		return api.Ok(c, versionDeploy)
@@ -99,19 +118,27 @@ func main() {
 	////admin
 	//admin.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient))
 	//flashCardSets
-	flashcard_sets.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient))
+	queueCardAudio := job.NewCardAudioQueue(job.NewGenerateCardAudioFunc(dbPool, tts.NewGenerate(dbPool, *homeProxyAdapter)))
+	flashcard_sets.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient), mediaStore, queueCardAudio)
 
-	voice.GetRouter(group, dbPool, *homeProxyAdapter)
+	voice.GetRouter(group, *cfg, dbPool, *homeProxyAdapter, mediaStore)
 	learn.GetRouter(group, dbPool)
+	practice_sessions.GetRouter(group, dbPool)
+	search.GetRouter(group, dbPool)
+	tags.GetRouter(group, dbPool)
+	folders.GetRouter(group, dbPool)
+	trash.GetRouter(group, *cfg, dbPool)
+	media.GetRouter(group, *cfg, dbPool, mediaStore)
+	catalog.GetRouter(group, *cfg, &redisCMD, dbPool)
 	// daily
-	daily_plans.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient))
+	daily_plans.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient), mediaStore)
 
 	//job
-	job.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient))
+	job.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient), mediaStore, *homeProxyAdapter)
 
 	//TODO
 	//exam_sessions
-	exam_sessions.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient))
+	exam_sessions.GetRouter(group, *cfg, &redisCMD, dbPool, httputil.NewHttpPostCall(httpClient), mediaStore)
 
 	//TODO
 	// chat bot
@@ -122,10 +149,13 @@ func main() {
 
 }
 
-func initFiber() *fiber.App {
+const defaultBodyLimit = 10 * 1024 * 1024
+
+func initFiber(blobCfg config.BlobStoreConfig) *fiber.App {
 	app := fiber.New(
 		fiber.Config{
-			BodyLimit:             10 * 1024 * 1024,
+			BodyLimit:             defaultBodyLimit,
+			StreamRequestBody:     true,
 			ReadBufferSize:        64 * 1024,
 			ReadTimeout:           5 * time.Second,
 			WriteTimeout:          5 * time.Second,
@@ -138,6 +168,13 @@ func initFiber() *fiber.App {
 	defaultConfig := cors.ConfigDefault
 	defaultConfig.AllowHeaders = "*"
 	app.Use(cors.New(defaultConfig))
+	// self-hosted media is fetched by <img>/<audio> tags, which cannot send
+	// requestId or a bearer token, so it is mounted ahead of those checks
+	if (blobCfg.Driver == "" || strings.EqualFold(blobCfg.Driver, blobstore.DriverLocal)) && blobCfg.Local.BaseURL == "" {
+		app.Static("/"+strings.Trim(blobCfg.Local.ServePath, "/"), blobCfg.Local.Root, fiber.Static{
+			MaxAge: 86400,
+		})
+	}
 	app.Use(SetHeaderID())
 	return app
 }
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gitlab.com/home-server7795544/home-server/flash-card/flash-card-api/api"
+)
+
+// multipartOverhead is what the boundaries and part headers of an upload
+// may add on top of the file itself.
+const multipartOverhead = 64 * 1024
+
+// BodyLimit caps request bodies by their Content-Length. Request bodies are
+// streamed, so fiber's BodyLimit only bounds how much is buffered; JSON
+// bodies may be up to maxBytes and multipart uploads up to maxUploadBytes,
+// which each upload route narrows with UploadLimit. A chunked body has no
+// length to check up front, so it is read up to the limit instead.
+func BodyLimit(maxBytes, maxUploadBytes int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := maxBytes
+		if strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEMultipartForm) {
+			limit = maxUploadBytes + multipartOverhead
+		}
+		if c.Request().Header.ContentLength() == -1 {
+			return readChunkedBody(c, limit)
+		}
+		return checkContentLength(c, limit)
+	}
+}
+
+// UploadLimit caps the body of an upload route whose file may be up to
+// maxFileBytes. Uploads must state their length, so a chunked one is
+// refused before any of it is read.
+func UploadLimit(maxFileBytes int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Request().Header.ContentLength() == -1 {
+			return c.Status(fiber.StatusLengthRequired).JSON(api.Err("411", fiber.ErrLengthRequired.Message))
+		}
+		return checkContentLength(c, maxFileBytes+multipartOverhead)
+	}
+}
+
+func checkContentLength(c *fiber.Ctx, limit int64) error {
+	if int64(c.Request().Header.ContentLength()) > limit {
+		return tooLarge(c, limit)
+	}
+	return c.Next()
+}
+
+// readChunkedBody buffers a body sent without a Content-Length, stopping one
+// byte past limit.
+func readChunkedBody(c *fiber.Ctx, limit int64) error {
+	req := c.Request()
+	stream := req.BodyStream()
+	if stream == nil {
+		if int64(len(req.Body())) > limit {
+			return tooLarge(c, limit)
+		}
+		return c.Next()
+	}
+	body, err := io.ReadAll(io.LimitReader(stream, limit+1))
+	if err != nil {
+		return api.BadRequest(c, api.InvalidateBody)
+	}
+	if int64(len(body)) > limit {
+		return tooLarge(c, limit)
+	}
+	req.SetBody(body)
+	return c.Next()
+}
+
+func tooLarge(c *fiber.Ctx, limit int64) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).
+		JSON(api.Err("413", fmt.Sprintf("request body must be at most %d bytes", limit)))
+}
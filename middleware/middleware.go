@@ -16,10 +16,18 @@ var ignorePaths = []string{
 	"/job/",
 }
 
-func JWTMiddleware(jwtSecret string) fiber.Handler {
+// JWTMiddleware checks the bearer token of every request under the
+// /<serverName>/api/v1 group except the ignored paths.
+func JWTMiddleware(jwtSecret, serverName string) fiber.Handler {
+	// share links: the token in the path is checked by the handlers. Only
+	// the shared route group is open, not every path containing /shared/.
+	sharedPrefix := "/" + serverName + "/api/v1/shared/"
 	return func(c *fiber.Ctx) error {
 		tokenString := c.Get("Authorization")
 
+		if strings.HasPrefix(c.Path(), sharedPrefix) {
+			return c.Next()
+		}
 		for _, subPath := range ignorePaths {
 			if strings.Contains(c.Path(), subPath) {
 				return c.Next()
@@ -0,0 +1,19 @@
+ALTER TABLE tbl_flashcards
+    ADD COLUMN card_type VARCHAR(10) NOT NULL DEFAULT 'BASIC'; -- BASIC|CLOZE
+
+ALTER TABLE tbl_flashcards
+    ADD CONSTRAINT tbl_flashcards_card_type_check CHECK (card_type IN ('BASIC', 'CLOZE'));
+
+-- a cloze note yields one study item per {{cN::...}}; basic cards use 0
+ALTER TABLE tbl_user_flashcard_srs
+    ADD COLUMN cloze_ord SMALLINT NOT NULL DEFAULT 0;
+
+ALTER TABLE tbl_user_flashcard_srs
+    DROP CONSTRAINT tbl_user_flashcard_srs_pkey,
+    ADD PRIMARY KEY (user_id_token, card_id, cloze_ord);
+
+ALTER TABLE tbl_review_log
+    ADD COLUMN cloze_ord SMALLINT NOT NULL DEFAULT 0;
+
+ALTER TABLE tbl_exam_questions
+    ADD COLUMN cloze_ord SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- FORWARD studies front -> back only; BOTH also studies basic cards back -> front
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN study_direction VARCHAR(10) NOT NULL DEFAULT 'FORWARD';
+
+ALTER TABLE tbl_flashcard_sets
+    ADD CONSTRAINT tbl_flashcard_sets_study_direction_check CHECK (study_direction IN ('FORWARD', 'BOTH'));
+
+ALTER TABLE tbl_user_flashcard_srs
+    ADD COLUMN direction VARCHAR(7) NOT NULL DEFAULT 'FORWARD'; -- FORWARD|REVERSE
+
+ALTER TABLE tbl_user_flashcard_srs
+    ADD CONSTRAINT tbl_user_flashcard_srs_direction_check CHECK (direction IN ('FORWARD', 'REVERSE'));
+
+ALTER TABLE tbl_user_flashcard_srs
+    DROP CONSTRAINT tbl_user_flashcard_srs_pkey,
+    ADD PRIMARY KEY (user_id_token, card_id, cloze_ord, direction);
+
+ALTER TABLE tbl_review_log
+    ADD COLUMN direction VARCHAR(7) NOT NULL DEFAULT 'FORWARD';
+
+ALTER TABLE tbl_exam_questions
+    ADD COLUMN direction VARCHAR(7) NOT NULL DEFAULT 'FORWARD';
@@ -0,0 +1,21 @@
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN forked_from_set_id INT REFERENCES tbl_flashcard_sets (id) ON DELETE SET NULL,
+    ADD COLUMN forked_at          TIMESTAMP;
+
+-- upstream_card_id has no foreign key on purpose: a purged upstream card must
+-- still show up as deleted upstream for its fork copies.
+-- upstream_revision: revision of the upstream card last pulled into the copy
+-- local_revision:    revision of the copy right after that pull; a higher
+--                    revision means the fork owner edited the card since
+ALTER TABLE tbl_flashcards
+    ADD COLUMN upstream_card_id  BIGINT,
+    ADD COLUMN upstream_revision INT,
+    ADD COLUMN local_revision    INT;
+
+CREATE INDEX idx_tbl_flashcard_sets_forked_from
+    ON tbl_flashcard_sets (forked_from_set_id)
+    WHERE forked_from_set_id IS NOT NULL;
+
+CREATE INDEX idx_tbl_flashcards_upstream_card_id
+    ON tbl_flashcards (upstream_card_id)
+    WHERE upstream_card_id IS NOT NULL;
@@ -0,0 +1,34 @@
+CREATE TABLE tbl_flashcard_set_likes (
+    set_id        INT         NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    user_id_token VARCHAR(36) NOT NULL,
+    create_at     TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (set_id, user_id_token)
+);
+
+CREATE INDEX idx_tbl_flashcard_set_likes_create_at
+    ON tbl_flashcard_set_likes (create_at);
+
+CREATE TABLE tbl_flashcard_set_bookmarks (
+    set_id        INT         NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    user_id_token VARCHAR(36) NOT NULL,
+    create_at     TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (set_id, user_id_token)
+);
+
+CREATE INDEX idx_tbl_flashcard_set_bookmarks_user
+    ON tbl_flashcard_set_bookmarks (user_id_token, create_at);
+
+CREATE TABLE tbl_flashcard_set_ratings (
+    set_id        INT         NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    user_id_token VARCHAR(36) NOT NULL,
+    stars         SMALLINT    NOT NULL CHECK (stars BETWEEN 1 AND 5),
+    comment       TEXT,
+    create_at     TIMESTAMP DEFAULT now(),
+    create_by     VARCHAR(255),
+    update_at     TIMESTAMP,
+    PRIMARY KEY (set_id, user_id_token)
+);
+
+-- study counts are derived from the review log per card
+CREATE INDEX idx_review_log_card_time
+    ON tbl_review_log (card_id, created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE tbl_flashcard_set_shares (
+    id           BIGSERIAL PRIMARY KEY,
+    set_id       INT          NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    token        VARCHAR(64)  NOT NULL UNIQUE,
+    permission   VARCHAR(10)  NOT NULL, -- 'VIEW'|'COPY'|'EDIT'
+    expires_at   TIMESTAMP,
+    revoked_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    create_at    TIMESTAMP DEFAULT now(),
+    create_by    VARCHAR(255),
+    CONSTRAINT tbl_flashcard_set_shares_permission_check CHECK (permission IN ('VIEW', 'COPY', 'EDIT'))
+);
+
+CREATE INDEX idx_tbl_flashcard_set_shares_set_id
+    ON tbl_flashcard_set_shares (set_id);
@@ -0,0 +1,37 @@
+-- optimistic concurrency: every content change bumps version; clients send
+-- the version they edited (body or If-Match) and get 409 when it moved on
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE tbl_flashcards
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE tbl_flashcard_set_collaborators (
+    set_id        INT         NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    user_id_token VARCHAR(36) NOT NULL,
+    role          VARCHAR(10) NOT NULL,                   -- 'EDITOR'|'VIEWER'
+    status        VARCHAR(10) NOT NULL DEFAULT 'PENDING', -- 'PENDING'|'ACCEPTED'
+    invited_by    VARCHAR(255),
+    create_at     TIMESTAMP DEFAULT now(),
+    accepted_at   TIMESTAMP,
+    PRIMARY KEY (set_id, user_id_token),
+    CONSTRAINT tbl_flashcard_set_collaborators_role_check CHECK (role IN ('EDITOR', 'VIEWER')),
+    CONSTRAINT tbl_flashcard_set_collaborators_status_check CHECK (status IN ('PENDING', 'ACCEPTED'))
+);
+
+CREATE INDEX idx_tbl_flashcard_set_collaborators_user
+    ON tbl_flashcard_set_collaborators (user_id_token, status);
+
+CREATE TABLE tbl_flashcard_set_activity (
+    id          BIGSERIAL PRIMARY KEY,
+    set_id      INT         NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    card_id     BIGINT,
+    action      VARCHAR(30) NOT NULL,
+    detail      JSONB,
+    actor_token VARCHAR(36),
+    actor       VARCHAR(255),
+    create_at   TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX idx_tbl_flashcard_set_activity_set_time
+    ON tbl_flashcard_set_activity (set_id, create_at DESC);
@@ -0,0 +1,21 @@
+-- study status is per user: marking a shared or public card learned must not
+-- change it for everybody else studying the set. No row means 'studying'.
+CREATE TABLE tbl_user_flashcard_status (
+    user_id_token VARCHAR(36) NOT NULL,
+    card_id       BIGINT      NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    status        VARCHAR(50) NOT NULL, -- studying|learned|wrongAnswerInTest|...
+    update_at     TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (user_id_token, card_id)
+);
+
+-- the shared column only ever reflected the owner's own study
+INSERT INTO tbl_user_flashcard_status (user_id_token, card_id, status)
+SELECT s.owner_user_token, f.id, f.status
+  FROM tbl_flashcards f
+  JOIN tbl_flashcard_sets s ON s.id = f.set_id
+ WHERE f.status IS NOT NULL
+   AND f.status <> 'studying'
+   AND s.owner_user_token IS NOT NULL;
+
+ALTER TABLE tbl_flashcards
+    DROP COLUMN status;
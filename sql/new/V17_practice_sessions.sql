@@ -0,0 +1,58 @@
+-- practice sessions replace tbl_flashcard_sets_tracker: a session fixes the
+-- card order of a run over a set (seq 0..n-1, optionally shuffled by seed),
+-- remembers how far the user got and what they answered, and can be resumed
+-- from any device. A user may keep several named sessions on the same set.
+CREATE TABLE tbl_practice_sessions (
+    id               BIGSERIAL PRIMARY KEY,
+    user_id_token    VARCHAR(36)  NOT NULL,
+    set_id           INT          NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    name             VARCHAR(100) NOT NULL,
+    shuffle_seed     BIGINT,                                 -- NULL keeps the set order
+    filters          JSONB,
+    position         INT          NOT NULL DEFAULT 0,        -- seq of the next card to show
+    status           VARCHAR(20)  NOT NULL DEFAULT 'ACTIVE', -- ACTIVE|COMPLETED|ABANDONED
+    started_at       TIMESTAMP    NOT NULL DEFAULT now(),
+    last_activity_at TIMESTAMP    NOT NULL DEFAULT now(),
+    completed_at     TIMESTAMP,
+    CONSTRAINT tbl_practice_sessions_status_check CHECK (status IN ('ACTIVE', 'COMPLETED', 'ABANDONED'))
+);
+
+CREATE INDEX idx_tbl_practice_sessions_user_set
+    ON tbl_practice_sessions (user_id_token, set_id, status);
+
+CREATE TABLE tbl_practice_session_cards (
+    session_id  BIGINT NOT NULL REFERENCES tbl_practice_sessions (id) ON DELETE CASCADE,
+    seq         INT    NOT NULL,
+    card_id     BIGINT NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    result      VARCHAR(10), -- CORRECT|WRONG|SKIPPED, NULL while unanswered
+    answered_at TIMESTAMP,
+    PRIMARY KEY (session_id, seq),
+    CONSTRAINT tbl_practice_session_cards_result_check CHECK (result IN ('CORRECT', 'WRONG', 'SKIPPED'))
+);
+
+-- every tracker becomes an active session over the set in card order,
+-- positioned at the card it pointed to
+INSERT INTO tbl_practice_sessions (user_id_token, set_id, name, position)
+SELECT t.user_id_token,
+       t.set_id,
+       coalesce(t.tracker_type, 'flashCardPageType'),
+       coalesce((SELECT count(*)
+                   FROM tbl_flashcards f
+                   JOIN tbl_flashcards c ON c.id = t.card_id
+                  WHERE f.set_id = t.set_id
+                    AND f.is_deleted = 'N'
+                    AND (f.seq, f.id) < (c.seq, c.id)), 0)
+  FROM tbl_flashcard_sets_tracker t
+  JOIN tbl_flashcard_sets s ON s.id = t.set_id
+ WHERE s.is_deleted = 'N'
+   AND t.user_id_token IS NOT NULL;
+
+INSERT INTO tbl_practice_session_cards (session_id, seq, card_id)
+SELECT ps.id,
+       row_number() OVER (PARTITION BY ps.id ORDER BY f.seq, f.id) - 1,
+       f.id
+  FROM tbl_practice_sessions ps
+  JOIN tbl_flashcards f ON f.set_id = ps.set_id
+ WHERE f.is_deleted = 'N';
+
+DROP TABLE tbl_flashcard_sets_tracker;
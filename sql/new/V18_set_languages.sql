@@ -0,0 +1,6 @@
+-- BCP 47 language of each side of a set, e.g. 'th-TH' front and 'en-US'
+-- back; TTS and pronunciation scoring run in the language of the side they
+-- read. NULL falls back to the user's tbl_user_config.locale.
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN front_lang VARCHAR(20),
+    ADD COLUMN back_lang  VARCHAR(20);
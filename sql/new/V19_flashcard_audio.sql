@@ -0,0 +1,14 @@
+-- pre-generated speech for every card, filled in by the card audio job.
+-- V3 dropped the first version of this table before anything wrote to it;
+-- source_text and locale record what a clip was made from, so a card whose
+-- text or set language has changed since is picked up again.
+CREATE TABLE tbl_flashcard_audio (
+    flashcard_id BIGINT      NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    audio_type   VARCHAR(20) NOT NULL, -- front_normal|front_slow|back
+    tts_cache_id BIGINT      NOT NULL REFERENCES tbl_tts_cache (id),
+    source_text  TEXT        NOT NULL, -- the card's front or back when generated
+    locale       VARCHAR(20) NOT NULL,
+    created_at   TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (flashcard_id, audio_type),
+    CONSTRAINT tbl_flashcard_audio_type_check CHECK (audio_type IN ('front_normal', 'front_slow', 'back'))
+);
@@ -0,0 +1,9 @@
+-- every pronunciation score is kept as an attempt; score_detail holds the
+-- WER report, whose mismatches feed the most-mispronounced words. The
+-- recording itself is only stored when the client asks for it.
+ALTER TABLE tbl_pronunciation_attempt
+    ADD COLUMN locale    VARCHAR(20),
+    ADD COLUMN audio_key VARCHAR(255);
+
+CREATE INDEX idx_pron_attempt_user_card_time
+    ON tbl_pronunciation_attempt (user_id_token, card_id, created_at);
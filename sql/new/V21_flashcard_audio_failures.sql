@@ -0,0 +1,12 @@
+-- cards the card audio job could not speak. A failing card is retried with
+-- a growing delay, and only while its text is the one that failed, so a few
+-- bad cards cannot hold up the rest of the queue.
+CREATE TABLE tbl_flashcard_audio_failure (
+    flashcard_id    BIGINT    NOT NULL PRIMARY KEY REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    front_text      TEXT      NOT NULL,
+    back_text       TEXT      NOT NULL,
+    attempts        INT       NOT NULL DEFAULT 1,
+    last_error      TEXT,
+    last_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMP NOT NULL
+);
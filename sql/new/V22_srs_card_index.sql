@@ -0,0 +1,3 @@
+-- catalog learners are counted from SRS state per card
+CREATE INDEX idx_srs_card
+    ON tbl_user_flashcard_srs (card_id);
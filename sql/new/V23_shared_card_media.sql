@@ -0,0 +1,6 @@
+-- copied and forked cards link the blobs of the originals
+ALTER TABLE tbl_flashcard_media
+    DROP CONSTRAINT tbl_flashcard_media_storage_key_key;
+
+CREATE INDEX idx_tbl_flashcard_media_storage_key
+    ON tbl_flashcard_media (storage_key);
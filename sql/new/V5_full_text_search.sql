@@ -0,0 +1,29 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- full-text vectors ('simple' keeps every language, no stemming)
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+            setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+        ) STORED;
+
+ALTER TABLE tbl_flashcards
+    ADD COLUMN search_vector tsvector
+        GENERATED ALWAYS AS (
+            setweight(to_tsvector('simple', coalesce(front, '')), 'A') ||
+            setweight(to_tsvector('simple', coalesce(back, '')), 'B')
+        ) STORED;
+
+CREATE INDEX idx_tbl_flashcard_sets_search_vector
+    ON tbl_flashcard_sets USING GIN (search_vector);
+
+CREATE INDEX idx_tbl_flashcards_search_vector
+    ON tbl_flashcards USING GIN (search_vector);
+
+-- trigram fallback for Thai/CJK text that has no word boundaries
+CREATE INDEX idx_tbl_flashcard_sets_search_trgm
+    ON tbl_flashcard_sets USING GIN (lower(coalesce(title, '') || ' ' || coalesce(description, '')) gin_trgm_ops);
+
+CREATE INDEX idx_tbl_flashcards_search_trgm
+    ON tbl_flashcards USING GIN (lower(coalesce(front, '') || ' ' || coalesce(back, '')) gin_trgm_ops);
@@ -0,0 +1,51 @@
+CREATE TABLE tbl_tags (
+    id            BIGSERIAL PRIMARY KEY,
+    user_id_token VARCHAR(36) NOT NULL,
+    name          VARCHAR(50) NOT NULL,
+    create_at     TIMESTAMP DEFAULT now(),
+    update_at     TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uq_tbl_tags_user_name
+    ON tbl_tags (user_id_token, lower(name));
+
+CREATE TABLE tbl_flashcard_set_tags (
+    set_id    INT    NOT NULL REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE,
+    tag_id    BIGINT NOT NULL REFERENCES tbl_tags (id) ON DELETE CASCADE,
+    create_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (set_id, tag_id)
+);
+
+CREATE INDEX idx_tbl_flashcard_set_tags_tag_id
+    ON tbl_flashcard_set_tags (tag_id);
+
+CREATE TABLE tbl_flashcard_tags (
+    card_id   BIGINT NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    tag_id    BIGINT NOT NULL REFERENCES tbl_tags (id) ON DELETE CASCADE,
+    create_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (card_id, tag_id)
+);
+
+CREATE INDEX idx_tbl_flashcard_tags_tag_id
+    ON tbl_flashcard_tags (tag_id);
+
+CREATE TABLE tbl_folders (
+    id            SERIAL PRIMARY KEY,
+    user_id_token VARCHAR(36) NOT NULL,
+    parent_id     INT REFERENCES tbl_folders (id) ON DELETE CASCADE,
+    name          VARCHAR(255) NOT NULL,
+    create_at     TIMESTAMP  DEFAULT now(),
+    create_by     VARCHAR(255),
+    update_at     TIMESTAMP,
+    update_by     VARCHAR(255),
+    is_deleted    VARCHAR(1) DEFAULT 'N'
+);
+
+CREATE INDEX idx_tbl_folders_user_parent
+    ON tbl_folders (user_id_token, parent_id);
+
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN folder_id INT REFERENCES tbl_folders (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_tbl_flashcard_sets_folder_id
+    ON tbl_flashcard_sets (folder_id);
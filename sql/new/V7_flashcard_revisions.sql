@@ -0,0 +1,14 @@
+CREATE TABLE tbl_flashcard_revisions (
+    id             BIGSERIAL PRIMARY KEY,
+    card_id        BIGINT      NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    revision       INT         NOT NULL,
+    front          TEXT,
+    back           TEXT,
+    choices        TEXT[],
+    action         VARCHAR(20) NOT NULL, -- 'CREATE'|'UPDATE'|'IMPORT'|'REVERT'
+    changed_fields TEXT[]      NOT NULL DEFAULT '{}',
+    reverted_from  INT,
+    create_at      TIMESTAMP DEFAULT now(),
+    create_by      VARCHAR(255),
+    UNIQUE (card_id, revision)
+);
@@ -0,0 +1,38 @@
+ALTER TABLE tbl_flashcard_sets
+    ADD COLUMN deleted_at TIMESTAMP;
+
+ALTER TABLE tbl_flashcards
+    ADD COLUMN deleted_at TIMESTAMP;
+
+UPDATE tbl_flashcard_sets
+   SET deleted_at = coalesce(update_at, create_at, now())
+ WHERE is_deleted = 'Y';
+
+UPDATE tbl_flashcards
+   SET deleted_at = coalesce(update_at, create_at, now())
+ WHERE is_deleted = 'Y';
+
+CREATE INDEX idx_tbl_flashcard_sets_deleted_at
+    ON tbl_flashcard_sets (deleted_at)
+    WHERE is_deleted = 'Y';
+
+CREATE INDEX idx_tbl_flashcards_deleted_at
+    ON tbl_flashcards (deleted_at)
+    WHERE is_deleted = 'Y';
+
+-- purged cards and sets must not be blocked by history that keeps its own snapshot
+ALTER TABLE tbl_exam_questions
+    ALTER COLUMN card_id DROP NOT NULL,
+    DROP CONSTRAINT tbl_exam_questions_card_id_fkey,
+    ADD CONSTRAINT tbl_exam_questions_card_id_fkey
+        FOREIGN KEY (card_id) REFERENCES tbl_flashcards (id) ON DELETE SET NULL;
+
+ALTER TABLE tbl_exam_sessions
+    DROP CONSTRAINT tbl_exam_sessions_source_set_id_fkey,
+    ADD CONSTRAINT tbl_exam_sessions_source_set_id_fkey
+        FOREIGN KEY (source_set_id) REFERENCES tbl_flashcard_sets (id) ON DELETE SET NULL;
+
+ALTER TABLE tbl_study_class_sets
+    DROP CONSTRAINT tbl_study_class_sets_set_id_fkey,
+    ADD CONSTRAINT tbl_study_class_sets_set_id_fkey
+        FOREIGN KEY (set_id) REFERENCES tbl_flashcard_sets (id) ON DELETE CASCADE;
@@ -0,0 +1,15 @@
+CREATE TABLE tbl_flashcard_media (
+    id            BIGSERIAL PRIMARY KEY,
+    card_id       BIGINT       NOT NULL REFERENCES tbl_flashcards (id) ON DELETE CASCADE,
+    side          VARCHAR(5)   NOT NULL, -- FRONT|BACK
+    kind          VARCHAR(10)  NOT NULL, -- IMAGE|AUDIO
+    content_type  VARCHAR(100) NOT NULL,
+    size_bytes    BIGINT       NOT NULL,
+    storage_key   VARCHAR(255) NOT NULL UNIQUE,
+    original_name VARCHAR(255),
+    create_at     TIMESTAMP DEFAULT now(),
+    create_by     VARCHAR(255)
+);
+
+CREATE INDEX idx_tbl_flashcard_media_card_id
+    ON tbl_flashcard_media (card_id);
@@ -5,17 +5,26 @@ import (
 	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-func BuildCacheKey(text string) string {
-	speed := "1.0"
-	format := "mp3"
-	locale := "en-US"
+// BuildCacheKey identifies generated speech for text; the same text in
+// another voice, speed, format or locale is a different clip. The default
+// voice is left out and speed keeps one decimal at least, so clips cached
+// before these were configurable keep their keys.
+func BuildCacheKey(text, voice string, speed float64, format, locale string) string {
+	speedStr := strconv.FormatFloat(speed, 'f', -1, 64)
+	if !strings.Contains(speedStr, ".") {
+		speedStr += ".0"
+	}
 	text = strings.ToLower(text)
 	reg := regexp.MustCompile(`[^\p{L}\p{N}]+`)
 	text = reg.ReplaceAllString(text, "")
-	raw := fmt.Sprintf("%s|%s|%s|%s", text, speed, format, locale)
+	raw := fmt.Sprintf("%s|%s|%s|%s", text, speedStr, format, locale)
+	if voice != "" && voice != "DEFAULT" {
+		raw += "|" + voice
+	}
 
 	hash := sha256.Sum256([]byte(raw))
 
@@ -32,3 +32,8 @@ func GetIndexFromString(str string) int {
 	}
 	return int(c-'A') + 1
 }
+
+// EscapeLike escapes LIKE wildcards so user input is matched literally.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}